@@ -0,0 +1,227 @@
+package activity
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	disgojson "github.com/disgoorg/json"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+)
+
+// PositionBatchWindow is the default time the position updates of a guild are collected for.
+const PositionBatchWindow = 500 * time.Millisecond
+
+// CoalescingClient is the ChannelClient queuing the position updates of a guild and sending them once per window.
+// The presences are handled one after the other, so the updates return at once and the ones of the next presences
+// join the queue. Only the moves of the updates, the ones with a parent, are kept: a later move of a channel replaces
+// the earlier one and the positions they shift are computed again from the channels of the guild when the window
+// ends. The error of the combined update goes to onError. A guild has one update in flight at most, the updates
+// queued meanwhile are sent a window after it.
+type CoalescingClient struct {
+	ChannelClient
+	window   time.Duration
+	onError  func(guildID snowflake.ID, err error)
+	mu       sync.Mutex
+	batches  map[snowflake.ID]*positionBatch
+	flushing map[snowflake.ID]bool
+}
+
+type positionBatch struct {
+	moves map[snowflake.ID]positionMove
+	// opts are the options of every queued update, the later ones applied last
+	opts []rest.RequestOpt
+}
+
+// positionMove is the parent a channel is moved to, and its position when the update sets one.
+type positionMove struct {
+	parentID snowflake.ID
+	position *int
+}
+
+func NewCoalescingClient(
+	client ChannelClient,
+	window time.Duration,
+	onError func(guildID snowflake.ID, err error),
+) *CoalescingClient {
+	return &CoalescingClient{
+		ChannelClient: client,
+		window:        window,
+		onError:       onError,
+		batches:       map[snowflake.ID]*positionBatch{},
+		flushing:      map[snowflake.ID]bool{},
+	}
+}
+
+func (c *CoalescingClient) UpdateChannelPositions(
+	guildID snowflake.ID,
+	guildChannelPositionUpdates []discord.GuildChannelPositionUpdate,
+	opts ...rest.RequestOpt,
+) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batch, ok := c.batches[guildID]
+	if !ok {
+		batch = &positionBatch{moves: map[snowflake.ID]positionMove{}}
+		c.batches[guildID] = batch
+
+		if !c.flushing[guildID] {
+			c.schedule(guildID)
+		}
+	}
+
+	for _, update := range guildChannelPositionUpdates {
+		if update.ParentID == nil {
+			continue
+		}
+
+		move := positionMove{parentID: *update.ParentID}
+		if update.Position != nil && !update.Position.IsNull() {
+			move.position = disgojson.Ptr(update.Position.Value())
+		}
+
+		batch.moves[update.ID] = move
+	}
+
+	batch.opts = append(batch.opts, opts...)
+
+	return nil
+}
+
+// schedule flushes the batch of guildID once the window ends, c.mu is held.
+func (c *CoalescingClient) schedule(guildID snowflake.ID) {
+	time.AfterFunc(c.window, func() { c.flush(guildID) })
+}
+
+// flush sends the batch of guildID, then schedules the batch queued meanwhile.
+func (c *CoalescingClient) flush(guildID snowflake.ID) {
+	c.mu.Lock()
+	batch := c.batches[guildID]
+	delete(c.batches, guildID)
+	c.flushing[guildID] = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.flushing, guildID)
+
+		if _, queued := c.batches[guildID]; queued {
+			c.schedule(guildID)
+		}
+	}()
+
+	channels, err := c.ChannelClient.GetGuildChannels(guildID, batch.opts...)
+	if err != nil {
+		c.onError(guildID, oops.With("guild_id", guildID).Wrapf(err, "failed to get channels to update positions"))
+
+		return
+	}
+
+	updates := positionDiff(channels, batch.moves)
+	if len(updates) == 0 {
+		return
+	}
+
+	err = c.ChannelClient.UpdateChannelPositions(guildID, updates, batch.opts...)
+	if err != nil {
+		c.onError(guildID, err)
+	}
+}
+
+// positionDiff applies moves to channels and returns the updates for the channels whose parent or position changed.
+// A channel moved into a parent, or to another position in its parent, is placed after the last sibling sorting
+// before its name, as findPosition does, the siblings keep their order and are numbered from 0.
+func positionDiff(
+	channels []discord.GuildChannel,
+	moves map[snowflake.ID]positionMove,
+) []discord.GuildChannelPositionUpdate {
+	byID := map[snowflake.ID]discord.GuildChannel{}
+	children := map[snowflake.ID][]discord.GuildChannel{}
+
+	for _, channel := range channels {
+		byID[channel.ID()] = channel
+
+		if channel.ParentID() != nil {
+			children[*channel.ParentID()] = append(children[*channel.ParentID()], channel)
+		}
+	}
+
+	var incoming []discord.GuildChannel
+
+	for channelID, move := range moves {
+		channel, found := byID[channelID]
+		if !found || parentOf(channel) == move.parentID && (move.position == nil || *move.position == channel.Position()) {
+			continue
+		}
+
+		incoming = append(incoming, channel)
+		children[parentOf(channel)] = slices.DeleteFunc(children[parentOf(channel)], func(sibling discord.GuildChannel) bool {
+			return sibling.ID() == channelID
+		})
+	}
+
+	slices.SortFunc(incoming, func(a, b discord.GuildChannel) int { return cmp.Compare(a.Name(), b.Name()) })
+
+	var affected []snowflake.ID
+
+	for _, channel := range incoming {
+		affected = append(affected, parentOf(channel), moves[channel.ID()].parentID)
+	}
+
+	slices.Sort(affected)
+
+	var updates []discord.GuildChannelPositionUpdate
+
+	for _, parentID := range slices.Compact(affected) {
+		siblings := children[parentID]
+		slices.SortStableFunc(siblings, func(a, b discord.GuildChannel) int {
+			return cmp.Compare(a.Position(), b.Position())
+		})
+
+		for _, channel := range incoming {
+			if moves[channel.ID()].parentID != parentID {
+				continue
+			}
+
+			index := 0
+
+			for i, sibling := range siblings {
+				if sibling.Name() < channel.Name() {
+					index = i + 1
+				}
+			}
+
+			siblings = slices.Insert(siblings, index, channel)
+		}
+
+		for position, channel := range siblings {
+			update := discord.GuildChannelPositionUpdate{ID: channel.ID()}
+
+			if parentOf(channel) != parentID {
+				update.ParentID = &parentID
+			} else if channel.Position() == position {
+				continue
+			}
+
+			update.Position = disgojson.NewNullablePtr(position)
+			updates = append(updates, update)
+		}
+	}
+
+	return updates
+}
+
+func parentOf(channel discord.GuildChannel) snowflake.ID {
+	if channel.ParentID() == nil {
+		return 0
+	}
+
+	return *channel.ParentID()
+}
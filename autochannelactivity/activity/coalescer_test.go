@@ -0,0 +1,285 @@
+package activity_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	disgojson "github.com/disgoorg/json"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+	"eggmech/host/fakediscord"
+)
+
+const coalescingWindow = 100 * time.Millisecond
+
+// TestCoalescingClient handles the presences one after the other, as the gateway and the workers do.
+func TestCoalescingClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []fakediscord.Option
+		channels []fakediscord.Channel
+		run      func(ctx context.Context, client *activity.CoalescingClient, repo *activitytest.MemoryRepository,
+			reporter *activity.Reporter)
+		updates int
+		errors  int
+		expect  []func(server *fakediscord.Server) error
+	}{
+		{
+			name:     "the sessions of three players end, each one archiving its own game",
+			channels: coalescingChannels(),
+			run: func(ctx context.Context, client *activity.CoalescingClient, repo *activitytest.MemoryRepository,
+				reporter *activity.Reporter,
+			) {
+				for i := range coalescingGames {
+					start := time.Now()
+
+					handleUserPresence(ctx, client, repo, reporter, userID+snowflake.ID(100+i))
+
+					if elapsed := time.Since(start); elapsed >= coalescingWindow {
+						t.Errorf("presence waited %s on the position update", elapsed)
+					}
+				}
+			},
+			updates: 1,
+			expect: []func(server *fakediscord.Server) error{
+				func(server *fakediscord.Server) error {
+					return expectFakeOrder(server, categoryArchiveID, "a-game", "celeste", "portal", "zelda")
+				},
+				func(server *fakediscord.Server) error { return expectFakeOrder(server, categoryGameID) },
+				expectContiguous,
+			},
+		},
+		{
+			name: "a channel is moved in its own parent",
+			channels: []fakediscord.Channel{
+				{ID: categoryArchiveID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryArchive},
+				fakeText(channelID, "zelda", categoryArchiveID, 0),
+				fakeText(channelID+1, "celeste", categoryArchiveID, 1),
+			},
+			run: func(_ context.Context, client *activity.CoalescingClient, _ *activitytest.MemoryRepository,
+				_ *activity.Reporter,
+			) {
+				moveFake(t, client, channelID+1, categoryArchiveID, 0)
+			},
+			updates: 1,
+			expect: []func(server *fakediscord.Server) error{
+				func(server *fakediscord.Server) error {
+					return expectFakeOrder(server, categoryArchiveID, "celeste", "zelda")
+				},
+			},
+		},
+		{
+			name: "a channel is moved where it already is",
+			channels: []fakediscord.Channel{
+				{ID: categoryArchiveID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryArchive},
+				fakeText(channelID, "celeste", categoryArchiveID, 0),
+			},
+			run: func(_ context.Context, client *activity.CoalescingClient, _ *activitytest.MemoryRepository,
+				_ *activity.Reporter,
+			) {
+				moveFake(t, client, channelID, categoryArchiveID, 0)
+			},
+		},
+		{
+			name:     "Discord refuses the combined update",
+			opts:     []fakediscord.Option{fakediscord.WithoutManageChannels()},
+			channels: coalescingChannels(),
+			run: func(_ context.Context, client *activity.CoalescingClient, _ *activitytest.MemoryRepository,
+				_ *activity.Reporter,
+			) {
+				moveFake(t, client, channelID+1, categoryArchiveID, 0)
+				moveFake(t, client, channelID+2, categoryArchiveID, 0)
+			},
+			updates: 1,
+			errors:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := activitytest.NewMemoryRepository()
+
+			for i, name := range coalescingGames {
+				repo.Sessions = append(repo.Sessions, activitytest.Session{
+					UUID:    activity.Uuidv7("open-" + strconv.Itoa(i)),
+					GuildID: guildID,
+					UserID:  userID + snowflake.ID(100+i),
+					Name:    name,
+				})
+			}
+
+			server := fakediscord.New(append([]fakediscord.Option{fakediscord.WithToken(fakeToken)}, test.opts...)...)
+			server.AddGuild(guildID, test.channels...)
+
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			restClient := newFakeRest(httpServer)
+			reporter := newAdminReporter(restClient)
+
+			var (
+				mu       sync.Mutex
+				reported []error
+			)
+
+			client := activity.NewCoalescingClient(restClient, coalescingWindow, func(id snowflake.ID, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if id != guildID {
+					err = errors.Join(err, oops.Errorf("reported to guild %d", id))
+				}
+
+				reported = append(reported, err)
+			})
+
+			test.run(ctx, client, repo, reporter)
+
+			time.Sleep(3 * coalescingWindow)
+
+			if updates := positionUpdates(server); updates != test.updates {
+				t.Errorf("expected %d position updates, got %d", test.updates, updates)
+			}
+
+			mu.Lock()
+			if len(reported) != test.errors {
+				t.Errorf("expected %d errors, got %v", test.errors, reported)
+			}
+			mu.Unlock()
+
+			if messages := server.Messages(adminChannelID); len(messages) != 0 {
+				t.Errorf("expected no error reported by the handler, got %v", messages)
+			}
+
+			for _, expect := range test.expect {
+				if err := expect(server); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+var coalescingGames = []string{"Zelda", "Portal", "Celeste"}
+
+// coalescingChannels are both categories, the game one holding the channels of coalescingGames.
+func coalescingChannels() []fakediscord.Channel {
+	channels := []fakediscord.Channel{
+		{ID: categoryGameID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryGame},
+		{ID: categoryArchiveID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryArchive, Position: 1},
+		fakeText(channelID, "a-game", categoryArchiveID, 0),
+		fakeText(adminChannelID, "admin", 0, 0),
+	}
+
+	for i, name := range coalescingGames {
+		channels = append(channels, fakeText(channelID+1+snowflake.ID(i), strings.ToLower(name), categoryGameID, i))
+	}
+
+	return channels
+}
+
+// moveFake queues the move of channelID to position in parentID.
+func moveFake(t *testing.T, client *activity.CoalescingClient, channelID, parentID snowflake.ID, position int) {
+	t.Helper()
+
+	err := client.UpdateChannelPositions(guildID, []discord.GuildChannelPositionUpdate{{
+		ID:       channelID,
+		ParentID: &parentID,
+		Position: disgojson.NewNullablePtr(position),
+	}})
+	if err != nil {
+		t.Errorf("unexpected error queuing the move: %v", err)
+	}
+}
+
+func positionUpdates(server *fakediscord.Server) int {
+	updates := 0
+
+	for _, request := range server.Requests() {
+		if request.Method == http.MethodPatch && request.Path == fakediscord.APIPath+"/guilds/"+guildID.String()+"/channels" {
+			updates++
+		}
+	}
+
+	return updates
+}
+
+// TestCoalescingClientSlowFlush queues a move while the update of the guild is still in flight, it is sent once that
+// one is done, from the channels as they are then.
+func TestCoalescingClientSlowFlush(t *testing.T) {
+	server := fakediscord.New(fakediscord.WithToken(fakeToken))
+	server.AddGuild(guildID, coalescingChannels()...)
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	slow := &slowChannels{
+		ChannelClient: newFakeRest(httpServer),
+		started:       make(chan struct{}, 2),
+		release:       make(chan struct{}),
+	}
+	client := activity.NewCoalescingClient(slow, coalescingWindow, func(_ snowflake.ID, err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+
+	moveFake(t, client, channelID+3, categoryArchiveID, 0)
+
+	select {
+	case <-slow.started:
+	case <-time.After(time.Second):
+		t.Fatal("expected the first update to start")
+	}
+
+	moveFake(t, client, channelID+2, categoryArchiveID, 0)
+	time.Sleep(3 * coalescingWindow)
+	close(slow.release)
+	time.Sleep(3 * coalescingWindow)
+
+	if updates := positionUpdates(server); updates != 2 {
+		t.Errorf("expected 2 position updates, got %d", updates)
+	}
+
+	for _, err := range []error{
+		expectFakeOrder(server, categoryArchiveID, "a-game", "celeste", "portal"),
+		expectFakeOrder(server, categoryGameID, "zelda"),
+		expectContiguous(server),
+	} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// slowChannels holds the channel listings until release is closed, and fails the test when two are in flight.
+type slowChannels struct {
+	activity.ChannelClient
+	started  chan struct{}
+	release  chan struct{}
+	inFlight atomic.Int32
+}
+
+func (c *slowChannels) GetGuildChannels(guildID snowflake.ID, opts ...rest.RequestOpt) ([]discord.GuildChannel, error) {
+	if c.inFlight.Add(1) > 1 {
+		return nil, oops.Errorf("two position updates of guild %d in flight", guildID)
+	}
+	defer c.inFlight.Add(-1)
+
+	c.started <- struct{}{}
+	<-c.release
+
+	return c.ChannelClient.GetGuildChannels(guildID, opts...)
+}
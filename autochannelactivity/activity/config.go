@@ -0,0 +1,85 @@
+package activity
+
+import (
+	"slices"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+const CategoryGame = "game"
+const CategoryArchive = "game archive"
+
+// Categories are the names of the categories the bot works in, matched case-insensitively.
+type Categories struct {
+	Game    string `yaml:"game"`
+	Archive string `yaml:"archive"`
+}
+
+// Thresholds are the settings used for guilds without aca_activity_settings.
+type Thresholds struct {
+	MinimumPlayers int `yaml:"minimum_players"`
+	MinimumHours   int `yaml:"minimum_hours"`
+	DayInterval    int `yaml:"day_interval"`
+}
+
+func DefaultCategories() Categories {
+	return Categories{
+		Game:    CategoryGame,
+		Archive: CategoryArchive,
+	}
+}
+
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		MinimumPlayers: 1,
+		MinimumHours:   1,
+		DayInterval:    1,
+	}
+}
+
+// DryRun plans the channel mutations instead of executing them, in every guild when Enabled or in the listed Guilds.
+// Planned actions are logged and also posted to AdminChannelID when set.
+type DryRun struct {
+	Enabled        bool           `yaml:"enabled"`
+	Guilds         []snowflake.ID `yaml:"guilds"`
+	AdminChannelID snowflake.ID   `yaml:"admin_channel_id"`
+}
+
+func (d DryRun) Active() bool {
+	return d.Enabled || len(d.Guilds) > 0
+}
+
+func (d DryRun) Guild(guildID snowflake.ID) bool {
+	return d.Enabled || slices.Contains(d.Guilds, guildID)
+}
+
+// DeletionPolicy is what becomes of a game once someone else deletes the channel the bot made for it.
+// Categories are not per game, they are always made again by name when needed.
+type DeletionPolicy string
+
+const (
+	// DeletionRecreate makes the channel again at the next session of the game.
+	DeletionRecreate DeletionPolicy = "recreate"
+	// DeletionUntrack keeps recording the sessions of the game without making its channel again.
+	DeletionUntrack DeletionPolicy = "untrack"
+	// DeletionBlocklist neither records the sessions of the game nor makes its channel again.
+	DeletionBlocklist DeletionPolicy = "blocklist"
+)
+
+func (p DeletionPolicy) Valid() bool {
+	return p == DeletionRecreate || p == DeletionUntrack || p == DeletionBlocklist
+}
+
+// DeletionPolicies are the policy of every guild, Default for the guilds not listed in Guilds.
+type DeletionPolicies struct {
+	Default DeletionPolicy                  `yaml:"default"`
+	Guilds  map[snowflake.ID]DeletionPolicy `yaml:"guilds"`
+}
+
+func (d DeletionPolicies) Guild(guildID snowflake.ID) DeletionPolicy {
+	if policy, ok := d.Guilds[guildID]; ok {
+		return policy
+	}
+
+	return d.Default
+}
@@ -0,0 +1,113 @@
+package activity
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// DeletionListener handles the tracked channels deleted while the bot is connected by the policy of their guild,
+// the other channels and the categories are not stored and are left alone.
+func DeletionListener(
+	ctx context.Context,
+	repo ChannelRepository,
+	policies DeletionPolicies,
+	logger *slog.Logger,
+) bot.EventListener {
+	return &events.ListenerAdapter{
+		OnGuildChannelDelete: func(event *events.GuildChannelDelete) {
+			deleteChannel(ctx, repo, policies.Guild(event.GuildID), event.GuildID, event.ChannelID, logger)
+		},
+	}
+}
+
+// AuditChannels handles the tracked channels deleted while the bot was away, in the guilds owns tells,
+// as if their deletion had just been received.
+func AuditChannels(
+	ctx context.Context,
+	client ChannelClient,
+	repo ChannelRepository,
+	policies DeletionPolicies,
+	owns func(guildID snowflake.ID) bool,
+	logger *slog.Logger,
+) {
+	tracked, err := repo.TrackedChannels(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "cannot audit the tracked channels", slog.Any("error", err))
+
+		return
+	}
+
+	byGuild := map[snowflake.ID][]TrackedChannel{}
+
+	for _, channel := range tracked {
+		if owns(channel.GuildID) {
+			byGuild[channel.GuildID] = append(byGuild[channel.GuildID], channel)
+		}
+	}
+
+	for guildID, guildTracked := range byGuild {
+		channels, errChannels := client.GetGuildChannels(guildID)
+		if errChannels != nil {
+			logger.WarnContext(
+				ctx,
+				"cannot audit the channels of the guild",
+				slog.Any("guild_id", guildID),
+				slog.Any("error", errChannels),
+			)
+
+			continue
+		}
+
+		existing := map[snowflake.ID]bool{}
+
+		for _, channel := range channels {
+			existing[channel.ID()] = true
+		}
+
+		for _, channel := range guildTracked {
+			if !existing[channel.ChannelID] {
+				deleteChannel(ctx, repo, policies.Guild(guildID), guildID, channel.ChannelID, logger)
+			}
+		}
+	}
+
+	logger.DebugContext(ctx, "tracked channels audited", slog.Int("guilds", len(byGuild)))
+}
+
+func deleteChannel(
+	ctx context.Context,
+	repo ChannelRepository,
+	policy DeletionPolicy,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	logger *slog.Logger,
+) {
+	names, err := repo.DeleteChannel(ctx, guildID, channelID, policy, time.Now())
+	if err != nil {
+		logger.ErrorContext(
+			ctx,
+			"cannot mark the channel deleted",
+			slog.Any("guild_id", guildID),
+			slog.Any("channel_id", channelID),
+			slog.Any("error", err),
+		)
+
+		return
+	}
+
+	for _, name := range names {
+		logger.InfoContext(
+			ctx,
+			"tracked channel deleted",
+			slog.Any("guild_id", guildID),
+			slog.Any("channel_id", channelID),
+			slog.String("channel_name", name),
+			slog.String("policy", string(policy)),
+		)
+	}
+}
@@ -0,0 +1,73 @@
+package activity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+)
+
+// TestDeletion audits a guild whose tracked channel was deleted while the bot was away, then deletes the other
+// tracked channel while it is connected, the guild untracking its games and the other guilds recreating them.
+func TestDeletion(t *testing.T) {
+	const otherGuildID = guildID + 1
+
+	ctx := context.Background()
+
+	repo := activitytest.NewMemoryRepository()
+	repo.Channels = []activitytest.StoredChannel{
+		{GuildID: guildID, ChannelID: channelID, ActivityName: channelName},
+		{GuildID: guildID, ChannelID: channelID + 1, ActivityName: "zelda"},
+		{GuildID: otherGuildID, ChannelID: channelID + 2, ActivityName: "portal"},
+	}
+
+	channels := activitytest.NewRecordingChannels(
+		activitytest.Channel{ID: channelID + 1, Type: discord.ChannelTypeGuildText, Name: "zelda"},
+	)
+	policies := activity.DeletionPolicies{
+		Default: activity.DeletionRecreate,
+		Guilds:  map[snowflake.ID]activity.DeletionPolicy{guildID: activity.DeletionUntrack},
+	}
+
+	activity.AuditChannels(ctx, channels, repo, policies, func(id snowflake.ID) bool { return id == guildID },
+		discardLogger())
+
+	for _, err := range []error{
+		expectTracked(repo, channelID+1, channelID+2),
+		expectIgnored(repo, guildID, channelName),
+	} {
+		if err != nil {
+			t.Errorf("after the audit: %v", err)
+		}
+	}
+
+	listener := activity.DeletionListener(ctx, repo, policies, discardLogger())
+
+	for _, deleted := range []struct{ guildID, channelID snowflake.ID }{
+		{guildID, channelID + 1},
+		{otherGuildID, channelID + 2},
+	} {
+		listener.OnEvent(&events.GuildChannelDelete{
+			GenericGuildChannel: &events.GenericGuildChannel{
+				GenericEvent: events.NewGenericEvent(nil, 0, 0),
+				GuildID:      deleted.guildID,
+				ChannelID:    deleted.channelID,
+			},
+		})
+	}
+
+	for _, err := range []error{
+		expectTracked(repo),
+		expectIgnored(repo, guildID, channelName, "zelda"),
+		expectIgnored(repo, otherGuildID),
+	} {
+		if err != nil {
+			t.Errorf("after the deletions: %v", err)
+		}
+	}
+}
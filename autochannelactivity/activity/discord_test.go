@@ -0,0 +1,371 @@
+package activity_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+	"eggmech/host/fakediscord"
+)
+
+const (
+	fakeToken      = "fake-token"
+	adminChannelID = snowflake.ID(30)
+)
+
+// TestFakeDiscord covers what the in-memory channels cannot: Discord positions, limits and rate limits. Each test
+// handles one presence of games through a real REST client talking to a fakediscord server, the guild always has an
+// admin channel the errors are reported to.
+func TestFakeDiscord(t *testing.T) {
+	tests := []struct {
+		name           string
+		channels       []fakediscord.Channel
+		rateLimitEvery int
+		denied         bool
+		dryRun         activity.DryRun
+		setup          func(repo *activitytest.MemoryRepository)
+		games          []string
+		check          func(server *fakediscord.Server, repo *activitytest.MemoryRepository) error
+	}{
+		{
+			name:  "an empty guild gets both categories and the channel",
+			setup: withRepositoryUsage,
+			games: []string{game},
+			check: func(server *fakediscord.Server, repo *activitytest.MemoryRepository) error {
+				return errors.Join(
+					expectFakeParent(server, channelName, activity.CategoryGame),
+					expectStoredChannels(Run{Repository: repo}, 1),
+				)
+			},
+		},
+		{
+			name:           "a rate limited request is retried",
+			rateLimitEvery: 2,
+			setup:          withRepositoryUsage,
+			games:          []string{game},
+			check: func(server *fakediscord.Server, _ *activitytest.MemoryRepository) error {
+				return errors.Join(
+					expectFakeParent(server, channelName, activity.CategoryGame),
+					expectStatus(server, http.StatusTooManyRequests),
+				)
+			},
+		},
+		{
+			name:     "a full game category refuses the channel",
+			channels: fullCategory(),
+			setup:    withRepositoryUsage,
+			games:    []string{game},
+			check: func(server *fakediscord.Server, repo *activitytest.MemoryRepository) error {
+				_, found := fakeFind(server, channelName)
+				if found {
+					return oops.Errorf("expected no channel %s in a full category", channelName)
+				}
+
+				return errors.Join(
+					expectStatus(server, http.StatusBadRequest),
+					expectStoredChannels(Run{Repository: repo}, 0),
+					expectReport(server, "Maximum number of channels in category reached"),
+				)
+			},
+		},
+		{
+			name:   "a missing Manage Channels permission is reported to the admin channel",
+			denied: true,
+			setup:  withRepositoryUsage,
+			games:  []string{game},
+			check: func(server *fakediscord.Server, repo *activitytest.MemoryRepository) error {
+				return errors.Join(
+					expectStatus(server, http.StatusForbidden),
+					expectSessions(Run{Repository: repo}, 1),
+					expectReport(server, "missing the Manage Channels permission"),
+				)
+			},
+		},
+		{
+			name:   "a dry run guild only gets planned actions on the admin channel",
+			dryRun: activity.DryRun{Guilds: []snowflake.ID{guildID}, AdminChannelID: adminChannelID},
+			setup:  withRepositoryUsage,
+			games:  []string{game},
+			check: func(server *fakediscord.Server, repo *activitytest.MemoryRepository) error {
+				var errs []error
+
+				for _, request := range server.Requests() {
+					if request.Method != http.MethodGet && !strings.HasSuffix(request.Path, "/messages") {
+						errs = append(errs, oops.Errorf("expected no mutation, got %s %s", request.Method, request.Path))
+					}
+				}
+
+				// both categories, the channel and its move, in a single message, the second player plans them again
+				messages := server.Messages(adminChannelID)
+				if len(messages) != 1 || strings.Count(messages[0].Content, "\n- ") != 4 {
+					errs = append(errs, oops.Errorf("expected one message of 4 planned actions, got %v", messages))
+				}
+
+				return errors.Join(append(errs,
+					expectSessions(Run{Repository: repo}, 2),
+					expectStoredChannels(Run{Repository: repo}, 0),
+				)...)
+			},
+		},
+		{
+			name: "an archived channel is placed by name among the archive",
+			channels: []fakediscord.Channel{
+				{ID: categoryGameID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryGame},
+				{ID: categoryArchiveID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryArchive, Position: 1},
+				fakeText(channelID, channelName, categoryGameID, 0),
+				fakeText(channelID+1, "a-game", categoryArchiveID, 0),
+				fakeText(channelID+2, "zelda", categoryArchiveID, 1),
+			},
+			setup: func(repo *activitytest.MemoryRepository) {
+				withOpenSession(Run{Repository: repo})
+			},
+			check: func(server *fakediscord.Server, _ *activitytest.MemoryRepository) error {
+				return errors.Join(
+					expectFakeParent(server, channelName, activity.CategoryArchive),
+					expectFakeOrder(server, categoryArchiveID, "a-game", channelName, "zelda"),
+				)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			opts := []fakediscord.Option{fakediscord.WithToken(fakeToken), fakediscord.WithRateLimitEvery(tt.rateLimitEvery)}
+			if tt.denied {
+				opts = append(opts, fakediscord.WithoutManageChannels())
+			}
+
+			server := fakediscord.New(opts...)
+			server.AddGuild(guildID, append(tt.channels, fakeText(adminChannelID, "admin", 0, 0))...)
+
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			restClient := newFakeRest(httpServer)
+
+			var client activity.ChannelClient = restClient
+			if tt.dryRun.Active() {
+				client = activity.NewPlanningClient(ctx, restClient, restClient, tt.dryRun, discardLogger())
+			}
+
+			repo := activitytest.NewMemoryRepository()
+			if tt.setup != nil {
+				tt.setup(repo)
+			}
+
+			handleFakePresence(ctx, client, repo, newAdminReporter(restClient), tt.games...)
+
+			// a second player of the games plans the same actions, and the plans are posted off the presences
+			if tt.dryRun.Active() {
+				handleUserPresence(ctx, client, repo, newAdminReporter(restClient), userID+100, tt.games...)
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			err := errors.Join(tt.check(server, repo), expectContiguous(server))
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func newFakeRest(httpServer *httptest.Server) rest.Rest {
+	logger := discardLogger()
+
+	return rest.New(rest.NewClient(
+		fakeToken,
+		rest.WithURL(httpServer.URL+fakediscord.APIPath),
+		rest.WithLogger(logger),
+		rest.WithRateLimiterConfigOpts(rest.WithRateLimiterLogger(logger)),
+	))
+}
+
+func newAdminReporter(restClient rest.Rest) *activity.Reporter {
+	return activity.NewReporter(restClient, map[snowflake.ID]snowflake.ID{guildID: adminChannelID}, discardLogger())
+}
+
+// handleFakePresence handles a presence of games from the default user and reports its error.
+func handleFakePresence(
+	ctx context.Context,
+	client activity.ChannelClient,
+	repo *activitytest.MemoryRepository,
+	reporter *activity.Reporter,
+	games ...string,
+) {
+	handleUserPresence(ctx, client, repo, reporter, userID, games...)
+}
+
+func handleUserPresence(
+	ctx context.Context,
+	client activity.ChannelClient,
+	repo *activitytest.MemoryRepository,
+	reporter *activity.Reporter,
+	user snowflake.ID,
+	games ...string,
+) {
+	activities := make([]discord.Activity, 0, len(games))
+
+	for _, name := range games {
+		activities = append(activities, discord.Activity{Name: name, Type: discord.ActivityTypeGame})
+	}
+
+	err := activity.HandlePresence(
+		ctx,
+		botID,
+		client,
+		repo,
+		&activitytest.RecordingPublisher{},
+		activity.DefaultCategories(),
+		activity.DefaultNamings(),
+		activity.NewMetrics(prometheus.NewRegistry()),
+		&events.PresenceUpdate{
+			EventPresenceUpdate: gateway.EventPresenceUpdate{
+				Presence: discord.Presence{
+					PresenceUser: discord.PresenceUser{ID: user},
+					GuildID:      guildID,
+					Activities:   activities,
+				},
+			},
+		},
+		time.Now(),
+		discardLogger(),
+	)
+	if err != nil {
+		reporter.Report(ctx, guildID, err)
+	}
+}
+
+func withRepositoryUsage(repo *activitytest.MemoryRepository) {
+	repo.Usage[game] = true
+}
+
+// fakeText is a text channel, at the top level when parentID is 0.
+func fakeText(id snowflake.ID, name string, parentID snowflake.ID, position int) fakediscord.Channel {
+	channel := fakediscord.Channel{ID: id, Type: discord.ChannelTypeGuildText, Name: name, Position: position}
+	if parentID != 0 {
+		channel.ParentID = &parentID
+	}
+
+	return channel
+}
+
+// fullCategory is both categories, the game one holding the maximum number of channels.
+func fullCategory() []fakediscord.Channel {
+	channels := []fakediscord.Channel{
+		{ID: categoryGameID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryGame},
+		{ID: categoryArchiveID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryArchive, Position: 1},
+	}
+
+	for i := range fakediscord.MaxCategoryChannels {
+		channels = append(channels, fakeText(channelID+snowflake.ID(i), "game-"+strconv.Itoa(i), categoryGameID, i))
+	}
+
+	return channels
+}
+
+func fakeFind(server *fakediscord.Server, name string) (fakediscord.Channel, bool) {
+	for _, channel := range server.Channels(guildID) {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+
+	return fakediscord.Channel{}, false
+}
+
+func expectFakeParent(server *fakediscord.Server, name string, category string) error {
+	channel, found := fakeFind(server, name)
+	if !found {
+		return oops.Errorf("expected channel %s, found none", name)
+	}
+
+	parent, found := fakeFind(server, category)
+	if !found || channel.ParentID == nil || *channel.ParentID != parent.ID {
+		return oops.Errorf("expected channel %s in category %s, got parent %v", name, category, channel.ParentID)
+	}
+
+	return nil
+}
+
+func expectFakeOrder(server *fakediscord.Server, parentID snowflake.ID, names ...string) error {
+	var got []string
+
+	for _, channel := range server.Channels(guildID) {
+		if channel.ParentID != nil && *channel.ParentID == parentID {
+			got = append(got, channel.Name)
+		}
+	}
+
+	if len(got) != len(names) {
+		return oops.Errorf("expected channels %v in %d, got %v", names, parentID, got)
+	}
+
+	for i := range names {
+		if got[i] != names[i] {
+			return oops.Errorf("expected channels %v in %d, got %v", names, parentID, got)
+		}
+	}
+
+	return nil
+}
+
+func expectStatus(server *fakediscord.Server, status int) error {
+	for _, request := range server.Requests() {
+		if request.Status == status {
+			return nil
+		}
+	}
+
+	return oops.Errorf("expected a %d response, got %v", status, server.Requests())
+}
+
+// expectContiguous checks the server kept the positions of every parent numbered from 0.
+func expectContiguous(server *fakediscord.Server) error {
+	next := map[snowflake.ID]int{}
+
+	for _, channel := range server.Channels(guildID) {
+		parent := snowflake.ID(0)
+		if channel.ParentID != nil {
+			parent = *channel.ParentID
+		}
+
+		if channel.Type == discord.ChannelTypeGuildCategory {
+			parent = 1
+		}
+
+		if channel.Position != next[parent] {
+			return oops.Errorf("expected position %d for %s, got %d", next[parent], channel.Name, channel.Position)
+		}
+
+		next[parent]++
+	}
+
+	return nil
+}
+
+// expectReport checks a single message containing text was posted to the admin channel.
+func expectReport(server *fakediscord.Server, text string) error {
+	messages := server.Messages(adminChannelID)
+	if len(messages) != 1 || !strings.Contains(messages[0].Content, text) {
+		return oops.Errorf("expected one admin message containing %q, got %v", text, messages)
+	}
+
+	return nil
+}
@@ -2,88 +2,253 @@ package activity
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
 	"github.com/disgoorg/disgo/rest"
 	disgojson "github.com/disgoorg/json"
 	"github.com/disgoorg/snowflake/v2"
-	"github.com/gosimple/slug"
 	"github.com/samber/oops"
+	"go.opentelemetry.io/otel/attribute"
+
+	"eggmech/autochannelactivity/stream"
+	"eggmech/host"
 )
 
-const categoryGame = "game"
-const categoryArchive = "game archive"
+// ChannelClient is the part of rest.Rest the handler uses.
+type ChannelClient interface {
+	GetGuildChannels(guildID snowflake.ID, opts ...rest.RequestOpt) ([]discord.GuildChannel, error)
+	CreateGuildChannel(
+		guildID snowflake.ID,
+		guildChannelCreate discord.GuildChannelCreate,
+		opts ...rest.RequestOpt,
+	) (discord.GuildChannel, error)
+	UpdateChannelPositions(
+		guildID snowflake.ID,
+		guildChannelPositionUpdates []discord.GuildChannelPositionUpdate,
+		opts ...rest.RequestOpt,
+	) error
+}
 
 func PresenceHandler(
 	ctx context.Context,
 	botID snowflake.ID,
-	client rest.Rest,
-	repo Repository,
+	client ChannelClient,
+	repo PresenceRepository,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
+	reporter *Reporter,
 	logger *slog.Logger,
 ) func(event *events.PresenceUpdate) {
-	insertActivityStatement, err := repo.InsertStatement(ctx)
-	if err != nil {
-		logger.ErrorContext(ctx, "failed to get insert statement", slog.Any("error", err))
+	handle := ReportingPresenceHandler(
+		ctx, botID, client, repo, publisher, categories, namings, metrics, reporter, logger,
+	)
 
-		return nil
+	return func(event *events.PresenceUpdate) {
+		_ = handle(event)
 	}
+}
 
-	closeActivityStatement, err := repo.CloseActivityStatement(ctx)
-	if err != nil {
-		logger.ErrorContext(ctx, "failed to get close activity statement", slog.Any("error", err))
+// ReportingPresenceHandler is PresenceHandler returning the error once reported, the workers redeliver the presences
+// failing with a Retryable one.
+func ReportingPresenceHandler(
+	ctx context.Context,
+	botID snowflake.ID,
+	client ChannelClient,
+	repo PresenceRepository,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
+	reporter *Reporter,
+	logger *slog.Logger,
+) func(event *events.PresenceUpdate) error {
+	return func(event *events.PresenceUpdate) error {
+		errHandler := HandlePresence(
+			ctx, botID, client, repo, publisher, categories, namings, metrics, event, time.Now(), logger,
+		)
+
+		if errHandler != nil {
+			reporter.Report(ctx, event.GuildID, errHandler)
+		}
 
-		return nil
+		return errHandler
 	}
+}
 
-	return func(event *events.PresenceUpdate) {
-		if botID == event.PresenceUser.ID {
-			return
-		}
+// HandlePresence handles one presence update as if it was received at now, the replay tool uses it
+// to keep the recorded timing.
+func HandlePresence(
+	ctx context.Context,
+	botID snowflake.ID,
+	client ChannelClient,
+	repo PresenceRepository,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
+	event *events.PresenceUpdate,
+	now time.Time,
+	logger *slog.Logger,
+) error {
+	start := time.Now()
+	ctx, span := startSpan(ctx, "aca.presence", event, "")
 
-		errHandler := handler(ctx, repo, insertActivityStatement, closeActivityStatement, client, event, logger)
+	outcome, err := handlePresence(
+		ctx, botID, client, repo, publisher, categories, namings, metrics, event, now, logger,
+	)
+	postPlan(client, event.GuildID)
+	metrics.observePresence(event.GuildID, outcome, start)
+	span.SetAttributes(attribute.String("aca.outcome", outcome))
+	host.EndSpan(span, err)
 
-		if err != nil {
-			logger.ErrorContext(ctx, "failed to run handler", slog.Any("error", errHandler))
+	return err
+}
 
-			return
-		}
+func handlePresence(
+	ctx context.Context,
+	botID snowflake.ID,
+	client ChannelClient,
+	repo PresenceRepository,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
+	event *events.PresenceUpdate,
+	now time.Time,
+	logger *slog.Logger,
+) (string, error) {
+	if botID == event.PresenceUser.ID {
+		return OutcomeSkippedBot, nil
+	}
+
+	optedOut, err := repo.IsOptedOut(ctx, event.PresenceUser.ID)
+	if err != nil {
+		return OutcomeFailed, databaseError(errorBuilder(event, "check opt-out"), err, "failed to get opt-out")
 	}
+
+	if optedOut {
+		return OutcomeSkippedOptedOut, nil
+	}
+
+	return handler(ctx, repo, client, publisher, categories, namings, metrics, event, now, logger)
 }
 
 func handler(
 	ctx context.Context,
-	repo Repository,
-	insertStatement *sql.Stmt,
-	closeActivityStatement *sql.Stmt,
-	client rest.Rest,
+	repo PresenceRepository,
+	client ChannelClient,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
 	event *events.PresenceUpdate,
+	now time.Time,
 	logger *slog.Logger,
-) error {
-	currentActivities, errRetrievingActivities := repo.GetCurrentActivitiesUUID(ctx, event)
+) (string, error) {
+	spanCtx, span := startSpan(ctx, "aca.fetch_current_activities", event, "")
+	currentActivities, errRetrievingActivities := repo.GetCurrentActivitiesUUID(spanCtx, event)
+	host.EndSpan(span, errRetrievingActivities)
 
 	if errRetrievingActivities != nil {
-		return oops.Wrapf(errRetrievingActivities, "failed to get current activities")
+		return OutcomeFailed, databaseError(
+			errorBuilder(event, "fetch current activities"),
+			errRetrievingActivities,
+			"failed to get current activities",
+		)
 	}
 
-	processActivitiesToClose(ctx, client, closeActivityStatement, event, currentActivities, repo, logger)
-	processActivitiesToCreate(ctx, client, insertStatement, event, currentActivities, repo, logger)
+	activitiesToClose := findActivitiesToClose(event, currentActivities)
+	activitiesToCreate := findActivitiesToCreate(event, currentActivities)
 
-	return nil
+	if len(activitiesToClose) == 0 && len(activitiesToCreate) == 0 {
+		return OutcomeSkippedUnchanged, nil
+	}
+
+	spanCtx, span = startSpan(ctx, "aca.fetch_ignored_channels", event, "")
+	ignored, errIgnored := repo.IgnoredChannels(spanCtx, event.GuildID)
+	host.EndSpan(span, errIgnored)
+
+	if errIgnored != nil {
+		return OutcomeFailed, databaseError(
+			errorBuilder(event, "fetch ignored channels"),
+			errIgnored,
+			"failed to get ignored channels",
+		)
+	}
+
+	activitiesToCreate = slices.DeleteFunc(activitiesToCreate, func(activity discord.Activity) bool {
+		return ignored[ActivityKey(activity.Name)] == DeletionBlocklist
+	})
+
+	if len(activitiesToClose) == 0 && len(activitiesToCreate) == 0 {
+		return OutcomeSkippedIgnored, nil
+	}
+
+	createdActivities, errApply := repo.ApplyPresence(ctx, event, activitiesToClose, activitiesToCreate, now)
+
+	if errApply != nil {
+		return OutcomeFailed, databaseError(errorBuilder(event, "apply presence"), errApply, "failed to apply presence")
+	}
+
+	metrics.sessionsChanged(event.GuildID, len(createdActivities), len(activitiesToClose))
+	publishSessions(ctx, publisher, event, activitiesToClose, createdActivities, now, logger)
+
+	err := errors.Join(
+		processActivitiesToClose(
+			ctx, client, publisher, categories, namings, metrics, event, activitiesToClose, repo, ignored, logger,
+		),
+		processActivitiesToCreate(
+			ctx, client, publisher, categories, namings, metrics, event, createdActivities, repo, ignored, logger,
+		),
+	)
+	if errors.Is(err, ErrMissingManageChannels) {
+		return OutcomeSkippedMisconfigured, err
+	}
+
+	if err != nil {
+		return OutcomeFailed, err
+	}
+
+	return OutcomeProcessed, nil
 }
 
-func processActivitiesToClose(
+func publishSessions(
 	ctx context.Context,
-	client rest.Rest,
-	closeActivityStatement *sql.Stmt,
+	publisher stream.Publisher,
 	event *events.PresenceUpdate,
-	currentActivities []CurrentActivity,
-	repo Repository,
+	closedActivities []CurrentActivity,
+	createdActivities []discord.Activity,
+	now time.Time,
 	logger *slog.Logger,
 ) {
+	for _, activity := range closedActivities {
+		stream.Publish(ctx, publisher, event.GuildID, stream.SessionEnded{
+			UserID:       event.PresenceUser.ID,
+			ActivityName: activity.Name,
+			EndedAt:      now.UTC(),
+		}, now, logger)
+	}
+
+	for _, activity := range createdActivities {
+		stream.Publish(ctx, publisher, event.GuildID, stream.SessionStarted{
+			UserID:       event.PresenceUser.ID,
+			ActivityName: activity.Name,
+			StartedAt:    sessionStart(activity, now).UTC(),
+		}, now, logger)
+	}
+}
+
+func findActivitiesToClose(event *events.PresenceUpdate, currentActivities []CurrentActivity) []CurrentActivity {
+	var activitiesToClose []CurrentActivity
+
 	for _, currentActivity := range currentActivities {
 		foundInActivity := false
 
@@ -100,33 +265,15 @@ func processActivitiesToClose(
 		}
 
 		if !foundInActivity {
-			_, err := closeActivityStatement.ExecContext(ctx, currentActivity.UUID)
-			if err != nil {
-				logger.ErrorContext(ctx, "failed to close activity", slog.Any("error", oops.Wrap(err)))
-			}
-
-			processActivity(
-				ctx,
-				client,
-				event,
-				currentActivity,
-				repo,
-				logger,
-			)
+			activitiesToClose = append(activitiesToClose, currentActivity)
 		}
 	}
+
+	return activitiesToClose
 }
 
-func processActivitiesToCreate(
-	ctx context.Context,
-	client rest.Rest,
-	insertStatement *sql.Stmt,
-	event *events.PresenceUpdate,
-	currentActivities []CurrentActivity,
-	repo Repository,
-	logger *slog.Logger,
-) {
-	_ = insertStatement
+func findActivitiesToCreate(event *events.PresenceUpdate, currentActivities []CurrentActivity) []discord.Activity {
+	var activitiesToCreate []discord.Activity
 
 	for _, eventActivity := range event.Activities {
 		if eventActivity.Type != discord.ActivityTypeGame {
@@ -144,68 +291,155 @@ func processActivitiesToCreate(
 		}
 
 		if !foundInDatabase {
-			err := repo.InsertActivity(ctx, event, eventActivity)
+			activitiesToCreate = append(activitiesToCreate, eventActivity)
+		}
+	}
 
-			if err != nil {
-				logger.ErrorContext(ctx, "failed to insert activity", slog.Any("error", oops.Wrap(err)))
+	return activitiesToCreate
+}
 
-				return
-			}
+func processActivitiesToClose(
+	ctx context.Context,
+	client ChannelClient,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
+	event *events.PresenceUpdate,
+	activitiesToClose []CurrentActivity,
+	repo PresenceRepository,
+	ignored map[string]DeletionPolicy,
+	logger *slog.Logger,
+) error {
+	var errs []error
+
+	for _, activity := range activitiesToClose {
+		errs = append(errs, processActivity(
+			ctx,
+			client,
+			publisher,
+			categories,
+			namings,
+			metrics,
+			event,
+			activity,
+			repo,
+			ignored,
+			logger,
+		))
+	}
 
-			processActivity(
-				ctx,
-				client,
-				event,
-				CurrentActivity{
-					UUID: "",
-					Name: eventActivity.Name,
-				},
-				repo,
-				logger,
-			)
-		}
+	return errors.Join(errs...)
+}
+
+func processActivitiesToCreate(
+	ctx context.Context,
+	client ChannelClient,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
+	event *events.PresenceUpdate,
+	activitiesToCreate []discord.Activity,
+	repo PresenceRepository,
+	ignored map[string]DeletionPolicy,
+	logger *slog.Logger,
+) error {
+	var errs []error
+
+	for _, activity := range activitiesToCreate {
+		errs = append(errs, processActivity(
+			ctx,
+			client,
+			publisher,
+			categories,
+			namings,
+			metrics,
+			event,
+			CurrentActivity{
+				UUID: "",
+				Name: activity.Name,
+			},
+			repo,
+			ignored,
+			logger,
+		))
 	}
+
+	return errors.Join(errs...)
 }
 
 func processActivity(
 	ctx context.Context,
-	client rest.Rest,
+	client ChannelClient,
+	publisher stream.Publisher,
+	categories Categories,
+	namings Namings,
+	metrics *Metrics,
 	event *events.PresenceUpdate,
 	activity CurrentActivity,
-	repo Repository,
+	repo PresenceRepository,
+	ignored map[string]DeletionPolicy,
 	logger *slog.Logger,
-) {
-	hasEnoughActivityUsage, err := repo.HasEnoughActivityUsage(ctx, activity.Name)
-	if err != nil {
-		logger.ErrorContext(ctx, "failed to get game usage", slog.Any("error", oops.Wrap(err)))
+) (err error) {
+	ctx, span := startSpan(ctx, "aca.process_activity", event, activity.Name)
+	defer func() { host.EndSpan(span, err) }()
+
+	key := ActivityKey(activity.Name)
 
-		return
+	if policy, found := ignored[key]; found {
+		span.SetAttributes(attribute.String("aca.ignored", string(policy)))
+
+		return nil
 	}
 
-	channels, err := client.GetGuildChannels(event.GuildID)
-	if err != nil {
-		logger.ErrorContext(ctx, "failed to get channels", slog.Any("error", oops.Wrap(err)))
+	builder := errorBuilder(event, "").With("activity_name", activity.Name)
+
+	spanCtx, usageSpan := startSpan(ctx, "aca.usage_check", event, activity.Name)
+	hasEnoughActivityUsage, err := repo.HasEnoughActivityUsage(spanCtx, event.GuildID, activity.Name)
+	host.EndSpan(usageSpan, err)
 
-		return
+	if err != nil {
+		return databaseError(builder.Tags("check usage"), err, "failed to get game usage")
 	}
 
-	name := slug.Make(activity.Name)
-	channelID, categoryGameID, categoryArchiveID := findChannelsID(name, channels)
+	_, channelsSpan := startSpan(ctx, "aca.fetch_channels", event, activity.Name)
+	channels, err := client.GetGuildChannels(event.GuildID)
+	host.EndSpan(channelsSpan, err)
+
+	if err != nil {
+		return discordError(builder.Tags("fetch channels"), err, "failed to get channels")
+	}
 
-	categoryGameID, err = createCategory(categoryGame, categoryGameID, client, event)
+	spanCtx, trackedSpan := startSpan(ctx, "aca.fetch_tracked_channels", event, activity.Name)
+	tracked, err := repo.GuildTrackedChannels(spanCtx, event.GuildID)
+	host.EndSpan(trackedSpan, err)
 
 	if err != nil {
-		logger.ErrorContext(ctx, "cannot create category game", slog.Any("error", oops.Wrap(err)))
+		return databaseError(builder.Tags("fetch tracked channels"), err, "failed to get tracked channels")
+	}
+
+	name, channelID, manual := resolveChannel(namings.Guild(event.GuildID), key, activity.Name, tracked, channels)
+	if manual {
+		span.SetAttributes(attribute.Bool("aca.manually_placed", true))
 
-		return
+		return nil
 	}
 
-	categoryArchiveID, err = createCategory(categoryArchive, categoryArchiveID, client, event)
+	categoryGameID, categoryArchiveID := findCategoriesID(categories, channels)
+
+	dryRun := isDryRun(client, event.GuildID)
 
+	categoryGameID, err = createCategory(ctx, builder, categories.Game, categoryGameID, client, event, dryRun, metrics)
 	if err != nil {
-		logger.ErrorContext(ctx, "cannot create category archive", slog.Any("error", oops.Wrap(err)))
+		return err
+	}
 
-		return
+	categoryArchiveID, err = createCategory(
+		ctx, builder, categories.Archive, categoryArchiveID, client, event, dryRun, metrics,
+	)
+	if err != nil {
+		return err
 	}
 
 	moveToCategory := categoryGameID
@@ -215,69 +449,179 @@ func processActivity(
 	}
 
 	channelPosition := findPosition(name, moveToCategory, channels)
-	if activity.UUID == "" {
-		channelID, err = createChannel(ctx, repo, name, channelID, channelPosition, moveToCategory, client, event)
+	moved := channelID != 0 && !isInCategory(channelID, moveToCategory, channels)
 
+	if activity.UUID == "" {
+		var created bool
+
+		channelID, created, err = createChannel(
+			ctx,
+			builder.With("category_id", moveToCategory),
+			repo,
+			TrackedChannel{
+				GuildID:      event.GuildID,
+				ChannelID:    channelID,
+				ActivityName: key,
+				GameName:     activity.Name,
+				Name:         name,
+			},
+			channelPosition,
+			moveToCategory,
+			client,
+			event,
+		)
 		if err != nil {
-			logger.ErrorContext(ctx, "cannot create channel", slog.Any("error", oops.Wrap(err)))
+			return err
+		}
 
-			return
+		if created {
+			metrics.channelChanged(event.GuildID, "create_channel")
+			stream.Publish(ctx, publisher, event.GuildID, stream.ChannelCreated{
+				ChannelID:    channelID,
+				ChannelName:  name,
+				CategoryID:   moveToCategory,
+				ActivityName: activity.Name,
+			}, time.Now(), logger)
 		}
 	}
 
-	var channelsToUpdate []discord.GuildChannelPositionUpdate
+	archived := moveToCategory == categoryArchiveID && !isInCategory(channelID, categoryArchiveID, channels)
+
+	err = moveChannel(ctx, client, event, activity.Name, channelID, channelPosition, moveToCategory, channels)
+	if err != nil {
+		return discordError(
+			builder.Tags("update channel positions").With("channel_id", channelID, "category_id", moveToCategory),
+			err,
+			"cannot update channel positions",
+		)
+	}
+
+	if moved && !dryRun {
+		metrics.channelChanged(event.GuildID, "move")
+	}
+
+	if archived && channelID != 0 && !dryRun {
+		metrics.channelChanged(event.GuildID, "archive")
+		stream.Publish(ctx, publisher, event.GuildID, stream.ChannelArchived{
+			ChannelID:    channelID,
+			CategoryID:   categoryArchiveID,
+			ActivityName: activity.Name,
+		}, time.Now(), logger)
+	}
+
+	return nil
+}
+
+// moveChannel puts channelID at channelPosition in category and shifts the channels below it.
+func moveChannel(
+	ctx context.Context,
+	client ChannelClient,
+	event *events.PresenceUpdate,
+	game string,
+	channelID snowflake.ID,
+	channelPosition int,
+	category snowflake.ID,
+	channels []discord.GuildChannel,
+) error {
+	_, span := startSpan(ctx, "aca.update_positions", event, game)
 
-	channelsToUpdate = append(channelsToUpdate, discord.GuildChannelPositionUpdate{
+	channelsToUpdate := []discord.GuildChannelPositionUpdate{{
 		ID:       channelID,
-		ParentID: &moveToCategory,
+		ParentID: &category,
 		Position: disgojson.NewNullablePtr(channelPosition),
-	})
+	}}
 
-	channelsToUpdate = append(
-		channelsToUpdate,
-		calculateNewChannelPosition(channelPosition, moveToCategory, channels)...,
-	)
+	channelsToUpdate = append(channelsToUpdate, calculateNewChannelPosition(channelPosition, category, channels)...)
+	span.SetAttributes(attribute.Int("aca.updates", len(channelsToUpdate)))
 
-	err = client.UpdateChannelPositions(event.GuildID, channelsToUpdate)
+	err := client.UpdateChannelPositions(event.GuildID, channelsToUpdate)
+	host.EndSpan(span, err)
 
-	if err != nil {
-		logger.WarnContext(ctx, "cannot update channel positions", slog.Any("error", oops.Wrap(err)))
+	return err
+}
 
-		return
+func isInCategory(channelID snowflake.ID, category snowflake.ID, channels []discord.GuildChannel) bool {
+	for _, channel := range channels {
+		if channel.ID() == channelID {
+			return channel.ParentID() != nil && *channel.ParentID() == category
+		}
 	}
+
+	return false
 }
 
-func findChannelsID(name string, channels []discord.GuildChannel) (snowflake.ID, snowflake.ID, snowflake.ID) {
-	var channelID snowflake.ID
+// resolveChannel finds the name and the channel of game, channelID is 0 when the channel is still to be made.
+// The channel tracked for key is kept whatever its name, otherwise the channel named by naming is adopted unless
+// it is tracked for another game, then the collision name of game is used instead.
+func resolveChannel(
+	naming Naming,
+	key string,
+	game string,
+	tracked []TrackedChannel,
+	channels []discord.GuildChannel,
+) (string, snowflake.ID, bool) {
+	for _, trackedChannel := range tracked {
+		if trackedChannel.ActivityName != key {
+			continue
+		}
 
-	var categoryArchiveID snowflake.ID
+		for _, channel := range channels {
+			if channel.ID() == trackedChannel.ChannelID {
+				return channel.Name(), channel.ID(), trackedChannel.ManuallyPlaced
+			}
+		}
+	}
 
-	var categoryGameID snowflake.ID
+	name := naming.ChannelName(game)
+	channelID := findChannelID(name, channels)
+
+	if channelID != 0 && slices.ContainsFunc(tracked, func(trackedChannel TrackedChannel) bool {
+		return trackedChannel.ChannelID == channelID
+	}) {
+		name = naming.CollisionName(game)
+		channelID = findChannelID(name, channels)
+	}
+
+	return name, channelID, false
+}
+
+func findChannelID(name string, channels []discord.GuildChannel) snowflake.ID {
+	var channelID snowflake.ID
 
 	for _, channel := range channels {
 		if channel.Name() == name {
 			channelID = channel.ID()
 		}
+	}
+
+	return channelID
+}
+
+func findCategoriesID(categories Categories, channels []discord.GuildChannel) (snowflake.ID, snowflake.ID) {
+	var categoryArchiveID snowflake.ID
 
+	var categoryGameID snowflake.ID
+
+	for _, channel := range channels {
 		if channel.Type() != discord.ChannelTypeGuildCategory {
 			continue
 		}
 
 		channelName := strings.ToLower(channel.Name())
-		if channelName == categoryArchive {
+		if channelName == strings.ToLower(categories.Archive) {
 			categoryArchiveID = channel.ID()
 		}
 
-		if channelName == categoryGame {
+		if channelName == strings.ToLower(categories.Game) {
 			categoryGameID = channel.ID()
 		}
 
-		if channelID > 0 && categoryArchiveID > 0 && categoryGameID > 0 {
+		if categoryArchiveID > 0 && categoryGameID > 0 {
 			break
 		}
 	}
 
-	return channelID, categoryGameID, categoryArchiveID
+	return categoryGameID, categoryArchiveID
 }
 
 func findPosition(name string, category snowflake.ID, channels []discord.GuildChannel) int {
@@ -324,21 +668,33 @@ func calculateNewChannelPosition(
 }
 
 func createCategory(
+	ctx context.Context,
+	builder oops.OopsErrorBuilder,
 	categoryName string,
 	category snowflake.ID,
-	client rest.Rest,
+	client ChannelClient,
 	event *events.PresenceUpdate,
+	dryRun bool,
+	metrics *Metrics,
 ) (snowflake.ID, error) {
 	if category != 0 {
 		return category, nil
 	}
 
+	_, span := startSpan(ctx, "aca.create_category", event, "")
+	span.SetAttributes(attribute.String("aca.category", categoryName))
+
 	channel, err := client.CreateGuildChannel(event.GuildID, discord.GuildCategoryChannelCreate{
 		Name: categoryName,
 	})
+	host.EndSpan(span, err)
 
 	if err != nil {
-		return 0, oops.Wrapf(err, "cannot create category")
+		return 0, discordError(builder.Tags("create category").With("category", categoryName), err, "cannot create category")
+	}
+
+	if !dryRun {
+		metrics.channelChanged(event.GuildID, "create_category")
 	}
 
 	return channel.ID(), nil
@@ -346,37 +702,48 @@ func createCategory(
 
 func createChannel(
 	ctx context.Context,
-	repo Repository,
-	channelName string,
-	channel snowflake.ID,
+	builder oops.OopsErrorBuilder,
+	repo PresenceRepository,
+	tracked TrackedChannel,
 	channelPosition int,
 	category snowflake.ID,
-	client rest.Rest,
+	client ChannelClient,
 	event *events.PresenceUpdate,
-) (snowflake.ID, error) {
-	if channel != 0 {
-		return channel, nil
+) (snowflake.ID, bool, error) {
+	if tracked.ChannelID != 0 {
+		return tracked.ChannelID, false, nil
 	}
 
 	if category == 0 {
-		return channel, nil
+		return tracked.ChannelID, false, nil
 	}
 
+	_, span := startSpan(ctx, "aca.create_channel", event, tracked.GameName)
 	guildChannel, err := client.CreateGuildChannel(event.GuildID, discord.GuildTextChannelCreate{
-		Name:     channelName,
+		Name:     tracked.Name,
 		ParentID: category,
 		Position: channelPosition,
 	})
+	host.EndSpan(span, err)
 
 	if err != nil {
-		return 0, oops.Wrapf(err, "cannot create channel")
+		return 0, false, discordError(builder.Tags("create channel"), err, "cannot create channel")
+	}
+
+	if isDryRun(client, event.GuildID) {
+		return guildChannel.ID(), false, nil
 	}
 
-	errCreateChannel := repo.CreateChannel(ctx, event.GuildID, guildChannel.ID(), channelName)
+	tracked.ChannelID = guildChannel.ID()
+	errCreateChannel := repo.CreateChannel(ctx, tracked)
 
 	if errCreateChannel != nil {
-		return 0, oops.Wrapf(errCreateChannel, "cannot create channel in db")
+		return 0, false, databaseError(
+			builder.Tags("store channel").With("channel_id", guildChannel.ID()),
+			errCreateChannel,
+			"cannot create channel in db",
+		)
 	}
 
-	return guildChannel.ID(), nil
+	return guildChannel.ID(), true, nil
 }
@@ -0,0 +1,278 @@
+package activity_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+	"eggmech/autochannelactivity/stream"
+)
+
+// TestPresenceHandler covers the create, archive and promote paths of the handler and its error paths. Each test
+// handles one presence of games, from userID or the default user, on top of the channels of the guild, named by
+// naming or by the default naming when it is left out.
+func TestPresenceHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []activitytest.Channel
+		setup    func(run Run)
+		userID   snowflake.ID
+		games    []string
+		naming   activity.Naming
+		check    func(run Run) error
+	}{
+		{
+			name:  "create: a played game gets its channel in the game category",
+			setup: withUsage,
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(
+					expectParent(run, activity.CategoryGame),
+					expectStoredChannels(run, 1),
+					expectEvents(run, stream.SubjectSessionStarted, stream.SubjectChannelCreated),
+				)
+			},
+		},
+		{
+			name:  "create: a barely played game gets its channel in the archive category",
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(
+					expectParent(run, activity.CategoryArchive),
+					expectStoredChannels(run, 1),
+				)
+			},
+		},
+		{
+			name:     "archive: closing a barely played game moves its channel to the archive",
+			channels: guildChannels(categoryGameID),
+			setup:    withOpenSession,
+			check: func(run Run) error {
+				return errors.Join(
+					expectParent(run, activity.CategoryArchive),
+					expectCalls(run, "CreateGuildChannel", 0),
+					expectEvents(run, stream.SubjectSessionEnded, stream.SubjectChannelArchived),
+				)
+			},
+		},
+		{
+			name:     "promote: playing an archived game moves its channel back to the game category",
+			channels: guildChannels(categoryArchiveID),
+			setup:    withUsage,
+			games:    []string{game},
+			check: func(run Run) error {
+				return errors.Join(
+					expectParent(run, activity.CategoryGame),
+					expectCalls(run, "CreateGuildChannel", 0),
+					expectStoredChannels(run, 0),
+				)
+			},
+		},
+		{
+			name:   "the bot own presence is ignored",
+			userID: botID,
+			games:  []string{game},
+			check:  expectUntouched,
+		},
+		{
+			name:  "an opted out user is ignored",
+			setup: func(run Run) { run.Repository.OptedOut[userID] = true },
+			games: []string{game},
+			check: expectUntouched,
+		},
+		{
+			name:  "deleted: an untracked game keeps its sessions without a channel",
+			setup: withIgnored(activity.DeletionUntrack),
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(
+					expectSessions(run, 1),
+					expectCalls(run, "GetGuildChannels", 0),
+					expectStoredChannels(run, 0),
+				)
+			},
+		},
+		{
+			name:  "deleted: a blocklisted game records no session",
+			setup: withIgnored(activity.DeletionBlocklist),
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(expectUntouched(run), expectSessions(run, 0))
+			},
+		},
+		{
+			name: "manual: a channel moved by hand is left alone",
+			channels: append(
+				guildChannels(loungeID),
+				activitytest.Channel{ID: loungeID, Type: discord.ChannelTypeGuildCategory},
+			),
+			setup: func(run Run) {
+				withOpenSession(run)
+				run.Repository.Channels = []activitytest.StoredChannel{
+					{GuildID: guildID, ChannelID: channelID, ActivityName: channelName, PlacedAt: time.Now()},
+				}
+			},
+			check: func(run Run) error {
+				return errors.Join(
+					expectCalls(run, "UpdateChannelPositions", 0),
+					expectEvents(run, stream.SubjectSessionEnded),
+				)
+			},
+		},
+		{
+			name:   "naming: the template of the guild names the channel, cut to its max length",
+			setup:  withUsage,
+			games:  []string{game},
+			naming: activity.Naming{Template: "🎮-{slug}-lfg", Language: "en", MaxLength: 11},
+			check: func(run Run) error {
+				return errors.Join(expectChannel(run, "🎮-dota-lfg", game), expectStoredChannels(run, 1))
+			},
+		},
+		{
+			name:     "naming: a channel renamed since is kept whatever its name",
+			channels: guildChannels(categoryArchiveID),
+			setup: func(run Run) {
+				withUsage(run)
+				run.Repository.Channels = []activitytest.StoredChannel{
+					{GuildID: guildID, ChannelID: channelID, ActivityName: channelName},
+				}
+			},
+			games:  []string{game},
+			naming: activity.Naming{Template: "{slug}-lfg", Language: "en", MaxLength: activity.MaxChannelName},
+			check: func(run Run) error {
+				return errors.Join(
+					expectParent(run, activity.CategoryGame),
+					expectCalls(run, "CreateGuildChannel", 0),
+				)
+			},
+		},
+		{
+			name:     "naming: a name tracked for another game gets the collision name",
+			channels: guildChannels(categoryGameID),
+			setup: func(run Run) {
+				run.Repository.Usage[otherGame] = true
+				run.Repository.Channels = []activitytest.StoredChannel{
+					{GuildID: guildID, ChannelID: channelID, ActivityName: "other"},
+				}
+			},
+			games: []string{otherGame},
+			check: func(run Run) error {
+				return errors.Join(
+					expectChannel(run, activity.DefaultNaming().CollisionName(otherGame), otherGame),
+					expectStoredChannels(run, 2),
+				)
+			},
+		},
+		{
+			name:  "error: a failing usage lookup leaves Discord untouched",
+			setup: withRepositoryError("HasEnoughActivityUsage"),
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(expectCalls(run, "GetGuildChannels", 0), expectSessions(run, 1))
+			},
+		},
+		{
+			name:  "error: a failing channel listing creates nothing",
+			setup: withChannelsError("GetGuildChannels"),
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(expectCalls(run, "CreateGuildChannel", 0), expectStoredChannels(run, 0))
+			},
+		},
+		{
+			name:  "error: a failing channel creation stores nothing",
+			setup: withChannelsError("CreateGuildChannel"),
+			games: []string{game},
+			check: func(run Run) error {
+				return errors.Join(expectStoredChannels(run, 0), expectEvents(run, stream.SubjectSessionStarted))
+			},
+		},
+		{
+			name:     "error: a failing move does not announce the archive",
+			channels: guildChannels(categoryGameID),
+			setup: func(run Run) {
+				withOpenSession(run)
+				withChannelsError("UpdateChannelPositions")(run)
+			},
+			check: func(run Run) error {
+				return errors.Join(
+					expectParent(run, activity.CategoryGame),
+					expectEvents(run, stream.SubjectSessionEnded),
+				)
+			},
+		},
+		{
+			name:  "error: a failing session write stops before Discord",
+			setup: withRepositoryError("ApplyPresence"),
+			games: []string{game},
+			check: expectUntouched,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := Run{
+				Repository: activitytest.NewMemoryRepository(),
+				Channels:   activitytest.NewRecordingChannels(tt.channels...),
+				Publisher:  &activitytest.RecordingPublisher{},
+			}
+
+			if tt.setup != nil {
+				tt.setup(run)
+			}
+
+			presenceUserID := tt.userID
+			if presenceUserID == 0 {
+				presenceUserID = userID
+			}
+
+			activities := make([]discord.Activity, 0, len(tt.games))
+
+			for _, name := range tt.games {
+				activities = append(activities, discord.Activity{Name: name, Type: discord.ActivityTypeGame})
+			}
+
+			namings := activity.DefaultNamings()
+			if tt.naming != (activity.Naming{}) {
+				namings.Guilds = map[snowflake.ID]activity.Naming{guildID: tt.naming}
+			}
+
+			handler := activity.PresenceHandler(
+				context.Background(),
+				botID,
+				run.Channels,
+				run.Repository,
+				run.Publisher,
+				activity.DefaultCategories(),
+				namings,
+				activity.NewMetrics(prometheus.NewRegistry()),
+				activity.NewReporter(nil, nil, discardLogger()),
+				discardLogger(),
+			)
+
+			handler(&events.PresenceUpdate{
+				EventPresenceUpdate: gateway.EventPresenceUpdate{
+					Presence: discord.Presence{
+						PresenceUser: discord.PresenceUser{ID: presenceUserID},
+						GuildID:      guildID,
+						Activities:   activities,
+					},
+				},
+			})
+
+			err := tt.check(run)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
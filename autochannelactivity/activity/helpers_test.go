@@ -0,0 +1,190 @@
+package activity_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+)
+
+const (
+	guildID           = snowflake.ID(1)
+	userID            = snowflake.ID(2)
+	botID             = snowflake.ID(3)
+	categoryGameID    = snowflake.ID(10)
+	categoryArchiveID = snowflake.ID(11)
+	loungeID          = snowflake.ID(12)
+	channelID         = snowflake.ID(20)
+	game              = "Dota 2"
+	channelName       = "dota-2"
+	otherGame         = "DOTA 2"
+)
+
+// Run is what a scenario checks once the presence was handled.
+type Run struct {
+	Repository *activitytest.MemoryRepository
+	Channels   *activitytest.RecordingChannels
+	Publisher  *activitytest.RecordingPublisher
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// guildChannels are both categories and the game channel in parentID.
+func guildChannels(parentID snowflake.ID) []activitytest.Channel {
+	return []activitytest.Channel{
+		{ID: categoryGameID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryGame},
+		{ID: categoryArchiveID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryArchive},
+		{ID: channelID, Type: discord.ChannelTypeGuildText, Name: channelName, ParentID: parentID},
+	}
+}
+
+func withUsage(run Run) {
+	run.Repository.Usage[game] = true
+}
+
+func withOpenSession(run Run) {
+	run.Repository.Sessions = append(run.Repository.Sessions, activitytest.Session{
+		UUID:    "open",
+		GuildID: guildID,
+		UserID:  userID,
+		Name:    game,
+	})
+}
+
+func withIgnored(policy activity.DeletionPolicy) func(run Run) {
+	return func(run Run) {
+		withUsage(run)
+		run.Repository.Ignored[guildID] = map[string]activity.DeletionPolicy{channelName: policy}
+	}
+}
+
+func withRepositoryError(method string) func(run Run) {
+	return func(run Run) {
+		run.Repository.Errors[method] = oops.Errorf("%s failed", method)
+	}
+}
+
+func withChannelsError(method string) func(run Run) {
+	return func(run Run) {
+		run.Channels.Errors[method] = oops.Errorf("%s failed", method)
+	}
+}
+
+func expectParent(run Run, category string) error {
+	channel, found := run.Channels.Find(channelName)
+	if !found {
+		return oops.Errorf("expected channel %s, found none", channelName)
+	}
+
+	parent, found := run.Channels.Find(category)
+	if !found || channel.ParentID != parent.ID {
+		return oops.Errorf("expected channel %s in category %s, got parent %d", channelName, category, channel.ParentID)
+	}
+
+	return nil
+}
+
+// expectChannel checks the channel of game was made and stored as name in the game category.
+func expectChannel(run Run, name string, game string) error {
+	channel, found := run.Channels.Find(name)
+	parent, _ := run.Channels.Find(activity.CategoryGame)
+
+	if !found || channel.ParentID != parent.ID {
+		return oops.Errorf("expected channel %s in the game category, got %v", name, run.Channels.Channels)
+	}
+
+	for _, stored := range run.Repository.Channels {
+		if stored.ChannelID == channel.ID && stored.Name == name && stored.GameName == game {
+			return nil
+		}
+	}
+
+	return oops.Errorf("expected channel %s stored for %s, got %v", name, game, run.Repository.Channels)
+}
+
+func expectCalls(run Run, method string, count int) error {
+	if calls := run.Channels.Count(method); calls != count {
+		return oops.Errorf("expected %d %s calls, got %d", count, method, calls)
+	}
+
+	return nil
+}
+
+func expectStoredChannels(run Run, count int) error {
+	if len(run.Repository.Channels) != count {
+		return oops.Errorf("expected %d stored channels, got %v", count, run.Repository.Channels)
+	}
+
+	return nil
+}
+
+func expectSessions(run Run, count int) error {
+	if len(run.Repository.Sessions) != count {
+		return oops.Errorf("expected %d sessions, got %v", count, run.Repository.Sessions)
+	}
+
+	return nil
+}
+
+func expectEvents(run Run, subjects ...string) error {
+	if types := run.Publisher.Types(); !slices.Equal(types, subjects) {
+		return oops.Errorf("expected events %v, got %v", subjects, types)
+	}
+
+	return nil
+}
+
+func expectUntouched(run Run) error {
+	if len(run.Channels.Calls) != 0 || len(run.Publisher.Envelopes) != 0 {
+		return oops.Errorf("expected no call and no event, got %v and %v", run.Channels.Calls, run.Publisher.Types())
+	}
+
+	return nil
+}
+
+func expectTracked(repo *activitytest.MemoryRepository, channelIDs ...snowflake.ID) error {
+	tracked, err := repo.TrackedChannels(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if len(tracked) != len(channelIDs) {
+		return oops.Errorf("expected tracked channels %v, got %v", channelIDs, tracked)
+	}
+
+	for i, channel := range tracked {
+		if channel.ChannelID != channelIDs[i] || channel.GuildID == 0 {
+			return oops.Errorf("expected tracked channels %v, got %v", channelIDs, tracked)
+		}
+	}
+
+	return nil
+}
+
+func expectIgnored(repo *activitytest.MemoryRepository, guildID snowflake.ID, names ...string) error {
+	ignored, err := repo.IgnoredChannels(context.Background(), guildID)
+	if err != nil {
+		return err
+	}
+
+	if len(ignored) != len(names) {
+		return oops.Errorf("expected ignored channels %v in %d, got %v", names, guildID, ignored)
+	}
+
+	for _, name := range names {
+		if ignored[name] != activity.DeletionUntrack {
+			return oops.Errorf("expected %s untracked in %d, got %v", name, guildID, ignored)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package activity
+
+import (
+	"context"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of a presence event, the skipped ones change nothing on Discord.
+const (
+	OutcomeProcessed            = "processed"
+	OutcomeSkippedBot           = "skipped_bot"
+	OutcomeSkippedOptedOut      = "skipped_opted_out"
+	OutcomeSkippedUnchanged     = "skipped_unchanged"
+	OutcomeSkippedIgnored       = "skipped_ignored"
+	OutcomeSkippedMisconfigured = "skipped_misconfigured"
+	OutcomeFailed               = "failed"
+)
+
+// Metrics are labelled per guild except the latencies, their buckets would multiply by the guilds.
+type Metrics struct {
+	presences       *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	sessions        *prometheus.CounterVec
+	channels        *prometheus.CounterVec
+	queryDuration   *prometheus.HistogramVec
+	misconfigured   *prometheus.GaugeVec
+}
+
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	factory := promauto.With(registerer)
+
+	return &Metrics{
+		presences: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "aca_presence_events_total",
+			Help: "Presence events by guild and outcome.",
+		}, []string{"guild_id", "outcome"}),
+		handlerDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "aca_presence_handler_duration_seconds",
+			Help:    "Time to handle a presence event, Discord calls included.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		sessions: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "aca_sessions_total",
+			Help: "Activity sessions opened and closed by guild.",
+		}, []string{"guild_id", "change"}),
+		channels: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "aca_channel_changes_total",
+			Help: "Categories and channels created, channels moved and archived by guild, dry runs excluded.",
+		}, []string{"guild_id", "change"}),
+		queryDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "aca_db_query_duration_seconds",
+			Help:    "Time spent in the repository by operation.",
+			Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1},
+		}, []string{"operation"}),
+		misconfigured: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "aca_guild_misconfigured",
+			Help: "1 while the channel changes of a guild are skipped for a missing permission.",
+		}, []string{"guild_id"}),
+	}
+}
+
+func (m *Metrics) observePresence(guildID snowflake.ID, outcome string, start time.Time) {
+	m.presences.WithLabelValues(guildID.String(), outcome).Inc()
+	m.handlerDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) sessionsChanged(guildID snowflake.ID, opened int, closed int) {
+	m.sessions.WithLabelValues(guildID.String(), "opened").Add(float64(opened))
+	m.sessions.WithLabelValues(guildID.String(), "closed").Add(float64(closed))
+}
+
+func (m *Metrics) channelChanged(guildID snowflake.ID, change string) {
+	m.channels.WithLabelValues(guildID.String(), change).Inc()
+}
+
+func (m *Metrics) misconfiguredGuild(guildID snowflake.ID, misconfigured bool) {
+	if misconfigured {
+		m.misconfigured.WithLabelValues(guildID.String()).Set(1)
+
+		return
+	}
+
+	m.misconfigured.DeleteLabelValues(guildID.String())
+}
+
+func (m *Metrics) observeQuery(operation string, start time.Time) {
+	m.queryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// MetricsRepository times every operation of Repository.
+type MetricsRepository struct {
+	Repository
+	Metrics *Metrics
+}
+
+func (r MetricsRepository) ApplyPresence(
+	ctx context.Context,
+	event *events.PresenceUpdate,
+	activitiesToClose []CurrentActivity,
+	activitiesToCreate []discord.Activity,
+	now time.Time,
+) ([]discord.Activity, error) {
+	defer r.Metrics.observeQuery("apply_presence", time.Now())
+
+	return r.Repository.ApplyPresence(ctx, event, activitiesToClose, activitiesToCreate, now)
+}
+
+func (r MetricsRepository) IsOptedOut(ctx context.Context, userID snowflake.ID) (bool, error) {
+	defer r.Metrics.observeQuery("is_opted_out", time.Now())
+
+	return r.Repository.IsOptedOut(ctx, userID)
+}
+
+func (r MetricsRepository) CreateChannel(ctx context.Context, channel TrackedChannel) error {
+	defer r.Metrics.observeQuery("create_channel", time.Now())
+
+	return r.Repository.CreateChannel(ctx, channel)
+}
+
+func (r MetricsRepository) GetCurrentActivitiesUUID(
+	ctx context.Context,
+	event *events.PresenceUpdate,
+) ([]CurrentActivity, error) {
+	defer r.Metrics.observeQuery("get_current_activities", time.Now())
+
+	return r.Repository.GetCurrentActivitiesUUID(ctx, event)
+}
+
+func (r MetricsRepository) HasEnoughActivityUsage(
+	ctx context.Context,
+	guildID snowflake.ID,
+	activityName string,
+) (bool, error) {
+	defer r.Metrics.observeQuery("has_enough_activity_usage", time.Now())
+
+	return r.Repository.HasEnoughActivityUsage(ctx, guildID, activityName)
+}
+
+func (r MetricsRepository) IgnoredChannels(
+	ctx context.Context,
+	guildID snowflake.ID,
+) (map[string]DeletionPolicy, error) {
+	defer r.Metrics.observeQuery("ignored_channels", time.Now())
+
+	return r.Repository.IgnoredChannels(ctx, guildID)
+}
+
+func (r MetricsRepository) TrackedChannels(ctx context.Context) ([]TrackedChannel, error) {
+	defer r.Metrics.observeQuery("tracked_channels", time.Now())
+
+	return r.Repository.TrackedChannels(ctx)
+}
+
+func (r MetricsRepository) DeleteChannel(
+	ctx context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	policy DeletionPolicy,
+	now time.Time,
+) ([]string, error) {
+	defer r.Metrics.observeQuery("delete_channel", time.Now())
+
+	return r.Repository.DeleteChannel(ctx, guildID, channelID, policy, now)
+}
+
+func (r MetricsRepository) GuildTrackedChannels(ctx context.Context, guildID snowflake.ID) ([]TrackedChannel, error) {
+	defer r.Metrics.observeQuery("guild_tracked_channels", time.Now())
+
+	return r.Repository.GuildTrackedChannels(ctx, guildID)
+}
+
+func (r MetricsRepository) SetManuallyPlaced(
+	ctx context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	placed bool,
+	now time.Time,
+) ([]string, error) {
+	defer r.Metrics.observeQuery("set_manually_placed", time.Now())
+
+	return r.Repository.SetManuallyPlaced(ctx, guildID, channelID, placed, now)
+}
+
+func (r MetricsRepository) RenameChannel(
+	ctx context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	name string,
+) error {
+	defer r.Metrics.observeQuery("rename_channel", time.Now())
+
+	return r.Repository.RenameChannel(ctx, guildID, channelID, name)
+}
+
+func (r MetricsRepository) AggregateActivities(ctx context.Context, before time.Time) (int64, error) {
+	defer r.Metrics.observeQuery("aggregate_activities", time.Now())
+
+	return r.Repository.AggregateActivities(ctx, before)
+}
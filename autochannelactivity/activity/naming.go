@@ -0,0 +1,114 @@
+package activity
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/gosimple/slug"
+)
+
+const (
+	// SlugPlaceholder is replaced by the slug of the game in a naming template.
+	SlugPlaceholder = "{slug}"
+	// MaxChannelName is the longest channel name Discord accepts.
+	MaxChannelName = 100
+)
+
+// ActivityKey is the key a game is stored, ignored and given its channel settings by, whatever the naming.
+func ActivityKey(game string) string {
+	return slug.Make(game)
+}
+
+// Naming makes the channel name of a game from Template, where SlugPlaceholder is the slug of the game in Language,
+// cut so the name fits in MaxLength characters. Unknown languages fall back to English.
+type Naming struct {
+	Template  string `yaml:"template"`
+	Language  string `yaml:"language"`
+	MaxLength int    `yaml:"max_length"`
+}
+
+func DefaultNaming() Naming {
+	return Naming{
+		Template:  SlugPlaceholder,
+		Language:  "en",
+		MaxLength: MaxChannelName,
+	}
+}
+
+// Validate checks the template gives names Discord keeps as they are, it lowercases text channel names
+// and replaces their spaces.
+func (n Naming) Validate() error {
+	switch {
+	case strings.Count(n.Template, SlugPlaceholder) != 1:
+		return fmt.Errorf("template must contain %s once, got %q", SlugPlaceholder, n.Template)
+	case strings.ToLower(n.Template) != n.Template || strings.ContainsFunc(n.Template, unicode.IsSpace):
+		return fmt.Errorf("template must be lowercase without spaces, got %q", n.Template)
+	case n.MaxLength < 1 || n.MaxLength > MaxChannelName:
+		return fmt.Errorf("max_length must be in [1, %d], got %d", MaxChannelName, n.MaxLength)
+	case utf8.RuneCountInString(n.collisionName("", "-0000")) >= n.MaxLength:
+		return fmt.Errorf("max_length %d leaves no room for the slug in %q", n.MaxLength, n.Template)
+	}
+
+	return nil
+}
+
+// ChannelName is the channel name of game.
+func (n Naming) ChannelName(game string) string {
+	return n.collisionName(slug.MakeLang(game, n.Language), "")
+}
+
+// CollisionName is the channel name of game once ChannelName is taken by the channel of another game,
+// the slug is suffixed with a hash of the game so the name is the same on every run.
+func (n Naming) CollisionName(game string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(game))
+
+	return n.collisionName(slug.MakeLang(game, n.Language), fmt.Sprintf("-%04x", hash.Sum32()&0xffff))
+}
+
+func (n Naming) collisionName(gameSlug string, suffix string) string {
+	prefix, rest, _ := strings.Cut(n.Template, SlugPlaceholder)
+	available := n.MaxLength - utf8.RuneCountInString(prefix+suffix+rest)
+
+	if runes := []rune(gameSlug); len(runes) > available {
+		gameSlug = strings.TrimRight(string(runes[:max(available, 0)]), "-")
+	}
+
+	return prefix + gameSlug + suffix + rest
+}
+
+// Namings are the naming of every guild, Default for the guilds not listed in Guilds and for the fields a guild
+// leaves out.
+type Namings struct {
+	Default Naming                  `yaml:"default"`
+	Guilds  map[snowflake.ID]Naming `yaml:"guilds"`
+}
+
+func DefaultNamings() Namings {
+	return Namings{Default: DefaultNaming()}
+}
+
+func (n Namings) Guild(guildID snowflake.ID) Naming {
+	naming, ok := n.Guilds[guildID]
+	if !ok {
+		return n.Default
+	}
+
+	if naming.Template == "" {
+		naming.Template = n.Default.Template
+	}
+
+	if naming.Language == "" {
+		naming.Language = n.Default.Language
+	}
+
+	if naming.MaxLength == 0 {
+		naming.MaxLength = n.Default.MaxLength
+	}
+
+	return naming
+}
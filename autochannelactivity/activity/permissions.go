@@ -0,0 +1,206 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+)
+
+// ErrMissingManageChannels is returned by PermissionClient instead of calling Discord.
+var ErrMissingManageChannels = errors.New("the bot is missing the Manage Channels permission")
+
+// PermissionSource is the part of cache.Caches the bot permissions are computed from.
+type PermissionSource interface {
+	SelfMember(guildID snowflake.ID) (discord.Member, bool)
+	Channel(channelID snowflake.ID) (discord.GuildChannel, bool)
+	MemberPermissions(member discord.Member) discord.Permissions
+	MemberPermissionsInChannel(channel discord.GuildChannel, member discord.Member) discord.Permissions
+}
+
+// PermissionClient is the ChannelClient that skips the mutations the bot has no permission for.
+// A guild where a mutation was skipped is misconfigured until the permission is granted, its admins are told once
+// when it happens and once when it is fixed. Guilds missing from the caches are left to Discord.
+type PermissionClient struct {
+	ctx           context.Context
+	client        ChannelClient
+	source        PermissionSource
+	reporter      *Reporter
+	metrics       *Metrics
+	logger        *slog.Logger
+	mu            sync.Mutex
+	misconfigured map[snowflake.ID][]snowflake.ID
+}
+
+func NewPermissionClient(
+	ctx context.Context,
+	client ChannelClient,
+	source PermissionSource,
+	reporter *Reporter,
+	metrics *Metrics,
+	logger *slog.Logger,
+) *PermissionClient {
+	return &PermissionClient{
+		ctx:           ctx,
+		client:        client,
+		source:        source,
+		reporter:      reporter,
+		metrics:       metrics,
+		logger:        logger,
+		misconfigured: map[snowflake.ID][]snowflake.ID{},
+	}
+}
+
+func (c *PermissionClient) GetGuildChannels(
+	guildID snowflake.ID,
+	opts ...rest.RequestOpt,
+) ([]discord.GuildChannel, error) {
+	return c.client.GetGuildChannels(guildID, opts...)
+}
+
+// CreateGuildChannel needs the permission in the parent of a channel and in the guild for a category.
+func (c *PermissionClient) CreateGuildChannel(
+	guildID snowflake.ID,
+	guildChannelCreate discord.GuildChannelCreate,
+	opts ...rest.RequestOpt,
+) (discord.GuildChannel, error) {
+	var parentID snowflake.ID
+
+	if textChannelCreate, ok := guildChannelCreate.(discord.GuildTextChannelCreate); ok {
+		parentID = textChannelCreate.ParentID
+	}
+
+	err := c.check(guildID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.CreateGuildChannel(guildID, guildChannelCreate, opts...)
+}
+
+// UpdateChannelPositions needs the permission on every moved channel and on their new parent.
+func (c *PermissionClient) UpdateChannelPositions(
+	guildID snowflake.ID,
+	guildChannelPositionUpdates []discord.GuildChannelPositionUpdate,
+	opts ...rest.RequestOpt,
+) error {
+	channelIDs := make([]snowflake.ID, 0, len(guildChannelPositionUpdates))
+
+	for _, update := range guildChannelPositionUpdates {
+		channelIDs = append(channelIDs, update.ID)
+
+		if update.ParentID != nil {
+			channelIDs = append(channelIDs, *update.ParentID)
+		}
+	}
+
+	err := c.check(guildID, channelIDs...)
+	if err != nil {
+		return err
+	}
+
+	return c.client.UpdateChannelPositions(guildID, guildChannelPositionUpdates, opts...)
+}
+
+// Listener rechecks a misconfigured guild on the updates that may grant the permission.
+func (c *PermissionClient) Listener() bot.EventListener {
+	return &events.ListenerAdapter{
+		OnRoleUpdate:         func(event *events.RoleUpdate) { c.Recheck(event.GuildID) },
+		OnGuildMemberUpdate:  func(event *events.GuildMemberUpdate) { c.Recheck(event.GuildID) },
+		OnGuildChannelUpdate: func(event *events.GuildChannelUpdate) { c.Recheck(event.GuildID) },
+	}
+}
+
+// Recheck clears the misconfiguration of a guild once the caches grant the missing permission.
+func (c *PermissionClient) Recheck(guildID snowflake.ID) {
+	c.mu.Lock()
+	channelIDs, misconfigured := c.misconfigured[guildID]
+	c.mu.Unlock()
+
+	if misconfigured && c.allowed(guildID, channelIDs) {
+		c.resolve(guildID)
+	}
+}
+
+func (c *PermissionClient) check(guildID snowflake.ID, channelIDs ...snowflake.ID) error {
+	if !c.allowed(guildID, channelIDs) {
+		c.flag(guildID, channelIDs)
+
+		return oops.With("guild_id", guildID, "channel_ids", channelIDs).Wrap(ErrMissingManageChannels)
+	}
+
+	c.Recheck(guildID)
+
+	return nil
+}
+
+// allowed checks the permission in each channel of channelIDs, or in the guild for a zero or uncached one.
+func (c *PermissionClient) allowed(guildID snowflake.ID, channelIDs []snowflake.ID) bool {
+	member, ok := c.source.SelfMember(guildID)
+	if !ok {
+		return true
+	}
+
+	if len(channelIDs) == 0 {
+		channelIDs = []snowflake.ID{0}
+	}
+
+	for _, channelID := range channelIDs {
+		permissions := c.source.MemberPermissions(member)
+
+		if channel, found := c.source.Channel(channelID); found {
+			permissions = c.source.MemberPermissionsInChannel(channel, member)
+		}
+
+		if !permissions.Has(discord.PermissionManageChannels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *PermissionClient) flag(guildID snowflake.ID, channelIDs []snowflake.ID) {
+	c.mu.Lock()
+	_, misconfigured := c.misconfigured[guildID]
+	c.misconfigured[guildID] = slices.Clone(channelIDs)
+	c.mu.Unlock()
+
+	if misconfigured {
+		return
+	}
+
+	c.metrics.misconfiguredGuild(guildID, true)
+	c.logger.WarnContext(
+		c.ctx,
+		"guild misconfigured, skipping channel changes",
+		slog.Any("guild_id", guildID),
+		slog.Any("channel_ids", channelIDs),
+	)
+	c.reporter.Notify(c.ctx, guildID,
+		"Channel changes are paused: the bot is missing the Manage Channels permission. "+
+			"They resume as soon as it is granted.")
+}
+
+func (c *PermissionClient) resolve(guildID snowflake.ID) {
+	c.mu.Lock()
+	_, misconfigured := c.misconfigured[guildID]
+	delete(c.misconfigured, guildID)
+	c.mu.Unlock()
+
+	if !misconfigured {
+		return
+	}
+
+	c.metrics.misconfiguredGuild(guildID, false)
+	c.logger.InfoContext(c.ctx, "guild permissions granted, resuming channel changes", slog.Any("guild_id", guildID))
+	c.reporter.Notify(c.ctx, guildID, "The bot has the Manage Channels permission again, channel changes resumed.")
+}
@@ -0,0 +1,95 @@
+package activity_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/disgo/cache"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+	"eggmech/host/fakediscord"
+)
+
+const (
+	botRoleID = snowflake.ID(40)
+	ownerID   = snowflake.ID(41)
+)
+
+// TestPermissionClient plays two presences while the cached role of the bot lacks Manage Channels,
+// then grants it and plays a third one.
+func TestPermissionClient(t *testing.T) {
+	ctx := context.Background()
+
+	server := fakediscord.New(fakediscord.WithToken(fakeToken))
+	server.AddGuild(guildID, fakeText(adminChannelID, "admin", 0, 0))
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	restClient := newFakeRest(httpServer)
+	reporter := newAdminReporter(restClient)
+	caches := botCaches(0)
+	client := activity.NewPermissionClient(
+		ctx, restClient, caches, reporter, activity.NewMetrics(prometheus.NewRegistry()), discardLogger(),
+	)
+
+	repo := activitytest.NewMemoryRepository()
+	repo.Usage[game] = true
+	repo.Usage["Portal"] = true
+
+	handleFakePresence(ctx, client, repo, reporter, game)
+	handleFakePresence(ctx, client, repo, reporter, game, "Zelda")
+
+	for _, request := range server.Requests() {
+		if request.Method != http.MethodGet && !strings.HasSuffix(request.Path, "/messages") {
+			t.Errorf("expected no mutation while denied, got %s %s", request.Method, request.Path)
+		}
+	}
+
+	for _, err := range []error{
+		expectReport(server, "missing the Manage Channels permission"),
+		expectSessions(Run{Repository: repo}, 2),
+	} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	caches.AddRole(discord.Role{ID: botRoleID, GuildID: guildID, Permissions: discord.PermissionManageChannels})
+	client.Recheck(guildID)
+
+	messages := server.Messages(adminChannelID)
+	if len(messages) != 2 || !strings.Contains(messages[1].Content, "resumed") {
+		t.Errorf("expected the resume to be announced once, got %v", messages)
+	}
+
+	handleFakePresence(ctx, client, repo, reporter, game, "Zelda", "Portal")
+
+	for _, err := range []error{
+		expectFakeParent(server, "portal", activity.CategoryGame),
+		expectContiguous(server),
+	} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// botCaches has the guild, owned by someone else, and the bot member with a single role granting permissions.
+func botCaches(permissions discord.Permissions) cache.Caches {
+	caches := cache.New(cache.WithCaches(cache.FlagGuilds | cache.FlagChannels | cache.FlagRoles | cache.FlagMembers))
+	caches.SetSelfUser(discord.OAuth2User{User: discord.User{ID: botID}})
+	caches.AddGuild(discord.Guild{ID: guildID, OwnerID: ownerID})
+	caches.AddRole(discord.Role{ID: guildID, GuildID: guildID})
+	caches.AddRole(discord.Role{ID: botRoleID, GuildID: guildID, Permissions: permissions})
+	caches.AddMember(discord.Member{User: discord.User{ID: botID}, GuildID: guildID, RoleIDs: []snowflake.ID{botRoleID}})
+
+	return caches
+}
@@ -0,0 +1,175 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/command"
+)
+
+// ChannelSource finds the channels of the caches.
+type ChannelSource interface {
+	Channel(channelID snowflake.ID) (discord.GuildChannel, bool)
+}
+
+// PlacementListener marks the tracked channels moved out of the bot categories as manually placed, the bot itself
+// only moves them between its categories. An admin hands them back to the bot with `/aca automate`.
+func PlacementListener(
+	ctx context.Context,
+	repo ChannelRepository,
+	source ChannelSource,
+	categories Categories,
+	logger *slog.Logger,
+) bot.EventListener {
+	return &events.ListenerAdapter{
+		OnGuildChannelUpdate: func(event *events.GuildChannelUpdate) {
+			if !movedByHand(event.OldChannel, event.Channel, source, categories) {
+				return
+			}
+
+			names, err := repo.SetManuallyPlaced(ctx, event.GuildID, event.ChannelID, true, time.Now())
+			if err != nil {
+				logger.ErrorContext(
+					ctx,
+					"cannot mark the channel manually placed",
+					slog.Any("guild_id", event.GuildID),
+					slog.Any("channel_id", event.ChannelID),
+					slog.Any("error", err),
+				)
+
+				return
+			}
+
+			for _, name := range names {
+				logger.InfoContext(
+					ctx,
+					"tracked channel moved by hand, the bot leaves it alone",
+					slog.Any("guild_id", event.GuildID),
+					slog.Any("channel_id", event.ChannelID),
+					slog.String("channel_name", name),
+				)
+			}
+		},
+	}
+}
+
+// movedByHand tells if channel left for a parent that is not a bot category, oldChannel is nil when it was not cached.
+// A channel losing a parent gone from the caches is a child of a deleted category, not a move, and without oldChannel
+// the two cannot be told apart.
+func movedByHand(
+	oldChannel discord.GuildChannel,
+	channel discord.GuildChannel,
+	source ChannelSource,
+	categories Categories,
+) bool {
+	if channel.Type() == discord.ChannelTypeGuildCategory {
+		return false
+	}
+
+	if oldChannel != nil && parentOf(oldChannel) == parentOf(channel) {
+		return false
+	}
+
+	if channel.ParentID() == nil {
+		if oldChannel == nil {
+			return false
+		}
+
+		_, parentFound := source.Channel(*oldChannel.ParentID())
+
+		return parentFound
+	}
+
+	parent, found := source.Channel(*channel.ParentID())
+
+	return found && !isBotCategory(parent.Name(), categories)
+}
+
+func isBotCategory(name string, categories Categories) bool {
+	return strings.EqualFold(name, categories.Game) || strings.EqualFold(name, categories.Archive)
+}
+
+// Subcommands are the `/aca` entries of the channels managed by the bot.
+func Subcommands(
+	ctx context.Context,
+	repo ChannelRepository,
+	client RenameClient,
+	namings Namings,
+	dryRun DryRun,
+	logger *slog.Logger,
+) []command.Subcommand {
+	return []command.Subcommand{
+		{
+			Option: discord.ApplicationCommandOptionSubCommand{
+				Name:        "automate",
+				Description: "Let the bot place a game channel moved by hand again (requires Manage Channels)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionChannel{
+						Name:         "channel",
+						Description:  "Game channel the bot places again",
+						Required:     true,
+						ChannelTypes: []discord.ChannelType{discord.ChannelTypeGuildText},
+					},
+				},
+			},
+			Handle: func(event *events.ApplicationCommandInteractionCreate, data discord.SlashCommandInteractionData) error {
+				return automateHandler(ctx, repo, event, data)
+			},
+		},
+		{
+			Option: discord.ApplicationCommandOptionSubCommand{
+				Name:        "rename",
+				Description: "Rename the game channels after the naming template (requires Manage Channels)",
+			},
+			Handle: func(event *events.ApplicationCommandInteractionCreate, _ discord.SlashCommandInteractionData) error {
+				return renameHandler(ctx, client, repo, namings, dryRun, event, logger)
+			},
+		},
+	}
+}
+
+func automateHandler(
+	ctx context.Context,
+	repo ChannelRepository,
+	event *events.ApplicationCommandInteractionCreate,
+	data discord.SlashCommandInteractionData,
+) error {
+	guildID := event.GuildID()
+	member := event.Member()
+
+	if guildID == nil || member == nil {
+		return command.Reply(event, "This command can only be used in a server.")
+	}
+
+	if !member.Permissions.Has(discord.PermissionManageChannels) {
+		return command.Reply(event, "You need the Manage Channels permission to automate a channel.")
+	}
+
+	channel := data.Channel("channel")
+
+	names, err := repo.SetManuallyPlaced(ctx, *guildID, channel.ID, false, time.Now())
+	if err != nil {
+		return oops.Wrapf(err, "failed to automate channel")
+	}
+
+	if len(names) == 0 {
+		return command.Reply(event, fmt.Sprintf(
+			"%s is not a game channel moved by hand.",
+			discord.ChannelMention(channel.ID),
+		))
+	}
+
+	return command.Reply(event, fmt.Sprintf(
+		"%s is placed by the bot again from the next game session.",
+		discord.ChannelMention(channel.ID),
+	))
+}
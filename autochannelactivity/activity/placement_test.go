@@ -0,0 +1,110 @@
+package activity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/cache"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+)
+
+// TestPlacement moves the tracked channel to the archive as the bot does, out of it as deleting the archive does,
+// then out of the bot categories as an admin does, and hands it back to the bot.
+func TestPlacement(t *testing.T) {
+	ctx := context.Background()
+
+	repo := activitytest.NewMemoryRepository()
+	repo.Channels = []activitytest.StoredChannel{{GuildID: guildID, ChannelID: channelID, ActivityName: channelName}}
+
+	caches := cache.New(cache.WithCaches(cache.FlagChannels))
+
+	for _, channel := range append(guildChannels(categoryGameID), activitytest.Channel{
+		ID: loungeID, Type: discord.ChannelTypeGuildCategory, Name: "Lounge",
+	}) {
+		caches.AddChannel(guildChannel(t, channel))
+	}
+
+	listener := activity.PlacementListener(ctx, repo, caches, activity.DefaultCategories(), discardLogger())
+
+	tests := []struct {
+		name     string
+		deleted  snowflake.ID
+		from, to snowflake.ID
+		manual   bool
+	}{
+		{name: "the bot archives it", from: categoryGameID, to: categoryArchiveID, manual: false},
+		{name: "the archive is deleted", deleted: categoryArchiveID, from: categoryArchiveID, to: 0, manual: false},
+		{name: "an admin moves it to the top", from: categoryGameID, to: 0, manual: true},
+		{name: "an admin moves it out", from: categoryGameID, to: loungeID, manual: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.SetManuallyPlaced(ctx, guildID, channelID, false, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// disgo removes a deleted category from the caches before its children lose their parent
+			if tt.deleted != 0 {
+				caches.RemoveChannel(tt.deleted)
+			}
+
+			moveTracked(t, listener, tt.from, tt.to)
+
+			tracked, err := repo.GuildTrackedChannels(ctx, guildID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(tracked) != 1 || tracked[0].ManuallyPlaced != tt.manual {
+				t.Errorf("expected manually placed %t after moving to %d, got %v", tt.manual, tt.to, tracked)
+			}
+		})
+	}
+
+	names, err := repo.SetManuallyPlaced(ctx, guildID, channelID, false, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 1 || names[0] != channelName {
+		t.Errorf("expected %s placed by the bot again, got %v", channelName, names)
+	}
+}
+
+func guildChannel(t *testing.T, channel activitytest.Channel) discord.GuildChannel {
+	t.Helper()
+
+	guildChannel, err := channel.GuildChannel(guildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return guildChannel
+}
+
+func moveTracked(t *testing.T, listener bot.EventListener, from snowflake.ID, to snowflake.ID) {
+	t.Helper()
+
+	listener.OnEvent(&events.GuildChannelUpdate{
+		GenericGuildChannel: &events.GenericGuildChannel{
+			GenericEvent: events.NewGenericEvent(nil, 0, 0),
+			ChannelID:    channelID,
+			Channel: guildChannel(t, activitytest.Channel{
+				ID: channelID, Type: discord.ChannelTypeGuildText, Name: channelName, ParentID: to,
+			}),
+			GuildID: guildID,
+		},
+		OldChannel: guildChannel(t, activitytest.Channel{
+			ID: channelID, Type: discord.ChannelTypeGuildText, Name: channelName, ParentID: from,
+		}),
+	})
+}
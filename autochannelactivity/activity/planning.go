@@ -0,0 +1,301 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+)
+
+type MessageClient interface {
+	CreateMessage(
+		channelID snowflake.ID,
+		messageCreate discord.MessageCreate,
+		opts ...rest.RequestOpt,
+	) (*discord.Message, error)
+}
+
+// PlanningClient is the ChannelClient of the dry run, it only plans the mutations of the guilds in dry run.
+// Channels listing always goes to Discord. The actions planned for a presence are posted together once PostPlan
+// is called, off the handling of the presences, and an action planned again by later presences is not posted again.
+type PlanningClient struct {
+	ctx      context.Context
+	client   ChannelClient
+	messages MessageClient
+	dryRun   DryRun
+	logger   *slog.Logger
+	mu       sync.Mutex
+	nextID   snowflake.ID
+	// plannedIDs are the made up IDs of the planned channels by guild, kind and name, so their actions read the same
+	plannedIDs map[string]snowflake.ID
+	planned    map[snowflake.ID][]string
+	// ready are the actions handed to the poster, reported the ones it has already posted or is about to
+	ready    map[snowflake.ID][]string
+	reported map[snowflake.ID]map[string]bool
+	posts    chan struct{}
+}
+
+// dryRunner lets the handler skip storing and announcing the channels that were only planned, and post the plan of
+// a presence once it is handled.
+type dryRunner interface {
+	DryRun(guildID snowflake.ID) bool
+	PostPlan(guildID snowflake.ID)
+}
+
+// planSummaryLines keeps the plan under the 2000 characters of a message with names of up to 100 characters.
+const planSummaryLines = 10
+
+func NewPlanningClient(
+	ctx context.Context,
+	client ChannelClient,
+	messages MessageClient,
+	dryRun DryRun,
+	logger *slog.Logger,
+) *PlanningClient {
+	c := &PlanningClient{
+		ctx:        ctx,
+		client:     client,
+		messages:   messages,
+		dryRun:     dryRun,
+		logger:     logger,
+		nextID:     snowflake.New(time.Now()),
+		plannedIDs: map[string]snowflake.ID{},
+		planned:    map[snowflake.ID][]string{},
+		ready:      map[snowflake.ID][]string{},
+		reported:   map[snowflake.ID]map[string]bool{},
+		posts:      make(chan struct{}, 1),
+	}
+
+	go c.postPlans()
+
+	return c
+}
+
+func (c *PlanningClient) DryRun(guildID snowflake.ID) bool {
+	return c.dryRun.Guild(guildID)
+}
+
+func (c *PlanningClient) GetGuildChannels(
+	guildID snowflake.ID,
+	opts ...rest.RequestOpt,
+) ([]discord.GuildChannel, error) {
+	return c.client.GetGuildChannels(guildID, opts...)
+}
+
+// CreateGuildChannel answers a planned channel with a made up ID, so the handler can plan the moves that follow.
+func (c *PlanningClient) CreateGuildChannel(
+	guildID snowflake.ID,
+	guildChannelCreate discord.GuildChannelCreate,
+	opts ...rest.RequestOpt,
+) (discord.GuildChannel, error) {
+	if !c.dryRun.Guild(guildID) {
+		return c.client.CreateGuildChannel(guildID, guildChannelCreate, opts...)
+	}
+
+	content, err := json.Marshal(guildChannelCreate)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to encode planned channel")
+	}
+
+	var fields map[string]any
+
+	err = json.Unmarshal(content, &fields)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to decode planned channel")
+	}
+
+	key := fmt.Sprintf("%d/%d/%v", guildID, guildChannelCreate.Type(), fields["name"])
+
+	c.mu.Lock()
+	channelID, found := c.plannedIDs[key]
+	if !found {
+		channelID = c.nextID
+		c.nextID++
+		c.plannedIDs[key] = channelID
+	}
+	c.mu.Unlock()
+
+	fields["id"] = channelID
+	fields["guild_id"] = guildID
+	fields["type"] = guildChannelCreate.Type()
+
+	content, err = json.Marshal(fields)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to encode planned channel")
+	}
+
+	var channel discord.UnmarshalChannel
+
+	err = json.Unmarshal(content, &channel)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to decode planned channel")
+	}
+
+	guildChannel, ok := channel.Channel.(discord.GuildChannel)
+	if !ok {
+		return nil, oops.Errorf("planned channel of type %d is not a guild channel", guildChannelCreate.Type())
+	}
+
+	parentID := snowflake.ID(0)
+	if guildChannel.ParentID() != nil {
+		parentID = *guildChannel.ParentID()
+	}
+
+	c.plan(guildID, fmt.Sprintf("create %s %q in %d at position %d",
+		channelKind(guildChannel.Type()), guildChannel.Name(), parentID, guildChannel.Position()),
+		slog.String("action", "create_channel"),
+		slog.String("name", guildChannel.Name()),
+		slog.Int("type", int(guildChannel.Type())),
+		slog.Any("parent_id", parentID),
+		slog.Int("position", guildChannel.Position()),
+	)
+
+	return guildChannel, nil
+}
+
+func (c *PlanningClient) UpdateChannelPositions(
+	guildID snowflake.ID,
+	guildChannelPositionUpdates []discord.GuildChannelPositionUpdate,
+	opts ...rest.RequestOpt,
+) error {
+	if !c.dryRun.Guild(guildID) {
+		return c.client.UpdateChannelPositions(guildID, guildChannelPositionUpdates, opts...)
+	}
+
+	for _, update := range guildChannelPositionUpdates {
+		attrs := []slog.Attr{slog.String("action", "move_channel"), slog.Any("channel_id", update.ID)}
+		summary := fmt.Sprintf("move %d", update.ID)
+
+		if update.ParentID != nil {
+			attrs = append(attrs, slog.Any("parent_id", *update.ParentID))
+			summary += fmt.Sprintf(" to %d", *update.ParentID)
+		}
+
+		if update.Position != nil && !update.Position.IsNull() {
+			attrs = append(attrs, slog.Int("position", update.Position.Value()))
+			summary += fmt.Sprintf(" at position %d", update.Position.Value())
+		}
+
+		c.plan(guildID, summary, attrs...)
+	}
+
+	return nil
+}
+
+// plan logs a planned action and keeps its summary for the admin channel, unless it was already reported.
+func (c *PlanningClient) plan(guildID snowflake.ID, summary string, attrs ...slog.Attr) {
+	c.logger.LogAttrs(c.ctx, slog.LevelInfo, "planned action", append(attrs, slog.Any("guild_id", guildID))...)
+
+	if c.dryRun.AdminChannelID == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.reported[guildID][summary] && !slices.Contains(c.planned[guildID], summary) {
+		c.planned[guildID] = append(c.planned[guildID], summary)
+	}
+}
+
+// PostPlan hands the actions planned in a guild since the last call to the poster, which posts them to the admin
+// channel as a single message.
+func (c *PlanningClient) PostPlan(guildID snowflake.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summaries := c.planned[guildID]
+	delete(c.planned, guildID)
+
+	if len(summaries) == 0 {
+		return
+	}
+
+	if c.reported[guildID] == nil {
+		c.reported[guildID] = map[string]bool{}
+	}
+
+	for _, summary := range summaries {
+		c.reported[guildID][summary] = true
+	}
+
+	c.ready[guildID] = append(c.ready[guildID], summaries...)
+
+	select {
+	case c.posts <- struct{}{}:
+	default:
+	}
+}
+
+// postPlans posts the actions handed by PostPlan until ctx is done, the ones handed while a post waits on Discord
+// are posted together.
+func (c *PlanningClient) postPlans() {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-c.posts:
+		}
+
+		c.mu.Lock()
+		ready := c.ready
+		c.ready = map[snowflake.ID][]string{}
+		c.mu.Unlock()
+
+		for guildID, summaries := range ready {
+			_, err := c.messages.CreateMessage(c.dryRun.AdminChannelID, discord.MessageCreate{
+				Content: planSummary(guildID, summaries),
+			})
+			if err != nil {
+				c.logger.WarnContext(c.ctx, "cannot post planned actions", slog.Any("error", oops.Wrap(err)))
+			}
+		}
+	}
+}
+
+func planSummary(guildID snowflake.ID, summaries []string) string {
+	var summary strings.Builder
+
+	fmt.Fprintf(&summary, "dry run in guild %d:", guildID)
+
+	for i, line := range summaries {
+		if i == planSummaryLines {
+			fmt.Fprintf(&summary, "\nand %d more.", len(summaries)-i)
+
+			break
+		}
+
+		fmt.Fprintf(&summary, "\n- %s", line)
+	}
+
+	return summary.String()
+}
+
+func channelKind(channelType discord.ChannelType) string {
+	if channelType == discord.ChannelTypeGuildCategory {
+		return "category"
+	}
+
+	return "channel"
+}
+
+func isDryRun(client ChannelClient, guildID snowflake.ID) bool {
+	planner, ok := client.(dryRunner)
+
+	return ok && planner.DryRun(guildID)
+}
+
+func postPlan(client ChannelClient, guildID snowflake.ID) {
+	if planner, ok := client.(dryRunner); ok {
+		planner.PostPlan(guildID)
+	}
+}
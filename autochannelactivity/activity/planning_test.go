@@ -0,0 +1,168 @@
+package activity_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/json"
+	"github.com/disgoorg/snowflake/v2"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+)
+
+type recordingMessages struct {
+	mu       sync.Mutex
+	messages []discord.MessageCreate
+}
+
+func (m *recordingMessages) CreateMessage(
+	_ snowflake.ID,
+	messageCreate discord.MessageCreate,
+	_ ...rest.RequestOpt,
+) (*discord.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, messageCreate)
+
+	return &discord.Message{}, nil
+}
+
+// posted waits for the poster to settle and returns the messages it posted.
+func (m *recordingMessages) posted() []discord.MessageCreate {
+	time.Sleep(50 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.messages
+}
+
+func TestPlanningClientPostPlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		moves     int
+		presences int
+		posts     int
+		expected  []string
+	}{
+		{name: "nothing planned posts nothing", moves: 0, presences: 1, posts: 1},
+		{
+			name:      "the plan of a presence is one message",
+			moves:     3,
+			presences: 1,
+			posts:     2,
+			expected:  []string{"\n- move 22"},
+		},
+		{
+			name:      "a long plan is cut",
+			moves:     12,
+			presences: 1,
+			posts:     1,
+			expected:  []string{"\n- move 29", "\nand 2 more."},
+		},
+		{
+			name:      "the actions planned again by later presences are not posted again",
+			moves:     3,
+			presences: 3,
+			posts:     1,
+			expected:  []string{"\n- move 22"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			messages := &recordingMessages{}
+			client := activity.NewPlanningClient(
+				ctx,
+				activitytest.NewRecordingChannels(),
+				messages,
+				activity.DryRun{Guilds: []snowflake.ID{guildID}, AdminChannelID: adminChannelID},
+				discardLogger(),
+			)
+
+			updates := make([]discord.GuildChannelPositionUpdate, 0, tt.moves)
+
+			for i := range tt.moves {
+				updates = append(updates, discord.GuildChannelPositionUpdate{
+					ID:       channelID + snowflake.ID(i),
+					Position: json.NewNullablePtr(i),
+				})
+			}
+
+			for range tt.presences {
+				err := client.UpdateChannelPositions(guildID, updates)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for range tt.posts {
+					client.PostPlan(guildID)
+				}
+			}
+
+			posted := messages.posted()
+
+			if tt.expected == nil {
+				if len(posted) != 0 {
+					t.Errorf("expected no message, got %v", posted)
+				}
+
+				return
+			}
+
+			if len(posted) != 1 {
+				t.Fatalf("expected one message, got %v", posted)
+			}
+
+			for _, text := range tt.expected {
+				if strings.Count(posted[0].Content, text) != 1 {
+					t.Errorf("expected a message containing %q once, got %q", text, posted[0].Content)
+				}
+			}
+
+			if strings.Contains(posted[0].Content, "move 30") {
+				t.Errorf("expected the plan cut after 10 actions, got %q", posted[0].Content)
+			}
+		})
+	}
+}
+
+// TestPlanningClientKeepsPlannedIDs plans the same channel for two presences, it keeps its made up ID so the moves
+// that follow read the same.
+func TestPlanningClientKeepsPlannedIDs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := activity.NewPlanningClient(
+		ctx,
+		activitytest.NewRecordingChannels(),
+		&recordingMessages{},
+		activity.DryRun{Guilds: []snowflake.ID{guildID}, AdminChannelID: adminChannelID},
+		discardLogger(),
+	)
+
+	var ids []snowflake.ID
+
+	for _, name := range []string{activity.CategoryGame, activity.CategoryGame, activity.CategoryArchive} {
+		channel, err := client.CreateGuildChannel(guildID, discord.GuildCategoryChannelCreate{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, channel.ID())
+	}
+
+	if ids[0] != ids[1] || ids[0] == ids[2] {
+		t.Errorf("expected the same ID for the same category only, got %v", ids)
+	}
+}
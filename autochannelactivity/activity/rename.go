@@ -0,0 +1,215 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/command"
+)
+
+// RenameClient is what renaming the tracked channels needs from Discord.
+type RenameClient interface {
+	GetGuildChannels(guildID snowflake.ID, opts ...rest.RequestOpt) ([]discord.GuildChannel, error)
+	UpdateChannel(
+		channelID snowflake.ID,
+		channelUpdate discord.ChannelUpdate,
+		opts ...rest.RequestOpt,
+	) (discord.Channel, error)
+}
+
+// Rename is the name a tracked channel goes from and to.
+type Rename struct {
+	ChannelID snowflake.ID
+	From      string
+	To        string
+}
+
+// PlanRenames names the tracked channels of a guild by naming, in the order of their games so the collisions resolve
+// the same way on every run. The channels already named, gone from channels or manually placed are left out.
+// As in resolveChannel, only the names of the tracked channels are taken, the other channels do not collide.
+func PlanRenames(naming Naming, tracked []TrackedChannel, channels []discord.GuildChannel) []Rename {
+	existing := map[snowflake.ID]discord.GuildChannel{}
+	taken := map[string]bool{}
+
+	for _, channel := range channels {
+		existing[channel.ID()] = channel
+	}
+
+	tracked = slices.Clone(tracked)
+	slices.SortStableFunc(tracked, func(a TrackedChannel, b TrackedChannel) int {
+		return strings.Compare(a.ActivityName, b.ActivityName)
+	})
+
+	renamed := map[snowflake.ID]bool{}
+
+	var renames []Rename
+
+	for _, trackedChannel := range tracked {
+		channel, found := existing[trackedChannel.ChannelID]
+		if !found || renamed[channel.ID()] {
+			continue
+		}
+
+		renamed[channel.ID()] = true
+
+		if trackedChannel.ManuallyPlaced {
+			taken[channel.Name()] = true
+
+			continue
+		}
+
+		game := trackedChannel.GameName
+		if game == "" {
+			game = trackedChannel.ActivityName
+		}
+
+		name := naming.ChannelName(game)
+		if taken[name] {
+			name = naming.CollisionName(game)
+		}
+
+		taken[name] = true
+
+		if name != channel.Name() {
+			renames = append(renames, Rename{ChannelID: channel.ID(), From: channel.Name(), To: name})
+		}
+	}
+
+	return renames
+}
+
+// RenameChannels renames the tracked channels of guildID after naming, or only plans it when dryRun.
+// The renames done before a failure are returned with it.
+func RenameChannels(
+	ctx context.Context,
+	client RenameClient,
+	repo ChannelRepository,
+	naming Naming,
+	guildID snowflake.ID,
+	dryRun bool,
+) ([]Rename, error) {
+	tracked, err := repo.GuildTrackedChannels(ctx, guildID)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to get tracked channels")
+	}
+
+	channels, err := client.GetGuildChannels(guildID)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to get channels")
+	}
+
+	renames := PlanRenames(naming, tracked, channels)
+	if dryRun {
+		return renames, nil
+	}
+
+	for i, rename := range renames {
+		_, err = client.UpdateChannel(rename.ChannelID, discord.GuildTextChannelUpdate{Name: &rename.To})
+		if err != nil {
+			return renames[:i], oops.With("channel_id", rename.ChannelID).Wrapf(err, "failed to rename channel")
+		}
+
+		err = repo.RenameChannel(ctx, guildID, rename.ChannelID, rename.To)
+		if err != nil {
+			return renames[:i+1], oops.With("channel_id", rename.ChannelID).Wrapf(err, "failed to store channel name")
+		}
+	}
+
+	return renames, nil
+}
+
+func renameHandler(
+	ctx context.Context,
+	client RenameClient,
+	repo ChannelRepository,
+	namings Namings,
+	dryRun DryRun,
+	event *events.ApplicationCommandInteractionCreate,
+	logger *slog.Logger,
+) error {
+	guildID := event.GuildID()
+	member := event.Member()
+
+	if guildID == nil || member == nil {
+		return command.Reply(event, "This command can only be used in a server.")
+	}
+
+	if !member.Permissions.Has(discord.PermissionManageChannels) {
+		return command.Reply(event, "You need the Manage Channels permission to rename the game channels.")
+	}
+
+	err := event.DeferCreateMessage(true)
+	if err != nil {
+		return oops.Wrapf(err, "failed to defer reply")
+	}
+
+	// each rename waits on the rate limit of its channel, the interaction is answered once they are all done
+	go func() {
+		planned := dryRun.Guild(*guildID)
+		renames, errRename := RenameChannels(ctx, client, repo, namings.Guild(*guildID), *guildID, planned)
+
+		if errRename != nil {
+			logger.ErrorContext(
+				ctx,
+				"cannot rename the tracked channels",
+				slog.Any("guild_id", *guildID),
+				slog.Any("error", errRename),
+			)
+		}
+
+		_, errFollowup := event.Client().Rest().CreateFollowupMessage(
+			event.ApplicationID(),
+			event.Token(),
+			discord.NewMessageCreateBuilder().
+				SetContent(renameSummary(renames, planned, errRename != nil)).
+				SetEphemeral(true).
+				Build(),
+		)
+		if errFollowup != nil {
+			logger.WarnContext(ctx, "cannot reply to the rename", slog.Any("error", errFollowup))
+		}
+	}()
+
+	return nil
+}
+
+// renameSummaryLines keeps the summary under the 2000 characters of a message with names of up to 100 characters.
+const renameSummaryLines = 8
+
+func renameSummary(renames []Rename, planned bool, failed bool) string {
+	var summary strings.Builder
+
+	switch {
+	case len(renames) == 0 && !failed:
+		summary.WriteString("Every game channel is already named after the template.")
+	case planned:
+		fmt.Fprintf(&summary, "Dry run, %d game channels would be renamed:", len(renames))
+	default:
+		fmt.Fprintf(&summary, "%d game channels renamed:", len(renames))
+	}
+
+	for i, rename := range renames {
+		if i == renameSummaryLines {
+			fmt.Fprintf(&summary, "\nand %d more.", len(renames)-i)
+
+			break
+		}
+
+		fmt.Fprintf(&summary, "\n%s: %s → %s", discord.ChannelMention(rename.ChannelID), rename.From, rename.To)
+	}
+
+	if failed {
+		summary.WriteString("\nSomething went wrong, the other channels were not renamed, please try again later.")
+	}
+
+	return summary.String()
+}
@@ -0,0 +1,183 @@
+package activity_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/internal/activitytest"
+	"eggmech/host/fakediscord"
+)
+
+// TestRenameChannels plans the renames of a new template in dry run, then applies them. The channel made before the
+// games were stored is renamed by its key, the one moved by hand is left alone and the one whose name a channel moved
+// by hand keeps gets the collision name, while a channel the bot does not track takes no name, as when handling
+// presences.
+func TestRenameChannels(t *testing.T) {
+	ctx := context.Background()
+	naming := activity.Naming{Template: "🎮-{slug}", Language: "en", MaxLength: activity.MaxChannelName}
+
+	repo := activitytest.NewMemoryRepository()
+	repo.Channels = []activitytest.StoredChannel{
+		{GuildID: guildID, ChannelID: channelID, ActivityName: channelName, GameName: game, Name: channelName},
+		{GuildID: guildID, ChannelID: channelID + 1, ActivityName: "zelda"},
+		{GuildID: guildID, ChannelID: channelID + 2, ActivityName: "portal", PlacedAt: time.Now()},
+		{GuildID: guildID + 1, ChannelID: channelID + 3, ActivityName: "celeste"},
+		{GuildID: guildID, ChannelID: channelID + 4, ActivityName: "other", Name: "🎮-zelda", PlacedAt: time.Now()},
+	}
+
+	channels := activitytest.NewRecordingChannels(append(guildChannels(categoryGameID),
+		activitytest.Channel{ID: channelID + 1, Type: discord.ChannelTypeGuildText, Name: "zelda", ParentID: categoryGameID},
+		activitytest.Channel{ID: channelID + 2, Type: discord.ChannelTypeGuildText, Name: "portal"},
+		activitytest.Channel{ID: channelID + 4, Type: discord.ChannelTypeGuildText, Name: "🎮-zelda"},
+		activitytest.Channel{ID: channelID + 5, Type: discord.ChannelTypeGuildText, Name: "🎮-" + channelName},
+	)...)
+
+	planned := []activity.Rename{
+		{ChannelID: channelID, From: channelName, To: "🎮-" + channelName},
+		{ChannelID: channelID + 1, From: "zelda", To: naming.CollisionName("zelda")},
+	}
+
+	tests := []struct {
+		name     string
+		dryRun   bool
+		expected []activity.Rename
+	}{
+		{name: "dry run", dryRun: true, expected: planned},
+		{name: "apply", dryRun: false, expected: planned},
+		{name: "apply again", dryRun: false, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renames, err := activity.RenameChannels(ctx, channels, repo, naming, guildID, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(renames, tt.expected) {
+				t.Errorf("expected renames %v, got %v", tt.expected, renames)
+			}
+		})
+	}
+
+	for _, channel := range []struct {
+		name   string
+		stored string
+	}{
+		{"🎮-" + channelName, "🎮-" + channelName},
+		{naming.CollisionName("zelda"), naming.CollisionName("zelda")},
+		{"portal", ""},
+	} {
+		found, ok := channels.Find(channel.name)
+		stored := slices.IndexFunc(repo.Channels, func(stored activitytest.StoredChannel) bool {
+			return stored.ChannelID == found.ID && stored.Name == channel.stored
+		})
+
+		if !ok || stored < 0 {
+			t.Errorf("expected channel %s stored as %q, got %v and %v",
+				channel.name, channel.stored, channels.Channels, repo.Channels)
+		}
+	}
+
+	err := expectCalls(Run{Channels: channels}, "UpdateChannel", 2)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestRenameChannelsOnFakeDiscord applies the renames through a real REST client talking to a fakediscord server,
+// a rate limited rename is retried and a refused one is not stored.
+func TestRenameChannelsOnFakeDiscord(t *testing.T) {
+	naming := activity.Naming{Template: "🎮-{slug}", Language: "en", MaxLength: activity.MaxChannelName}
+
+	tests := []struct {
+		name           string
+		rateLimitEvery int
+		denied         bool
+		expectStatus   int
+		expectNames    []string
+	}{
+		{name: "renamed", expectStatus: http.StatusOK, expectNames: []string{"🎮-" + channelName, "🎮-zelda"}},
+		{
+			name:           "a rate limited rename is retried",
+			rateLimitEvery: 2,
+			expectStatus:   http.StatusTooManyRequests,
+			expectNames:    []string{"🎮-" + channelName, "🎮-zelda"},
+		},
+		{
+			name:         "a rename without Manage Channels is refused",
+			denied:       true,
+			expectStatus: http.StatusForbidden,
+			expectNames:  []string{channelName, "zelda"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []fakediscord.Option{fakediscord.WithToken(fakeToken), fakediscord.WithRateLimitEvery(tt.rateLimitEvery)}
+			if tt.denied {
+				opts = append(opts, fakediscord.WithoutManageChannels())
+			}
+
+			server := fakediscord.New(opts...)
+			server.AddGuild(guildID,
+				fakediscord.Channel{ID: categoryGameID, Type: discord.ChannelTypeGuildCategory, Name: activity.CategoryGame},
+				fakeText(channelID, channelName, categoryGameID, 0),
+				fakeText(channelID+1, "zelda", categoryGameID, 1),
+			)
+
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			repo := activitytest.NewMemoryRepository()
+			repo.Channels = []activitytest.StoredChannel{
+				{GuildID: guildID, ChannelID: channelID, ActivityName: channelName, Name: channelName},
+				{GuildID: guildID, ChannelID: channelID + 1, ActivityName: "zelda", Name: "zelda"},
+			}
+
+			renames, err := activity.RenameChannels(context.Background(), newFakeRest(httpServer), repo, naming, guildID, false)
+			if tt.denied != (err != nil) {
+				t.Fatalf("expected refused %t, got %v", tt.denied, err)
+			}
+
+			err = errors.Join(
+				expectStatus(server, tt.expectStatus),
+				expectFakeOrder(server, categoryGameID, tt.expectNames...),
+				expectStoredNames(repo, renames, tt.expectNames...),
+			)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// expectStoredNames checks the names stored for the channels, in order, and that only the applied renames were.
+func expectStoredNames(repo *activitytest.MemoryRepository, renames []activity.Rename, names ...string) error {
+	applied := 0
+
+	for i, channel := range repo.Channels {
+		if channel.Name != names[i] {
+			return oops.Errorf("expected stored names %v, got %v", names, repo.Channels)
+		}
+
+		if channel.Name != channel.ActivityName {
+			applied++
+		}
+	}
+
+	if applied != len(renames) {
+		return oops.Errorf("expected %d renames stored, got %d", len(renames), applied)
+	}
+
+	return nil
+}
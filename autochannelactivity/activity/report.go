@@ -0,0 +1,233 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+)
+
+// Error classes, set as the oops code of the handler errors.
+const (
+	ClassPermission = "discord_permission"
+	// ClassMisconfigured is a mutation PermissionClient skipped, its admins were already told.
+	ClassMisconfigured = "misconfigured"
+	ClassRateLimit     = "rate_limit"
+	ClassDiscord       = "discord"
+	ClassDatabase      = "database"
+	ClassValidation    = "validation"
+)
+
+// JSON error codes of Discord, see https://discord.com/developers/docs/topics/opcodes-and-status-codes
+const (
+	codeMissingAccess      rest.JSONErrorCode = 50001
+	codeMissingPermissions rest.JSONErrorCode = 50013
+)
+
+const errorDomain = "autochannelactivity"
+
+// reportInterval is how long the same error is not posted again to the admin channel of a guild.
+const reportInterval = time.Hour
+
+// errorBuilder is the base of the handler errors about event, the operation, when known, becomes the tag.
+func errorBuilder(event *events.PresenceUpdate, operation string) oops.OopsErrorBuilder {
+	builder := oops.In(errorDomain).With("guild_id", event.GuildID, "user_id", event.PresenceUser.ID)
+	if operation != "" {
+		builder = builder.Tags(operation)
+	}
+
+	return builder
+}
+
+// Retryable tells if err, or one of the errors joined in it, may pass when tried again: a database error or a rate
+// limit of Discord.
+func Retryable(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint // errors.Join is never wrapped here
+		return slices.ContainsFunc(joined.Unwrap(), Retryable)
+	}
+
+	oopsError, ok := oops.AsOops(err)
+	if !ok {
+		return false
+	}
+
+	return oopsError.Code() == ClassDatabase || oopsError.Code() == ClassRateLimit
+}
+
+// discordError wraps the error of a Discord call, classified from the response of Discord.
+func discordError(builder oops.OopsErrorBuilder, err error, message string) error {
+	return builder.Code(discordClass(err)).Wrapf(err, "%s", message)
+}
+
+func databaseError(builder oops.OopsErrorBuilder, err error, message string) error {
+	return builder.Code(ClassDatabase).Wrapf(err, "%s", message)
+}
+
+func discordClass(err error) string {
+	if errors.Is(err, ErrMissingManageChannels) {
+		return ClassMisconfigured
+	}
+
+	var restError rest.Error
+	if !errors.As(err, &restError) || restError.Response == nil {
+		return ClassDiscord
+	}
+
+	switch {
+	case restError.Code == codeMissingPermissions || restError.Code == codeMissingAccess:
+		return ClassPermission
+	case restError.Response.StatusCode == http.StatusForbidden:
+		return ClassPermission
+	case restError.Response.StatusCode == http.StatusTooManyRequests:
+		return ClassRateLimit
+	case restError.Response.StatusCode == http.StatusBadRequest:
+		return ClassValidation
+	}
+
+	return ClassDiscord
+}
+
+// Reporter logs the handler errors and posts the ones an admin can act on to the admin channel of their guild,
+// once per reportInterval.
+type Reporter struct {
+	messages      MessageClient
+	adminChannels map[snowflake.ID]snowflake.ID
+	logger        *slog.Logger
+	mu            sync.Mutex
+	reported      map[string]time.Time
+}
+
+func NewReporter(messages MessageClient, adminChannels map[snowflake.ID]snowflake.ID, logger *slog.Logger) *Reporter {
+	return &Reporter{
+		messages:      messages,
+		adminChannels: adminChannels,
+		logger:        logger,
+		reported:      map[string]time.Time{},
+	}
+}
+
+// Report handles every error joined in err on its own.
+func (r *Reporter) Report(ctx context.Context, guildID snowflake.ID, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint // errors.Join is never wrapped here
+		for _, joinedErr := range joined.Unwrap() {
+			r.Report(ctx, guildID, joinedErr)
+		}
+
+		return
+	}
+
+	oopsError, ok := oops.AsOops(err)
+	if !ok {
+		r.logger.ErrorContext(ctx, "presence handling failed", slog.Any("error", err))
+
+		return
+	}
+
+	level := slog.LevelError
+
+	switch oopsError.Code() {
+	case ClassRateLimit:
+		level = slog.LevelWarn
+	case ClassMisconfigured:
+		level = slog.LevelDebug
+	}
+
+	r.logger.Log(ctx, level, "presence handling failed", slog.String("class", oopsError.Code()), slog.Any("error", err))
+
+	message := actionMessage(oopsError)
+	if message == "" {
+		return
+	}
+
+	if r.shouldPost(guildID, message, time.Now()) {
+		r.Notify(ctx, guildID, message)
+	}
+}
+
+// Notify posts message to the admin channel of guildID, if it has one.
+func (r *Reporter) Notify(ctx context.Context, guildID snowflake.ID, message string) {
+	channelID, ok := r.adminChannels[guildID]
+	if !ok {
+		return
+	}
+
+	_, err := r.messages.CreateMessage(channelID, discord.MessageCreate{Content: message})
+	if err != nil {
+		r.logger.WarnContext(ctx, "cannot post to the admin channel", slog.Any("error", oops.Wrap(err)))
+	}
+}
+
+// shouldPost drops the errors reported over reportInterval ago when it adds one, reported then only holds the
+// errors of the last interval.
+func (r *Reporter) shouldPost(guildID snowflake.ID, message string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := guildID.String() + message
+	if last, ok := r.reported[key]; ok && now.Sub(last) < reportInterval {
+		return false
+	}
+
+	maps.DeleteFunc(r.reported, func(_ string, last time.Time) bool {
+		return now.Sub(last) >= reportInterval
+	})
+	r.reported[key] = now
+
+	return true
+}
+
+// actionMessage tells an admin what to fix, it is empty for the errors they cannot act on.
+func actionMessage(oopsError oops.OopsError) string {
+	operation := "handle presences"
+	if tags := oopsError.Tags(); len(tags) > 0 {
+		operation = tags[0]
+	}
+
+	game, _ := oopsError.Context()["activity_name"].(string)
+	if game != "" {
+		operation += " for " + game
+	}
+
+	switch oopsError.Code() {
+	case ClassPermission:
+		return fmt.Sprintf("Cannot %s: the bot is missing the Manage Channels permission.", operation)
+	case ClassValidation:
+		var restError rest.Error
+		if errors.As(oopsError, &restError) {
+			return fmt.Sprintf("Cannot %s: Discord refused it (%s).", operation, refusal(restError))
+		}
+	}
+
+	return ""
+}
+
+// refusal is the first field error of a Discord form error, such as a full category, or its message.
+func refusal(restError rest.Error) string {
+	var fields map[string]struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"_errors"`
+	}
+
+	if json.Unmarshal(restError.Errors, &fields) == nil {
+		for _, field := range fields {
+			if len(field.Errors) > 0 {
+				return field.Errors[0].Message
+			}
+		}
+	}
+
+	return restError.Message
+}
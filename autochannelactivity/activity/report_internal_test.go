@@ -0,0 +1,32 @@
+package activity
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// TestReporterForgetsOldErrors reports an error per hour, only the last one is kept to not post it again.
+func TestReporterForgetsOldErrors(t *testing.T) {
+	reporter := NewReporter(nil, map[snowflake.ID]snowflake.ID{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+
+	for hour := range 24 {
+		reportedAt := now.Add(time.Duration(hour) * reportInterval)
+
+		if !reporter.shouldPost(snowflake.ID(hour), "Cannot create the channel.", reportedAt) {
+			t.Errorf("expected the error of hour %d to be posted", hour)
+		}
+
+		if reporter.shouldPost(snowflake.ID(hour), "Cannot create the channel.", reportedAt.Add(time.Minute)) {
+			t.Errorf("expected the error of hour %d to be posted once in the interval", hour)
+		}
+	}
+
+	if len(reporter.reported) != 1 {
+		t.Errorf("expected only the error of the last hour to be kept, got %d", len(reporter.reported))
+	}
+}
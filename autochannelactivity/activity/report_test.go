@@ -0,0 +1,39 @@
+package activity_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "database", err: oops.Code(activity.ClassDatabase).Errorf("locked"), expected: true},
+		{name: "rate limit", err: oops.Code(activity.ClassRateLimit).Errorf("429"), expected: true},
+		{name: "permission", err: oops.Code(activity.ClassPermission).Errorf("403"), expected: false},
+		{name: "plain", err: errors.New("failed"), expected: false},
+		{
+			name: "joined with a retryable one",
+			err: errors.Join(
+				oops.Code(activity.ClassValidation).Errorf("400"),
+				oops.Code(activity.ClassRateLimit).Errorf("429"),
+			),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if retryable := activity.Retryable(tt.err); retryable != tt.expected {
+				t.Errorf("expected retryable %t, got %t", tt.expected, retryable)
+			}
+		})
+	}
+}
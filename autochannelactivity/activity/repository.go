@@ -4,18 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
 	"github.com/disgoorg/snowflake/v2"
 	"github.com/gofrs/uuid/v5"
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
 	"github.com/samber/oops"
-)
 
-const MinimumPlayers = 1
-const MinimumHours = 1
-const DayInterval = 1
+	"eggmech/host"
+	"eggmech/host/database"
+)
 
 type Uuidv7 string
 type GuildID snowflake.ID
@@ -26,28 +26,100 @@ type CurrentActivity struct {
 	Name string
 }
 
-type Repository struct {
+// PresenceRepository is what the presence handler needs from the storage.
+type PresenceRepository interface {
+	ApplyPresence(
+		ctx context.Context,
+		event *events.PresenceUpdate,
+		activitiesToClose []CurrentActivity,
+		activitiesToCreate []discord.Activity,
+		now time.Time,
+	) ([]discord.Activity, error)
+	IsOptedOut(ctx context.Context, userID snowflake.ID) (bool, error)
+	CreateChannel(ctx context.Context, channel TrackedChannel) error
+	GetCurrentActivitiesUUID(ctx context.Context, event *events.PresenceUpdate) ([]CurrentActivity, error)
+	HasEnoughActivityUsage(ctx context.Context, guildID snowflake.ID, activityName string) (bool, error)
+	IgnoredChannels(ctx context.Context, guildID snowflake.ID) (map[string]DeletionPolicy, error)
+	GuildTrackedChannels(ctx context.Context, guildID snowflake.ID) ([]TrackedChannel, error)
+}
+
+// TrackedChannel is a channel the bot made for a game and that is not known to be deleted.
+// ActivityName is the slug of the game the channel is stored and ignored by, whatever the naming of the guild,
+// GameName the game as played, empty for the channels made before it was stored, and Name the name given to it.
+type TrackedChannel struct {
+	GuildID        snowflake.ID
+	ChannelID      snowflake.ID
+	ActivityName   string
+	GameName       string
+	Name           string
+	ManuallyPlaced bool
+}
+
+// ChannelRepository is what the handling of the deleted channels needs from the storage.
+type ChannelRepository interface {
+	TrackedChannels(ctx context.Context) ([]TrackedChannel, error)
+	GuildTrackedChannels(ctx context.Context, guildID snowflake.ID) ([]TrackedChannel, error)
+	DeleteChannel(
+		ctx context.Context,
+		guildID snowflake.ID,
+		channelID snowflake.ID,
+		policy DeletionPolicy,
+		now time.Time,
+	) ([]string, error)
+	SetManuallyPlaced(
+		ctx context.Context,
+		guildID snowflake.ID,
+		channelID snowflake.ID,
+		placed bool,
+		now time.Time,
+	) ([]string, error)
+	RenameChannel(ctx context.Context, guildID snowflake.ID, channelID snowflake.ID, name string) error
+}
+
+// Repository stores the sessions and the channels created for them.
+type Repository interface {
+	PresenceRepository
+	ChannelRepository
+	AggregateActivities(ctx context.Context, before time.Time) (int64, error)
+	Close()
+}
+
+// SQLRepository is the Repository of both SQLite and Postgres, queries are written for SQLite
+// and rebound by the dialect.
+type SQLRepository struct {
 	db         *sql.DB
-	Statements map[string]*sql.Stmt
+	dialect    database.Dialect
+	thresholds Thresholds
+	// statementsMu guards Statements, the presences, the audit and the retention use the repository concurrently
+	statementsMu sync.Mutex
+	Statements   map[string]*sql.Stmt
 }
 
-func BuildRepository(db *sql.DB) Repository {
-	return Repository{
+func BuildRepository(db *sql.DB, dialect database.Dialect, thresholds Thresholds) *SQLRepository {
+	return &SQLRepository{
 		db:         db,
+		dialect:    dialect,
+		thresholds: thresholds,
 		Statements: make(map[string]*sql.Stmt),
 	}
 }
 
-func (r *Repository) Close() {
+func (r *SQLRepository) Close() {
+	r.statementsMu.Lock()
+	defer r.statementsMu.Unlock()
+
 	for _, stmt := range r.Statements {
 		_ = stmt.Close()
 	}
 }
 
-func (r *Repository) getStatement(ctx context.Context, name string, query string) (*sql.Stmt, error) {
+func (r *SQLRepository) getStatement(ctx context.Context, name string, query string) (*sql.Stmt, error) {
+	r.statementsMu.Lock()
+	defer r.statementsMu.Unlock()
+
 	if r.Statements[name] == nil {
 		//nolint:sqlclosecheck // statement pool, closed on repository close
-		stmt, err := r.db.PrepareContext(ctx, query)
+		stmt, err := r.db.PrepareContext(ctx, r.dialect.Rebind(query))
 		if err != nil {
 			return nil, oops.Wrapf(err, "failed to prepare statement")
 		}
@@ -58,70 +130,144 @@ func (r *Repository) getStatement(ctx context.Context, name string, query string
 	return r.Statements[name], nil
 }
 
-func (r *Repository) InsertStatement(ctx context.Context) (*sql.Stmt, error) {
-	stmt, err := r.db.PrepareContext(ctx, `INSERT INTO aca_activity
-			(uuid, guild_id, user_id, activity_name, started_at) VALUES (?, ?, ?, ?, ?)`)
+// ApplyPresence closes the finished sessions and opens the new ones of a presence update in one transaction.
+// Timestamps are stored as UTC unix milliseconds, duration is kept in seconds for the usage statistics.
+// Sessions are closed by game rather than by uuid so duplicates left by racing events are closed too,
+// and opening a session already open for the same game is a no-op. The sessions really opened are returned.
+func (r *SQLRepository) ApplyPresence(
+	ctx context.Context,
+	event *events.PresenceUpdate,
+	activitiesToClose []CurrentActivity,
+	activitiesToCreate []discord.Activity,
+	now time.Time,
+) ([]discord.Activity, error) {
+	tx, errTx := r.db.BeginTx(ctx, nil)
+	if errTx != nil {
+		return nil, oops.Wrapf(errTx, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	endedAt := now.UTC().UnixMilli()
+
+	for _, activity := range activitiesToClose {
+		spanCtx, span := startSpan(ctx, "aca.close_session", event, activity.Name)
+		_, errExec := tx.ExecContext(
+			spanCtx,
+			r.dialect.Rebind(`UPDATE aca_activity
+			SET ended_at = ?, duration = `+r.dialect.Greatest()+`(0, (? - started_at) / 1000)
+			WHERE guild_id = ? AND user_id = ? AND activity_name = ? AND ended_at IS NULL`),
+			endedAt,
+			endedAt,
+			event.GuildID,
+			event.PresenceUser.ID,
+			activity.Name,
+		)
+		host.EndSpan(span, errExec)
 
-	if err != nil {
-		return nil, oops.Wrapf(err, "failed to prepare statement")
+		if errExec != nil {
+			return nil, oops.Wrapf(errExec, "can't close activity")
+		}
 	}
 
-	return stmt, nil
-}
+	var createdActivities []discord.Activity
 
-func (r *Repository) CloseActivityStatement(ctx context.Context) (*sql.Stmt, error) {
-	stmt, err := r.db.PrepareContext(ctx, `UPDATE aca_activity 
-		SET duration = 
-			CAST(strftime('%s', CURRENT_TIMESTAMP) as integer) 
-			- CAST(strftime('%s', aca_activity.started_at) as integer) 
-		WHERE uuid = ?`)
-	if err != nil {
-		return nil, oops.Wrapf(err, "failed to prepare statement")
+	for _, activity := range activitiesToCreate {
+		spanCtx, span := startSpan(ctx, "aca.insert_session", event, activity.Name)
+		inserted, errInsert := r.insertActivity(spanCtx, tx, event, activity, now)
+		host.EndSpan(span, errInsert)
+
+		if errInsert != nil {
+			return nil, errInsert
+		}
+
+		if inserted {
+			createdActivities = append(createdActivities, activity)
+		}
 	}
 
-	return stmt, nil
+	errCommit := tx.Commit()
+	if errCommit != nil {
+		return nil, oops.Wrapf(errCommit, "failed to commit transaction")
+	}
+
+	return createdActivities, nil
 }
 
-func (r *Repository) InsertActivity(
+// insertActivity casts the selected values, Postgres cannot infer the type of a bare placeholder.
+func (r *SQLRepository) insertActivity(
 	ctx context.Context,
+	tx *sql.Tx,
 	event *events.PresenceUpdate,
 	activity discord.Activity,
-) error {
-	//nolint:sqlclosecheck // statement pool
-	stmt, errStmt := r.getStatement(ctx, "insert_activity", `INSERT INTO aca_activity
-			(uuid, guild_id, user_id, activity_name, started_at) VALUES (?, ?, ?, ?, ?)`)
-
-	if errStmt != nil {
-		return oops.Wrapf(errStmt, "can't get statement for insert activity")
-	}
-
+	now time.Time,
+) (bool, error) {
 	uuidv7, errUUID := uuid.NewV7()
 	if errUUID != nil {
-		return oops.Wrapf(errUUID, "failed to generate uuid for insert activity")
+		return false, oops.Wrapf(errUUID, "failed to generate uuid for insert activity")
 	}
 
-	_, errExec := stmt.ExecContext(
+	startedAt := sessionStart(activity, now)
+
+	result, errExec := tx.ExecContext(
 		ctx,
+		r.dialect.Rebind(`INSERT INTO aca_activity
+			(uuid, guild_id, user_id, activity_name, started_at)
+			SELECT CAST(? AS varchar(36)),
+			       CAST(? AS bigint),
+			       CAST(? AS bigint),
+			       CAST(? AS varchar(256)),
+			       CAST(? AS bigint)
+			WHERE NOT EXISTS (SELECT 1 FROM aca_privacy_optout WHERE user_id = ?)
+			ON CONFLICT (guild_id, user_id, activity_name) WHERE ended_at IS NULL DO NOTHING`),
 		uuidv7,
 		event.GuildID,
 		event.PresenceUser.ID,
 		activity.Name,
-		activity.CreatedAt,
+		startedAt.UTC().UnixMilli(),
+		event.PresenceUser.ID,
 	)
 
 	if errExec != nil {
-		return oops.Wrapf(errExec, "can't insert activity")
+		return false, oops.Wrapf(errExec, "can't insert activity")
 	}
 
-	return nil
+	insertedRows, _ := result.RowsAffected()
+
+	return insertedRows > 0, nil
 }
 
-func (r *Repository) CreateChannel(
-	ctx context.Context,
-	guildID snowflake.ID,
-	channelID snowflake.ID,
-	channel string,
-) error {
+// sessionStart trusts the activity start sent by Discord unless it is missing or in the future.
+func sessionStart(activity discord.Activity, now time.Time) time.Time {
+	if activity.CreatedAt.IsZero() || activity.CreatedAt.After(now) {
+		return now
+	}
+
+	return activity.CreatedAt
+}
+
+func (r *SQLRepository) IsOptedOut(ctx context.Context, userID snowflake.ID) (bool, error) {
+	//nolint:sqlclosecheck // statement pool
+	stmt, errStmt := r.getStatement(
+		ctx,
+		"is_opted_out",
+		`SELECT EXISTS (SELECT 1 FROM aca_privacy_optout WHERE user_id = ?)`,
+	)
+
+	if errStmt != nil {
+		return false, oops.Wrapf(errStmt, "can't get statement for is opted out")
+	}
+
+	var optedOut bool
+
+	errScan := stmt.QueryRowContext(ctx, userID).Scan(&optedOut)
+	if errScan != nil {
+		return false, oops.Wrapf(errScan, "can't get opt-out for user")
+	}
+
+	return optedOut, nil
+}
+
+func (r *SQLRepository) CreateChannel(ctx context.Context, channel TrackedChannel) error {
 	//nolint:sqlclosecheck // statement pool
 	stmtGet, errStmtGet := r.getStatement(
 		ctx,
@@ -135,8 +281,8 @@ func (r *Repository) CreateChannel(
 
 	row := stmtGet.QueryRowContext(
 		ctx,
-		guildID,
-		channelID,
+		channel.GuildID,
+		channel.ChannelID,
 	)
 
 	var uuidv7ForSettings Uuidv7
@@ -169,11 +315,11 @@ func (r *Repository) CreateChannel(
 		_, errExec := stmt.ExecContext(
 			ctx,
 			uuidv7ForSettings,
-			guildID,
-			channelID,
-			MinimumPlayers,
-			MinimumHours,
-			DayInterval,
+			channel.GuildID,
+			channel.ChannelID,
+			r.thresholds.MinimumPlayers,
+			r.thresholds.MinimumHours,
+			r.thresholds.DayInterval,
 		)
 
 		if errExec != nil {
@@ -186,8 +332,8 @@ func (r *Repository) CreateChannel(
 		ctx,
 		"insert_activity_channel",
 		`INSERT INTO aca_activity_channel
-			(uuid, activity_settings_uuid, activity_name) 
-			VALUES (?, ?, ?)`,
+			(uuid, activity_settings_uuid, activity_name, game_name, channel_name)
+			VALUES (?, ?, ?, ?, ?)`,
 	)
 
 	if errStmt != nil {
@@ -203,7 +349,9 @@ func (r *Repository) CreateChannel(
 		ctx,
 		uuidv7ForChannel,
 		uuidv7ForSettings,
-		channel,
+		channel.ActivityName,
+		channel.GameName,
+		channel.Name,
 	)
 
 	if errExec != nil {
@@ -213,12 +361,314 @@ func (r *Repository) CreateChannel(
 	return nil
 }
 
-func (r *Repository) GetCurrentActivitiesUUID(
+// IgnoredChannels are the names of the channels of guildID the bot no longer makes, with the policy they were
+// deleted under.
+func (r *SQLRepository) IgnoredChannels(ctx context.Context, guildID snowflake.ID) (map[string]DeletionPolicy, error) {
+	//nolint:sqlclosecheck // statement pool
+	stmt, errStmt := r.getStatement(
+		ctx,
+		"ignored_channels",
+		`SELECT channel_name, policy FROM aca_activity_channel_ignored WHERE guild_id = ?`,
+	)
+
+	if errStmt != nil {
+		return nil, oops.Wrapf(errStmt, "can't get statement for ignored channels")
+	}
+
+	rows, err := stmt.QueryContext(ctx, guildID)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to execute query")
+	}
+	defer rows.Close()
+
+	ignored := map[string]DeletionPolicy{}
+
+	for rows.Next() {
+		var name string
+
+		var policy DeletionPolicy
+
+		err = rows.Scan(&name, &policy)
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to scan row")
+		}
+
+		ignored[name] = policy
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to fetch rows")
+	}
+
+	return ignored, nil
+}
+
+// trackedChannelColumns are the columns scanTrackedChannel reads, from aca_activity_channel aac joined to its
+// aca_activity_settings aas.
+const trackedChannelColumns = `aas.guild_id, aas.channel_id, aac.activity_name, COALESCE(aac.game_name, ''),
+	COALESCE(aac.channel_name, aac.activity_name), aac.manually_placed_at IS NOT NULL`
+
+func scanTrackedChannel(row interface{ Scan(dest ...any) error }) (TrackedChannel, error) {
+	var guildID, channelID int64
+
+	var channel TrackedChannel
+
+	err := row.Scan(
+		&guildID,
+		&channelID,
+		&channel.ActivityName,
+		&channel.GameName,
+		&channel.Name,
+		&channel.ManuallyPlaced,
+	)
+	if err != nil {
+		return TrackedChannel{}, err
+	}
+
+	channel.GuildID = snowflake.ID(guildID)
+	channel.ChannelID = snowflake.ID(channelID)
+
+	return channel, nil
+}
+
+func (r *SQLRepository) TrackedChannels(ctx context.Context) ([]TrackedChannel, error) {
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(`SELECT `+trackedChannelColumns+`
+		FROM aca_activity_channel aac
+		JOIN aca_activity_settings aas ON (aas.uuid = aac.activity_settings_uuid)
+		WHERE aac.deleted_at IS NULL AND aas.channel_id != 0`))
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to execute query")
+	}
+
+	return scanTrackedChannels(rows)
+}
+
+// GuildTrackedChannels are the tracked channels of guildID, oldest first.
+func (r *SQLRepository) GuildTrackedChannels(ctx context.Context, guildID snowflake.ID) ([]TrackedChannel, error) {
+	//nolint:sqlclosecheck // statement pool
+	stmt, errStmt := r.getStatement(
+		ctx,
+		"guild_tracked_channels",
+		`SELECT `+trackedChannelColumns+`
+			FROM aca_activity_channel aac
+			JOIN aca_activity_settings aas ON (aas.uuid = aac.activity_settings_uuid)
+			WHERE aas.guild_id = ? AND aas.channel_id != 0 AND aac.deleted_at IS NULL
+			ORDER BY aac.uuid`,
+	)
+
+	if errStmt != nil {
+		return nil, oops.Wrapf(errStmt, "can't get statement for guild tracked channels")
+	}
+
+	rows, err := stmt.QueryContext(ctx, guildID)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to execute query")
+	}
+
+	return scanTrackedChannels(rows)
+}
+
+func scanTrackedChannels(rows *sql.Rows) ([]TrackedChannel, error) {
+	defer rows.Close()
+
+	var channels []TrackedChannel
+
+	for rows.Next() {
+		channel, errScan := scanTrackedChannel(rows)
+		if errScan != nil {
+			return nil, oops.Wrapf(errScan, "failed to scan row")
+		}
+
+		channels = append(channels, channel)
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to fetch rows")
+	}
+
+	return channels, nil
+}
+
+// RenameChannel stores the name Discord now has for the tracked channels of channelID.
+func (r *SQLRepository) RenameChannel(
+	ctx context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	name string,
+) error {
+	_, err := r.db.ExecContext(ctx, r.dialect.Rebind(`UPDATE aca_activity_channel SET channel_name = ?
+		WHERE deleted_at IS NULL
+		  AND activity_settings_uuid IN (SELECT uuid FROM aca_activity_settings WHERE guild_id = ? AND channel_id = ?)`),
+		name,
+		guildID,
+		channelID,
+	)
+	if err != nil {
+		return oops.Wrapf(err, "can't rename channel")
+	}
+
+	return nil
+}
+
+// DeleteChannel marks the channels stored for channelID as deleted and returns their names, which are ignored from
+// now on unless policy is DeletionRecreate. Deleting a channel that is not tracked returns no name.
+func (r *SQLRepository) DeleteChannel(
+	ctx context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	policy DeletionPolicy,
+	now time.Time,
+) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	names, err := r.deletedChannelNames(ctx, tx, guildID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, r.dialect.Rebind(`UPDATE aca_activity_channel SET deleted_at = ?
+		WHERE deleted_at IS NULL
+		  AND activity_settings_uuid IN (SELECT uuid FROM aca_activity_settings WHERE guild_id = ? AND channel_id = ?)`),
+		now.UTC().UnixMilli(),
+		guildID,
+		channelID,
+	)
+	if err != nil {
+		return nil, oops.Wrapf(err, "can't mark channel deleted")
+	}
+
+	for _, name := range names {
+		if policy == DeletionRecreate {
+			break
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.Rebind(`INSERT INTO aca_activity_channel_ignored
+			(guild_id, channel_name, policy, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (guild_id, channel_name) DO UPDATE SET policy = excluded.policy`),
+			guildID,
+			name,
+			policy,
+			now.UTC().UnixMilli(),
+		)
+		if err != nil {
+			return nil, oops.Wrapf(err, "can't ignore channel")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to commit transaction")
+	}
+
+	return names, nil
+}
+
+func (r *SQLRepository) deletedChannelNames(
+	ctx context.Context,
+	tx *sql.Tx,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, r.dialect.Rebind(`SELECT aac.activity_name
+		FROM aca_activity_channel aac
+		JOIN aca_activity_settings aas ON (aas.uuid = aac.activity_settings_uuid)
+		WHERE aas.guild_id = ? AND aas.channel_id = ? AND aac.deleted_at IS NULL`),
+		guildID,
+		channelID,
+	)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to execute query")
+	}
+	defer rows.Close()
+
+	var names []string
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to scan row")
+		}
+
+		names = append(names, name)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to fetch rows")
+	}
+
+	return names, nil
+}
+
+// SetManuallyPlaced marks the tracked channels stored for channelID as placed by hand, or placed by the bot again,
+// and returns the names of the ones that changed.
+func (r *SQLRepository) SetManuallyPlaced(
+	ctx context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	placed bool,
+	now time.Time,
+) ([]string, error) {
+	var placedAt *int64
+
+	condition := `manually_placed_at IS NOT NULL`
+
+	if placed {
+		millis := now.UTC().UnixMilli()
+		placedAt = &millis
+		condition = `manually_placed_at IS NULL`
+	}
+
+	//nolint:gosec // only a fixed condition is concatenated
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(`UPDATE aca_activity_channel SET manually_placed_at = ?
+		WHERE deleted_at IS NULL AND `+condition+`
+		  AND activity_settings_uuid IN (SELECT uuid FROM aca_activity_settings WHERE guild_id = ? AND channel_id = ?)
+		RETURNING activity_name`),
+		placedAt,
+		guildID,
+		channelID,
+	)
+	if err != nil {
+		return nil, oops.Wrapf(err, "can't set channel placement")
+	}
+	defer rows.Close()
+
+	var names []string
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to scan row")
+		}
+
+		names = append(names, name)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to fetch rows")
+	}
+
+	return names, nil
+}
+
+func (r *SQLRepository) GetCurrentActivitiesUUID(
 	ctx context.Context,
 	event *events.PresenceUpdate,
 ) ([]CurrentActivity, error) {
-	stmt, err := r.db.PrepareContext(ctx, `SELECT uuid, activity_name 
-		FROM aca_activity WHERE guild_id = ? AND user_id = ? AND duration = 0`)
+	stmt, err := r.db.PrepareContext(ctx, r.dialect.Rebind(`SELECT uuid, activity_name 
+		FROM aca_activity WHERE guild_id = ? AND user_id = ? AND ended_at IS NULL`))
 	if err != nil {
 		return nil, oops.Wrapf(err, "failed to prepare statement")
 	}
@@ -258,52 +708,167 @@ func (r *Repository) GetCurrentActivitiesUUID(
 	return currentActivities, nil
 }
 
-func (r *Repository) HasEnoughActivityUsage(ctx context.Context, activityName string) (bool, error) {
-	stmt, errPrepare := r.db.PrepareContext(ctx, `WITH activities AS (
-    SELECT SUM(duration) AS duration,
-           count(distinct user_id) AS players,
-           CASE WHEN aas.uuid IS NOT NULL THEN aas.minimum_hours ELSE ? END * 1 AS minimum_seconds,
-           CASE WHEN aas.uuid IS NOT NULL THEN aas.minimum_players ELSE ? END AS minimum_players
-    FROM aca_activity aa
-    LEFT JOIN aca_activity_settings AS aas
-        ON (aas.guild_id = aa.guild_id)
-    LEFT JOIN aca_activity_channel AS aac ON (aac.activity_settings_uuid = aas.uuid AND aac.activity_name = aa.activity_name)
-    WHERE
-        aa.started_at > DATE(
-                'now',
-                '-' || CASE WHEN aas.uuid IS NOT NULL THEN aas.day_interval ELSE ? END || ' day'
-                        )
-      AND aa.activity_name = ?
-    GROUP BY aa.activity_name)
-SELECT 1 FROM activities WHERE players >= minimum_players AND duration >= minimum_seconds`)
+// HasEnoughActivityUsage tells if activityName was played long enough by enough distinct players of guildID, over the
+// day interval of its channel settings or of the guild ones. The players are distinct over the whole interval, across
+// the open sessions and the aggregated days.
+func (r *SQLRepository) HasEnoughActivityUsage(
+	ctx context.Context,
+	guildID snowflake.ID,
+	activityName string,
+) (bool, error) {
+	startedSince := `aa.started_at > (` + r.dialect.UnixNow() + ` - settings.day_interval * 86400) * 1000`
+	daySince := r.dialect.DaysAgo("settings.day_interval")
+
+	//nolint:gosec // only dialect fragments are concatenated
+	stmt, errPrepare := r.db.PrepareContext(ctx, r.dialect.Rebind(`WITH settings AS (
+    SELECT COALESCE(MAX(aas.minimum_hours), ?) * 1 AS minimum_seconds,
+           COALESCE(MAX(aas.minimum_players), ?) AS minimum_players,
+           COALESCE(MAX(aas.day_interval), ?) AS day_interval
+    FROM aca_activity_settings aas
+    WHERE aas.uuid = (
+        SELECT s.uuid
+        FROM aca_activity_settings s
+        WHERE s.guild_id = ?
+          AND (s.channel_id = 0 OR EXISTS (
+              SELECT 1
+              FROM aca_activity_channel aac
+              WHERE aac.activity_settings_uuid = s.uuid AND aac.activity_name = ? AND aac.deleted_at IS NULL))
+        ORDER BY s.channel_id DESC
+        LIMIT 1)),
+players AS (
+    SELECT aa.user_id
+    FROM aca_activity aa, settings
+    WHERE aa.guild_id = ? AND aa.activity_name = ? AND `+startedSince+`
+    UNION
+    SELECT aadp.user_id
+    FROM aca_activity_daily_player aadp, settings
+    WHERE aadp.guild_id = ? AND aadp.activity_name = ? AND aadp.day >= `+daySince+`),
+durations AS (
+    SELECT SUM(aa.duration) AS duration
+    FROM aca_activity aa, settings
+    WHERE aa.guild_id = ? AND aa.activity_name = ? AND `+startedSince+`
+    UNION ALL
+    SELECT SUM(aad.total_seconds) AS duration
+    FROM aca_activity_daily aad, settings
+    WHERE aad.guild_id = ? AND aad.activity_name = ? AND aad.day >= `+daySince+`)
+SELECT 1 FROM settings
+WHERE (SELECT COUNT(*) FROM players) >= settings.minimum_players
+  AND (SELECT COALESCE(SUM(duration), 0) FROM durations) >= settings.minimum_seconds`))
 	if errPrepare != nil {
 		return false, oops.Wrapf(errPrepare, "failed to prepare statement")
 	}
 	defer stmt.Close()
 
-	rows, errQuery := stmt.QueryContext(ctx, MinimumPlayers, MinimumHours, DayInterval, activityName)
-	if errQuery != nil {
-		return false, oops.Wrapf(errQuery, "failed to execute query")
+	var hasEnough uint
+
+	errScan := stmt.QueryRowContext(
+		ctx,
+		r.thresholds.MinimumHours,
+		r.thresholds.MinimumPlayers,
+		r.thresholds.DayInterval,
+		guildID,
+		ActivityKey(activityName),
+		guildID,
+		activityName,
+		guildID,
+		activityName,
+		guildID,
+		activityName,
+		guildID,
+		activityName,
+	).Scan(&hasEnough)
+
+	if errors.Is(errScan, sql.ErrNoRows) {
+		return false, nil
 	}
-	defer rows.Close()
 
-	if rows.Err() != nil {
-		return false, oops.Wrapf(rows.Err(), "failed to fetch rows")
+	if errScan != nil {
+		return false, oops.Wrapf(errScan, "failed to execute query")
 	}
 
-	hasRow := rows.Next()
+	return true, nil
+}
 
-	if !hasRow {
-		return false, nil
+// AggregateActivities rolls the closed sessions started before the given day into aca_activity_daily
+// and deletes them. The players of each day are kept in aca_activity_daily_player so a day aggregated twice
+// (a session closed after the first run) counts them again instead of adding them up, they are pruned once older
+// than the longest day interval.
+func (r *SQLRepository) AggregateActivities(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to begin transaction")
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	var hasEnough uint
+	year, month, day := before.UTC().Date()
+	beforeDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).UnixMilli()
 
-	errScan := rows.Scan(&hasEnough)
+	startedDay := r.dialect.DayOfMillis("started_at")
 
-	if errScan != nil {
-		return false, oops.Wrapf(rows.Err(), "failed to scan row")
+	_, err = tx.ExecContext(ctx, r.dialect.Rebind(`INSERT INTO aca_activity_daily_player
+			(guild_id, activity_name, day, user_id)
+		SELECT DISTINCT guild_id, activity_name, `+startedDay+`, user_id
+		FROM aca_activity
+		WHERE ended_at IS NOT NULL AND started_at < ?
+		ON CONFLICT (guild_id, activity_name, day, user_id) DO NOTHING`), beforeDay)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to aggregate players")
 	}
 
-	return true, nil
+	// WHERE true keeps SQLite from reading ON CONFLICT as a join constraint
+	_, err = tx.ExecContext(ctx, r.dialect.Rebind(`INSERT INTO aca_activity_daily
+			(guild_id, activity_name, day, players, sessions, total_seconds)
+		SELECT s.guild_id,
+		       s.activity_name,
+		       s.day,
+		       (SELECT count(*)
+		        FROM aca_activity_daily_player aadp
+		        WHERE aadp.guild_id = s.guild_id AND aadp.activity_name = s.activity_name AND aadp.day = s.day),
+		       s.sessions,
+		       s.total_seconds
+		FROM (SELECT guild_id,
+		             activity_name,
+		             `+startedDay+` AS day,
+		             count(*) AS sessions,
+		             SUM(duration) AS total_seconds
+		      FROM aca_activity
+		      WHERE ended_at IS NOT NULL AND started_at < ?
+		      GROUP BY guild_id, activity_name, `+startedDay+`) AS s
+		WHERE true
+		ON CONFLICT (guild_id, activity_name, day) DO UPDATE SET
+			players = excluded.players,
+			sessions = aca_activity_daily.sessions + excluded.sessions,
+			total_seconds = aca_activity_daily.total_seconds + excluded.total_seconds`), beforeDay)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to aggregate activities")
+	}
+
+	longestInterval := r.dialect.Greatest() + `(COALESCE((SELECT MAX(day_interval) FROM aca_activity_settings), 0), ?)`
+
+	_, err = tx.ExecContext(
+		ctx,
+		r.dialect.Rebind(`DELETE FROM aca_activity_daily_player WHERE day < `+r.dialect.DaysAgo(longestInterval)),
+		r.thresholds.DayInterval,
+	)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to prune aggregated players")
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		r.dialect.Rebind(`DELETE FROM aca_activity WHERE ended_at IS NOT NULL AND started_at < ?`),
+		beforeDay,
+	)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to delete aggregated activities")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to commit transaction")
+	}
+
+	deletedRows, _ := result.RowsAffected()
+
+	return deletedRows, nil
 }
@@ -0,0 +1,46 @@
+package activity
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/samber/oops"
+)
+
+const RetentionDays = 30
+const retentionInterval = time.Hour
+
+// RetentionJob aggregates the sessions older than retentionDays every hour until ctx is done.
+func RetentionJob(ctx context.Context, repo Repository, retentionDays int, logger *slog.Logger) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		runRetention(ctx, repo, retentionDays, logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runRetention(ctx context.Context, repo Repository, retentionDays int, logger *slog.Logger) {
+	before := time.Now().UTC().AddDate(0, 0, -retentionDays)
+
+	aggregatedRows, err := repo.AggregateActivities(ctx, before)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to aggregate activities", slog.Any("error", oops.Wrap(err)))
+
+		return
+	}
+
+	logger.InfoContext(
+		ctx,
+		"activities aggregated",
+		slog.Int64("rows", aggregatedRows),
+		slog.String("before", before.Format(time.DateOnly)),
+	)
+}
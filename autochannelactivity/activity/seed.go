@@ -0,0 +1,118 @@
+package activity
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/host"
+)
+
+// seedNonce prefixes the nonce of the member requests, to tell their chunks from the ones of other requests.
+const seedNonce = "aca-seed-"
+
+// seedQueue is the number of chunks waiting to be seeded before the gateway waits on the seeding.
+const seedQueue = 64
+
+// SeedListener requests the members of every guild that becomes ready or is joined with their presences,
+// and hands each presence to onPresenceUpdate, so the sessions started while the bot was away are opened.
+// The members without a presence are offline, they are handed an offline presence to close their sessions.
+func SeedListener(
+	ctx context.Context,
+	onPresenceUpdate func(event *events.PresenceUpdate),
+	logger *slog.Logger,
+) bot.EventListener {
+	chunks := make(chan *host.GuildMembersChunk, seedQueue)
+
+	go seedChunks(ctx, chunks, onPresenceUpdate)
+
+	return bot.NewListenerFunc(func(event bot.Event) {
+		switch event := event.(type) {
+		case *events.GuildReady:
+			requestPresences(ctx, event.GenericGuild, logger)
+		case *events.GuildJoin:
+			requestPresences(ctx, event.GenericGuild, logger)
+		case *host.GuildMembersChunk:
+			if !strings.HasPrefix(event.Nonce, seedNonce) {
+				return
+			}
+
+			logger.DebugContext(
+				ctx,
+				"seeding sessions",
+				slog.Any("guild_id", event.GuildID),
+				slog.Int("chunk", event.ChunkIndex),
+				slog.Int("presences", len(event.Presences)),
+			)
+
+			select {
+			case chunks <- event:
+			case <-ctx.Done():
+			}
+		}
+	})
+}
+
+func requestPresences(ctx context.Context, event *events.GenericGuild, logger *slog.Logger) {
+	err := event.Client().RequestMembersWithQuery(ctx, event.GuildID, true, seedNonce+event.GuildID.String(), "", 0)
+	if err != nil {
+		logger.WarnContext(
+			ctx,
+			"cannot request the presences to seed sessions",
+			slog.Any("guild_id", event.GuildID),
+			slog.Any("error", oops.Wrap(err)),
+		)
+	}
+}
+
+// seedChunks seeds the chunks one after the other outside the gateway goroutine, a chunk holds up to 1000 members.
+// Seeding them concurrently would race on the channels of the same games.
+func seedChunks(
+	ctx context.Context,
+	chunks <-chan *host.GuildMembersChunk,
+	onPresenceUpdate func(event *events.PresenceUpdate),
+) {
+	for {
+		select {
+		case chunk := <-chunks:
+			seed(chunk, onPresenceUpdate)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func seed(chunk *host.GuildMembersChunk, onPresenceUpdate func(event *events.PresenceUpdate)) {
+	present := make(map[snowflake.ID]bool, len(chunk.Presences))
+
+	for _, presence := range chunk.Presences {
+		present[presence.PresenceUser.ID] = true
+	}
+
+	presences := chunk.Presences
+
+	for _, member := range chunk.Members {
+		if !present[member.User.ID] {
+			presences = append(presences, discord.Presence{
+				PresenceUser: discord.PresenceUser{ID: member.User.ID},
+				Status:       discord.OnlineStatusOffline,
+			})
+		}
+	}
+
+	for _, presence := range presences {
+		presence.GuildID = chunk.GuildID
+
+		onPresenceUpdate(&events.PresenceUpdate{
+			GenericEvent:        chunk.GenericEvent,
+			EventPresenceUpdate: gateway.EventPresenceUpdate{Presence: presence},
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package activity_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/host"
+)
+
+// TestSeedListener hands the chunks of a seed request one after the other, with an offline presence for the members
+// without one, while the chunks of other requests are left alone.
+func TestSeedListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var inFlight atomic.Int32
+
+	seeded := make(chan *events.PresenceUpdate)
+	listener := activity.SeedListener(ctx, func(event *events.PresenceUpdate) {
+		if inFlight.Add(1) > 1 {
+			t.Error("presences seeded concurrently")
+		}
+		defer inFlight.Add(-1)
+
+		time.Sleep(time.Millisecond)
+		seeded <- event
+	}, discardLogger())
+
+	chunk := func(nonce string, index int, online snowflake.ID, offline snowflake.ID) *host.GuildMembersChunk {
+		return &host.GuildMembersChunk{
+			GenericEvent: events.NewGenericEvent(nil, 0, 0),
+			EventGuildMembersChunk: gateway.EventGuildMembersChunk{
+				GuildID:    guildID,
+				ChunkIndex: index,
+				Nonce:      nonce,
+				Members: []discord.Member{
+					{User: discord.User{ID: online}},
+					{User: discord.User{ID: offline}},
+				},
+				Presences: []discord.Presence{{
+					PresenceUser: discord.PresenceUser{ID: online},
+					Status:       discord.OnlineStatusOnline,
+					Activities:   []discord.Activity{{Name: game, Type: discord.ActivityTypeGame}},
+				}},
+			},
+		}
+	}
+
+	nonce := "aca-seed-" + snowflake.ID(guildID).String()
+	listener.OnEvent(chunk(nonce, 0, 100, 101))
+	listener.OnEvent(chunk("other", 0, 200, 201))
+	listener.OnEvent(chunk(nonce, 1, 102, 103))
+
+	expected := []struct {
+		userID snowflake.ID
+		status discord.OnlineStatus
+	}{
+		{100, discord.OnlineStatusOnline},
+		{101, discord.OnlineStatusOffline},
+		{102, discord.OnlineStatusOnline},
+		{103, discord.OnlineStatusOffline},
+	}
+
+	for _, want := range expected {
+		select {
+		case event := <-seeded:
+			if event.PresenceUser.ID != want.userID || event.Status != want.status || event.GuildID != guildID {
+				t.Errorf(
+					"expected %s %s in guild %d, got %s %s in guild %d",
+					want.userID, want.status, guildID, event.PresenceUser.ID, event.Status, event.GuildID,
+				)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("presence of %s not seeded", want.userID)
+		}
+	}
+
+	select {
+	case event := <-seeded:
+		t.Errorf("unexpected presence of %s", event.PresenceUser.ID)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
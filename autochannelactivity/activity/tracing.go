@@ -0,0 +1,36 @@
+package activity
+
+import (
+	"context"
+
+	"github.com/disgoorg/disgo/events"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"eggmech/host"
+)
+
+const tracerName = "eggmech/autochannelactivity/activity"
+
+// startSpan starts a span with the guild and the keyed hash of the user of event, and game when set.
+func startSpan(
+	ctx context.Context,
+	name string,
+	event *events.PresenceUpdate,
+	game string,
+) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("guild.id", event.GuildID.String()),
+	}
+
+	if userHash := host.UserHash(event.PresenceUser.ID); userHash != "" {
+		attributes = append(attributes, attribute.String("user.hash", userHash))
+	}
+
+	if game != "" {
+		attributes = append(attributes, attribute.String("game.name", game))
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
@@ -0,0 +1,97 @@
+package activity
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"eggmech/host"
+)
+
+// TestStartSpanLeavesUserOut ends a span and a failed one, the user ID must not reach them, only its keyed hash when
+// a key is set.
+func TestStartSpanLeavesUserOut(t *testing.T) {
+	const tracedUserID snowflake.ID = 123456789012345678
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "without key"},
+		{name: "with key", key: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			previous := otel.GetTracerProvider()
+			otel.SetTracerProvider(provider)
+			host.SetUserHashKey(tt.key)
+			t.Cleanup(func() {
+				otel.SetTracerProvider(previous)
+				host.SetUserHashKey("")
+			})
+
+			event := &events.PresenceUpdate{
+				EventPresenceUpdate: gateway.EventPresenceUpdate{
+					Presence: discord.Presence{
+						PresenceUser: discord.PresenceUser{ID: tracedUserID},
+						GuildID:      1,
+					},
+				},
+			}
+
+			_, span := startSpan(context.Background(), "aca.presence", event, "Dota 2")
+			host.EndSpan(span, nil)
+
+			_, span = startSpan(context.Background(), "aca.apply_presence", event, "Dota 2")
+			host.EndSpan(span, databaseError(errorBuilder(event, "apply presence"), errors.New("locked"), "failed"))
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("expected 2 spans, got %d", len(spans))
+			}
+
+			expectedHash := ""
+
+			if tt.key != "" {
+				mac := hmac.New(sha256.New, []byte(tt.key))
+				mac.Write([]byte(tracedUserID.String()))
+				expectedHash = hex.EncodeToString(mac.Sum(nil)[:8])
+			}
+
+			for _, ended := range spans {
+				userHash := ""
+
+				for _, kv := range ended.Attributes() {
+					if strings.Contains(kv.Value.Emit(), tracedUserID.String()) {
+						t.Errorf("span %s has the user ID in %s", ended.Name(), kv.Key)
+					}
+
+					if kv.Key == attribute.Key("user.hash") {
+						userHash = kv.Value.AsString()
+					}
+				}
+
+				if userHash != expectedHash {
+					t.Errorf("expected user.hash %q on span %s, got %q", expectedHash, ended.Name(), userHash)
+				}
+			}
+		})
+	}
+}
@@ -2,30 +2,177 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log/slog"
 	"os"
 
+	"github.com/disgoorg/disgo/bot"
 	"github.com/google/gops/agent"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/samber/oops"
 
 	"eggmech/autochannelactivity"
+	"eggmech/autochannelactivity/privacy"
+	"eggmech/autochannelactivity/replay"
+	"eggmech/host"
 )
 
+type options struct {
+	configFile   string
+	printConfig  bool
+	record       string
+	replay       string
+	replayGolden string
+	updateGolden bool
+}
+
+func parseOptions() options {
+	var o options
+
+	flag.StringVar(&o.configFile, "config", os.Getenv("EGGMECH_CONFIG"), "optional YAML configuration file")
+	flag.BoolVar(&o.printConfig, "print-config", false, "print the configuration with secrets redacted, then exit")
+	flag.StringVar(&o.record, "record", "", "append the presence, guild and channel events to this JSONL file")
+	flag.StringVar(&o.replay, "replay", "", "replay a -record file on a scratch database and print the report")
+	flag.StringVar(&o.replayGolden, "replay-golden", "", "with -replay, compare the report with this file")
+	flag.BoolVar(&o.updateGolden, "update-golden", false, "with -replay-golden, rewrite the file")
+	flag.Parse()
+
+	return o
+}
+
 func main() {
 	ctx := context.Background()
+	o := parseOptions()
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		AddSource: true,
 	}))
 
-	if err := agent.Listen(agent.Options{}); err != nil {
+	handled, err := runTool(ctx, o, logger)
+	if err != nil {
+		logger.ErrorContext(ctx, "tool failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if handled {
+		return
+	}
+
+	config, err := host.LoadConfig(o.configFile, os.Getenv)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	modules, err := enabledModules(config, os.Getenv)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load modules", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	err = config.ValidateSections(modules)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if o.printConfig {
+		err = host.PrintConfig(os.Stdout, config, modules)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to print configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if err = agent.Listen(agent.Options{}); err != nil {
 		logger.ErrorContext(ctx, "error creating gops agent", slog.Any("error", err))
 	}
 
-	err := autochannelactivity.Run(ctx, os.Getenv, logger)
+	opts, closeRecording, err := recordingOptions(ctx, o.record, config.Database, logger)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to open recording", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer closeRecording()
 
+	err = host.Run(ctx, config, modules, logger, opts...)
 	if err != nil {
 		logger.ErrorContext(ctx, "failed to start server", slog.Any("error", err))
-		os.Exit(1)
+		os.Exit(1) //nolint:gocritic // the recording is flushed on every entry
+	}
+}
+
+// recordingOptions record the gateway events to file, the database tells the users left out of the recording.
+func recordingOptions(
+	ctx context.Context,
+	file string,
+	databaseConfig host.DatabaseConfig,
+	logger *slog.Logger,
+) ([]bot.ConfigOpt, func(), error) {
+	if file == "" {
+		return nil, func() {}, nil
+	}
+
+	dialect, err := databaseConfig.Dialect()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := dialect.Open(databaseConfig.DSN())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recording, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, nil, oops.Wrapf(err, "failed to open recording file")
 	}
+
+	recorder := replay.NewRecorder(ctx, recording, &privacy.Repository{DB: db, Dialect: dialect}, logger)
+
+	return recorder.Options(), func() {
+		_ = recording.Close()
+		_ = db.Close()
+	}, nil
+}
+
+// enabledModules builds the modules listed in the configuration, every known module is enabled when it is empty.
+func enabledModules(config host.Config, getenv func(string) string) ([]host.Module, error) {
+	constructors := map[string]func(config host.Config, getenv func(string) string) (host.Module, error){
+		autochannelactivity.Name: func(config host.Config, getenv func(string) string) (host.Module, error) {
+			moduleConfig, err := autochannelactivity.LoadConfig(config, getenv)
+			if err != nil {
+				return nil, err
+			}
+
+			return autochannelactivity.New(moduleConfig), nil
+		},
+	}
+
+	names := config.Modules
+	if len(names) == 0 {
+		names = []string{autochannelactivity.Name}
+	}
+
+	modules := make([]host.Module, 0, len(names))
+
+	for _, name := range names {
+		constructor, ok := constructors[name]
+		if !ok {
+			return nil, oops.Errorf("unknown module %q", name)
+		}
+
+		module, err := constructor(config, getenv)
+		if err != nil {
+			return nil, oops.With("module", name).Wrapf(err, "failed to create module")
+		}
+
+		modules = append(modules, module)
+	}
+
+	return modules, nil
 }
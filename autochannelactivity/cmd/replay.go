@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity"
+	"eggmech/autochannelactivity/replay"
+	"eggmech/host"
+)
+
+// runReplay prints the report of a recording, or compares it with the golden file.
+// The configuration is not validated, a replay never connects to Discord. Logs go to stderr.
+func runReplay(ctx context.Context, o options, logger *slog.Logger) error {
+	hostConfig, err := host.ReadConfig(o.configFile, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	config, err := autochannelactivity.LoadConfig(hostConfig, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	recording, err := os.Open(o.replay)
+	if err != nil {
+		return oops.Wrapf(err, "failed to open recording")
+	}
+	defer recording.Close()
+
+	var report bytes.Buffer
+
+	err = replay.Replay(ctx, recording, &report, config, slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case o.replayGolden == "":
+		_, err = os.Stdout.Write(report.Bytes())
+
+		return oops.Wrapf(err, "failed to print report")
+	case o.updateGolden:
+		logger.InfoContext(ctx, "golden report updated", slog.String("file", o.replayGolden))
+
+		return oops.Wrapf(os.WriteFile(o.replayGolden, report.Bytes(), 0o600), "failed to write golden report")
+	}
+
+	golden, err := os.ReadFile(o.replayGolden)
+	if err != nil {
+		return oops.Wrapf(err, "failed to read golden report")
+	}
+
+	if !bytes.Equal(golden, report.Bytes()) {
+		return oops.
+			With("file", o.replayGolden).
+			Errorf("replay differs from %s, rerun with -update-golden to accept it", o.replayGolden)
+	}
+
+	logger.InfoContext(ctx, "replay matches the golden report", slog.String("file", o.replayGolden))
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// runTool runs the tool selected by the options, it reports false when the bot should start instead.
+func runTool(ctx context.Context, o options, logger *slog.Logger) (bool, error) {
+	if o.replay != "" {
+		return true, runReplay(ctx, o, logger)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,82 @@
+package command
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/samber/oops"
+)
+
+const Name = "aca"
+
+// Subcommand is one `/aca <name>` entry, each feature package provides its own.
+type Subcommand struct {
+	Option discord.ApplicationCommandOptionSubCommand
+	Handle func(event *events.ApplicationCommandInteractionCreate, data discord.SlashCommandInteractionData) error
+}
+
+func Create(subcommands []Subcommand) discord.SlashCommandCreate {
+	options := make([]discord.ApplicationCommandOption, 0, len(subcommands))
+
+	for _, subcommand := range subcommands {
+		options = append(options, subcommand.Option)
+	}
+
+	return discord.SlashCommandCreate{
+		Name:        Name,
+		Description: "Auto channel activity",
+		Options:     options,
+		Contexts:    []discord.InteractionContextType{discord.InteractionContextTypeGuild},
+	}
+}
+
+func Handler(
+	ctx context.Context,
+	subcommands []Subcommand,
+	logger *slog.Logger,
+) func(event *events.ApplicationCommandInteractionCreate) {
+	return func(event *events.ApplicationCommandInteractionCreate) {
+		data := event.SlashCommandInteractionData()
+
+		if data.CommandName() != Name || data.SubCommandName == nil {
+			return
+		}
+
+		for _, subcommand := range subcommands {
+			if subcommand.Option.Name != *data.SubCommandName {
+				continue
+			}
+
+			errHandle := subcommand.Handle(event, data)
+
+			if errHandle != nil {
+				logger.ErrorContext(
+					ctx,
+					"failed to run command",
+					slog.String("command", data.CommandPath()),
+					slog.Any("error", oops.Wrap(errHandle)),
+				)
+
+				_ = Reply(event, "Something went wrong, please try again later.")
+			}
+
+			return
+		}
+	}
+}
+
+func Reply(event *events.ApplicationCommandInteractionCreate, content string) error {
+	err := event.CreateMessage(discord.NewMessageCreateBuilder().
+		SetContent(content).
+		SetEphemeral(true).
+		Build(),
+	)
+
+	if err != nil {
+		return oops.Wrapf(err, "failed to reply to interaction")
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package autochannelactivity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/host"
+)
+
+const SchemaFile = "deployments/data/database-schema.sql"
+
+// Config is the autochannelactivity section of the configuration file, the ACA_ variables override it.
+type Config struct {
+	Mode          string              `yaml:"mode"`
+	NATSURL       string              `yaml:"nats_url"`
+	Partitions    int                 `yaml:"partitions"`
+	Partition     int                 `yaml:"partition"`
+	RetentionDays int                 `yaml:"retention_days"`
+	SchemaFile    string              `yaml:"schema_file"`
+	Categories    activity.Categories `yaml:"categories"`
+	Thresholds    activity.Thresholds `yaml:"thresholds"`
+	DryRun        activity.DryRun     `yaml:"dry_run"`
+	// SeedSessions requests the members of every guild with their presences when the bot joins it or restarts,
+	// it needs the privileged Server Members Intent.
+	SeedSessions bool `yaml:"seed_sessions"`
+	// PositionBatchWindow is how long the position updates of a guild are collected into a single one, 0 sends each.
+	PositionBatchWindow time.Duration `yaml:"position_batch_window"`
+	// DeletionPolicies tell what becomes of a game once someone else deletes its channel.
+	DeletionPolicies activity.DeletionPolicies `yaml:"deletion_policy"`
+	// Namings tell how the channel of a game is named, `/aca rename` applies a new one to the existing channels.
+	Namings activity.Namings `yaml:"naming"`
+	// AdminChannels maps a guild to the channel its actionable errors are posted to.
+	AdminChannels map[snowflake.ID]snowflake.ID `yaml:"admin_channels"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Mode:                ModeStandalone,
+		Partitions:          1,
+		RetentionDays:       activity.RetentionDays,
+		SchemaFile:          SchemaFile,
+		Categories:          activity.DefaultCategories(),
+		Thresholds:          activity.DefaultThresholds(),
+		PositionBatchWindow: activity.PositionBatchWindow,
+		DeletionPolicies:    activity.DeletionPolicies{Default: activity.DeletionRecreate},
+		Namings:             activity.DefaultNamings(),
+	}
+}
+
+func LoadConfig(hostConfig host.Config, getenv func(string) string) (Config, error) {
+	config := DefaultConfig()
+
+	err := hostConfig.DecodeModule(Name, &config)
+	if err != nil {
+		return Config{}, err
+	}
+
+	host.EnvString(getenv, "ACA_MODE", &config.Mode)
+	host.EnvString(getenv, "NATS_URL", &config.NATSURL)
+	host.EnvString(getenv, "ACA_SCHEMA_FILE", &config.SchemaFile)
+	host.EnvString(getenv, "ACA_CATEGORY_GAME", &config.Categories.Game)
+	host.EnvString(getenv, "ACA_CATEGORY_ARCHIVE", &config.Categories.Archive)
+
+	deletionPolicy := string(config.DeletionPolicies.Default)
+	host.EnvString(getenv, "ACA_DELETION_POLICY", &deletionPolicy)
+	config.DeletionPolicies.Default = activity.DeletionPolicy(deletionPolicy)
+
+	host.EnvString(getenv, "ACA_NAMING_TEMPLATE", &config.Namings.Default.Template)
+	host.EnvString(getenv, "ACA_NAMING_LANGUAGE", &config.Namings.Default.Language)
+
+	errs := []error{
+		host.EnvInt(getenv, "ACA_PARTITIONS", &config.Partitions),
+		host.EnvInt(getenv, "ACA_PARTITION", &config.Partition),
+		host.EnvInt(getenv, "ACA_RETENTION_DAYS", &config.RetentionDays),
+		host.EnvInt(getenv, "ACA_MINIMUM_PLAYERS", &config.Thresholds.MinimumPlayers),
+		host.EnvInt(getenv, "ACA_MINIMUM_HOURS", &config.Thresholds.MinimumHours),
+		host.EnvInt(getenv, "ACA_DAY_INTERVAL", &config.Thresholds.DayInterval),
+		host.EnvBool(getenv, "ACA_DRY_RUN", &config.DryRun.Enabled),
+		host.EnvBool(getenv, "ACA_SEED_SESSIONS", &config.SeedSessions),
+		host.EnvDuration(getenv, "ACA_POSITION_BATCH_WINDOW", &config.PositionBatchWindow),
+		host.EnvInt(getenv, "ACA_NAMING_MAX_LENGTH", &config.Namings.Default.MaxLength),
+		envSnowflakes(getenv, "ACA_DRY_RUN_GUILDS", &config.DryRun.Guilds),
+		envSnowflake(getenv, "ACA_DRY_RUN_CHANNEL", &config.DryRun.AdminChannelID),
+		envSnowflakeMap(getenv, "ACA_ADMIN_CHANNELS", &config.AdminChannels),
+	}
+
+	if err = errors.Join(errs...); err != nil {
+		return Config{}, oops.Wrapf(err, "invalid %s configuration", Name)
+	}
+
+	return config, config.Validate()
+}
+
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Mode != ModeStandalone && c.Mode != ModeGateway && c.Mode != ModeWorker {
+		errs = append(errs, fmt.Errorf("mode (ACA_MODE) must be %s, %s or %s, got %q",
+			ModeStandalone, ModeGateway, ModeWorker, c.Mode))
+	}
+
+	if c.NATSURL == "" && (c.Mode == ModeGateway || c.Mode == ModeWorker) {
+		errs = append(errs, fmt.Errorf("nats_url (NATS_URL) is required in %s mode", c.Mode))
+	}
+
+	if c.Partitions < 1 {
+		errs = append(errs, fmt.Errorf("partitions (ACA_PARTITIONS) must be >= 1, got %d", c.Partitions))
+	}
+
+	if c.Partition < 0 || c.Partition >= c.Partitions {
+		errs = append(errs, fmt.Errorf("partition (ACA_PARTITION) must be in [0, %d), got %d", c.Partitions, c.Partition))
+	}
+
+	if c.RetentionDays < 1 {
+		errs = append(errs, fmt.Errorf("retention_days (ACA_RETENTION_DAYS) must be >= 1, got %d", c.RetentionDays))
+	}
+
+	if c.Categories.Game == "" || c.Categories.Archive == "" {
+		errs = append(errs, errors.New("categories.game and categories.archive are required"))
+	}
+
+	if c.Categories.Game != "" && c.Categories.Game == c.Categories.Archive {
+		errs = append(errs, errors.New("categories.game and categories.archive must differ"))
+	}
+
+	if c.Thresholds.MinimumPlayers < 1 || c.Thresholds.MinimumHours < 0 || c.Thresholds.DayInterval < 1 {
+		errs = append(errs, errors.New(
+			"thresholds need minimum_players >= 1, minimum_hours >= 0 and day_interval >= 1",
+		))
+	}
+
+	if c.PositionBatchWindow < 0 {
+		errs = append(errs, fmt.Errorf(
+			"position_batch_window (ACA_POSITION_BATCH_WINDOW) must be >= 0, got %s", c.PositionBatchWindow,
+		))
+	}
+
+	if !c.DeletionPolicies.Default.Valid() {
+		errs = append(errs, fmt.Errorf("deletion_policy.default (ACA_DELETION_POLICY) must be %s, %s or %s, got %q",
+			activity.DeletionRecreate, activity.DeletionUntrack, activity.DeletionBlocklist, c.DeletionPolicies.Default))
+	}
+
+	for guildID, policy := range c.DeletionPolicies.Guilds {
+		if !policy.Valid() {
+			errs = append(errs, fmt.Errorf("deletion_policy.guilds of %s must be %s, %s or %s, got %q",
+				guildID, activity.DeletionRecreate, activity.DeletionUntrack, activity.DeletionBlocklist, policy))
+		}
+	}
+
+	errNaming := c.Namings.Default.Validate()
+	if errNaming != nil {
+		errs = append(errs, fmt.Errorf("naming.default (ACA_NAMING_*): %w", errNaming))
+	}
+
+	for guildID := range c.Namings.Guilds {
+		errNaming = c.Namings.Guild(guildID).Validate()
+		if errNaming != nil {
+			errs = append(errs, fmt.Errorf("naming.guilds of %s: %w", guildID, errNaming))
+		}
+	}
+
+	if len(errs) > 0 {
+		return oops.Wrapf(errors.Join(errs...), "invalid %s configuration", Name)
+	}
+
+	return nil
+}
+
+func (c Config) Redacted() Config {
+	redactedConfig := c
+	redactedConfig.NATSURL = host.RedactURL(c.NATSURL)
+
+	return redactedConfig
+}
+
+func envSnowflake(getenv func(string) string, name string, target *snowflake.ID) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := snowflake.Parse(value)
+	if err != nil {
+		return oops.Errorf("%s must be a Discord ID, got %q", name, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+// envSnowflakes reads a comma separated list of Discord IDs.
+func envSnowflakes(getenv func(string) string, name string, target *[]snowflake.ID) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	var ids []snowflake.ID
+
+	for _, field := range strings.Split(value, ",") {
+		parsed, err := snowflake.Parse(strings.TrimSpace(field))
+		if err != nil {
+			return oops.Errorf("%s must be comma separated Discord IDs, got %q", name, value)
+		}
+
+		ids = append(ids, parsed)
+	}
+
+	*target = ids
+
+	return nil
+}
+
+// envSnowflakeMap reads comma separated key:value pairs of Discord IDs.
+func envSnowflakeMap(getenv func(string) string, name string, target *map[snowflake.ID]snowflake.ID) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	ids := map[snowflake.ID]snowflake.ID{}
+
+	for _, field := range strings.Split(value, ",") {
+		key, mapped, found := strings.Cut(strings.TrimSpace(field), ":")
+		parsedKey, errKey := snowflake.Parse(key)
+		parsedValue, errValue := snowflake.Parse(mapped)
+
+		if !found || errKey != nil || errValue != nil {
+			return oops.Errorf("%s must be comma separated guild:channel Discord IDs, got %q", name, value)
+		}
+
+		ids[parsedKey] = parsedValue
+	}
+
+	*target = ids
+
+	return nil
+}
@@ -0,0 +1,485 @@
+// Package contract checks that a storage backend behaves like the others, TestCheck runs it against a migrated
+// scratch database of each dialect.
+package contract
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/guildjoin"
+	"eggmech/autochannelactivity/privacy"
+)
+
+const sessionLength = 2 * time.Hour
+
+// Repositories share one database, built with the default thresholds but for TwoPlayersGuildJoin which sets
+// default settings asking for two players over a week.
+type Repositories struct {
+	Activity            activity.Repository
+	GuildJoin           guildjoin.Repository
+	TwoPlayersGuildJoin guildjoin.Repository
+	Privacy             privacy.Repository
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context, repositories Repositories, f fixture) error
+}
+
+// Check runs every check, each on its own guild, user and game, and returns all the failures.
+func Check(ctx context.Context, repositories Repositories, now time.Time) error {
+	checks := []check{
+		{"sessions open and close", checkSessions},
+		{"one open session per guild, user and game", checkOpenUnique},
+		{"opted out users are not tracked", checkOptOut},
+		{"usage counts raw and aggregated sessions", checkUsage},
+		{"usage counts distinct players across days and aggregations", checkDistinctPlayers},
+		{"channels and default settings are stored", checkSettings},
+		{"deleted channels are no longer tracked", checkDeletedChannels},
+		{"channels moved by hand are left alone until automated", checkManualPlacement},
+		{"channels keep their game and their name through renames", checkChannelNames},
+	}
+
+	var errs []error
+
+	for _, c := range checks {
+		f, err := newFixture(now)
+		if err != nil {
+			return err
+		}
+
+		err = c.run(ctx, repositories, f)
+		if err != nil {
+			errs = append(errs, oops.With("check", c.name).Wrapf(err, "contract check failed"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type fixture struct {
+	guildID snowflake.ID
+	userID  snowflake.ID
+	game    string
+	now     time.Time
+}
+
+func newFixture(now time.Time) (fixture, error) {
+	game, err := uuid.NewV7()
+	if err != nil {
+		return fixture{}, oops.Wrapf(err, "failed to create uuidv7")
+	}
+
+	return fixture{
+		//nolint:gosec // identifiers of scratch rows
+		guildID: snowflake.ID(rand.Int64N(1 << 62)),
+		//nolint:gosec // identifiers of scratch rows
+		userID: snowflake.ID(rand.Int64N(1 << 62)),
+		game:   "contract " + game.String(),
+		now:    now,
+	}, nil
+}
+
+func (f fixture) event() *events.PresenceUpdate {
+	return &events.PresenceUpdate{
+		EventPresenceUpdate: gateway.EventPresenceUpdate{
+			Presence: discord.Presence{
+				PresenceUser: discord.PresenceUser{ID: f.userID},
+				GuildID:      f.guildID,
+			},
+		},
+	}
+}
+
+// channel is the channel of game made in channelID, game is its key and its name as well.
+func (f fixture) channel(channelID snowflake.ID, game string) activity.TrackedChannel {
+	return activity.TrackedChannel{
+		GuildID:      f.guildID,
+		ChannelID:    channelID,
+		ActivityName: game,
+		GameName:     game,
+		Name:         game,
+	}
+}
+
+func (f fixture) open(ctx context.Context, repository activity.Repository) (int, error) {
+	created, err := repository.ApplyPresence(ctx, f.event(), nil, []discord.Activity{{Name: f.game}}, f.now)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to open session")
+	}
+
+	return len(created), nil
+}
+
+func (f fixture) openSessions(ctx context.Context, repository activity.Repository) ([]activity.CurrentActivity, error) {
+	current, err := repository.GetCurrentActivitiesUUID(ctx, f.event())
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to get open sessions")
+	}
+
+	return current, nil
+}
+
+// session plays the game from f.now for sessionLength.
+func (f fixture) session(ctx context.Context, repository activity.Repository) error {
+	_, err := f.open(ctx, repository)
+	if err != nil {
+		return err
+	}
+
+	current, err := f.openSessions(ctx, repository)
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.ApplyPresence(ctx, f.event(), current, nil, f.now.Add(sessionLength))
+	if err != nil {
+		return oops.Wrapf(err, "failed to close session")
+	}
+
+	return nil
+}
+
+func checkSessions(ctx context.Context, repositories Repositories, f fixture) error {
+	created, err := f.open(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	current, err := f.openSessions(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	if created != 1 || len(current) != 1 || current[0].Name != f.game {
+		return oops.Errorf("expected one open session for %q, got %d created and %v open", f.game, created, current)
+	}
+
+	_, err = repositories.Activity.ApplyPresence(ctx, f.event(), current, nil, f.now.Add(sessionLength))
+	if err != nil {
+		return oops.Wrapf(err, "failed to close session")
+	}
+
+	current, err = f.openSessions(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	if len(current) != 0 {
+		return oops.Errorf("expected no open session after closing, got %v", current)
+	}
+
+	return nil
+}
+
+func checkOpenUnique(ctx context.Context, repositories Repositories, f fixture) error {
+	first, err := f.open(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	second, err := f.open(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	if first != 1 || second != 0 {
+		return oops.Errorf("expected the second open to be a no-op, got %d then %d created", first, second)
+	}
+
+	return nil
+}
+
+func checkOptOut(ctx context.Context, repositories Repositories, f fixture) error {
+	err := repositories.Privacy.OptOut(ctx, f.guildID, f.userID)
+	if err != nil {
+		return oops.Wrapf(err, "failed to opt out")
+	}
+
+	optedOut, err := repositories.Activity.IsOptedOut(ctx, f.userID)
+	if err != nil {
+		return oops.Wrapf(err, "failed to read opt-out")
+	}
+
+	created, err := f.open(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	if !optedOut || created != 0 {
+		return oops.Errorf("expected an untracked opted out user, got opted out %t and %d created", optedOut, created)
+	}
+
+	err = repositories.Privacy.OptIn(ctx, f.guildID, f.userID)
+	if err != nil {
+		return oops.Wrapf(err, "failed to opt in")
+	}
+
+	created, err = f.open(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	if created != 1 {
+		return oops.Errorf("expected tracking after opt-in, got %d created", created)
+	}
+
+	return nil
+}
+
+// checkUsage relies on the default thresholds: one player, one hour and a one day interval.
+func checkUsage(ctx context.Context, repositories Repositories, f fixture) error {
+	hasEnough, err := repositories.Activity.HasEnoughActivityUsage(ctx, f.guildID, f.game)
+	if err != nil {
+		return oops.Wrapf(err, "failed to get usage")
+	}
+
+	if hasEnough {
+		return oops.Errorf("expected not enough usage without session")
+	}
+
+	err = checkSessions(ctx, repositories, f)
+	if err != nil {
+		return err
+	}
+
+	hasEnough, err = repositories.Activity.HasEnoughActivityUsage(ctx, f.guildID, f.game)
+	if err != nil {
+		return oops.Wrapf(err, "failed to get usage")
+	}
+
+	if !hasEnough {
+		return oops.Errorf("expected enough usage from a closed session")
+	}
+
+	aggregated, err := repositories.Activity.AggregateActivities(ctx, f.now.Add(48*time.Hour))
+	if err != nil {
+		return oops.Wrapf(err, "failed to aggregate")
+	}
+
+	hasEnough, err = repositories.Activity.HasEnoughActivityUsage(ctx, f.guildID, f.game)
+	if err != nil {
+		return oops.Wrapf(err, "failed to get usage")
+	}
+
+	if aggregated == 0 || !hasEnough {
+		return oops.Errorf("expected enough usage from the daily rows, got %d aggregated", aggregated)
+	}
+
+	return nil
+}
+
+// checkDistinctPlayers plays twice on one day and once on the next, aggregating after each session, one player stays
+// one player until a second one plays.
+func checkDistinctPlayers(ctx context.Context, repositories Repositories, f fixture) error {
+	err := repositories.TwoPlayersGuildJoin.SetDefaultSettings(ctx, &events.GuildJoin{
+		GenericGuild: &events.GenericGuild{GuildID: f.guildID},
+	})
+	if err != nil {
+		return oops.Wrapf(err, "failed to set default settings")
+	}
+
+	day := f.now.UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+
+	for _, startedAt := range []time.Time{day.Add(time.Hour), day.Add(4 * time.Hour), day.Add(25 * time.Hour)} {
+		played := f
+		played.now = startedAt
+
+		err = played.session(ctx, repositories.Activity)
+		if err != nil {
+			return err
+		}
+
+		_, err = repositories.Activity.AggregateActivities(ctx, f.now)
+		if err != nil {
+			return oops.Wrapf(err, "failed to aggregate")
+		}
+
+		hasEnough, errUsage := repositories.Activity.HasEnoughActivityUsage(ctx, f.guildID, f.game)
+		if errUsage != nil {
+			return oops.Wrapf(errUsage, "failed to get usage")
+		}
+
+		if hasEnough {
+			return oops.Errorf("expected one player after the session of %s, got enough usage", startedAt)
+		}
+	}
+
+	other := f
+	other.userID++
+	other.now = f.now.Add(-sessionLength)
+
+	err = other.session(ctx, repositories.Activity)
+	if err != nil {
+		return err
+	}
+
+	hasEnough, err := repositories.Activity.HasEnoughActivityUsage(ctx, f.guildID, f.game)
+	if err != nil {
+		return oops.Wrapf(err, "failed to get usage")
+	}
+
+	if !hasEnough {
+		return oops.Errorf("expected enough usage from a second player")
+	}
+
+	return nil
+}
+
+func checkSettings(ctx context.Context, repositories Repositories, f fixture) error {
+	err := repositories.GuildJoin.SetDefaultSettings(ctx, &events.GuildJoin{
+		GenericGuild: &events.GenericGuild{GuildID: f.guildID},
+	})
+	if err != nil {
+		return oops.Wrapf(err, "failed to set default settings")
+	}
+
+	channelID := f.guildID + 1
+
+	for _, game := range []string{f.game, f.game + " 2"} {
+		err = repositories.Activity.CreateChannel(ctx, f.channel(channelID, game))
+		if err != nil {
+			return oops.With("game", game).Wrapf(err, "failed to create channel")
+		}
+	}
+
+	return nil
+}
+
+func checkDeletedChannels(ctx context.Context, repositories Repositories, f fixture) error {
+	channelID := f.guildID + 1
+	recreatedID := f.guildID + 2
+
+	for id, name := range map[snowflake.ID]string{channelID: f.game, recreatedID: f.game + " 2"} {
+		err := repositories.Activity.CreateChannel(ctx, f.channel(id, name))
+		if err != nil {
+			return oops.With("channel_id", id).Wrapf(err, "failed to create channel")
+		}
+	}
+
+	if tracked, err := trackedIn(ctx, repositories.Activity, f.guildID); err != nil || len(tracked) != 2 {
+		return oops.Errorf("expected 2 tracked channels, got %v (%v)", tracked, err)
+	}
+
+	for id, policy := range map[snowflake.ID]activity.DeletionPolicy{
+		channelID:   activity.DeletionBlocklist,
+		recreatedID: activity.DeletionRecreate,
+	} {
+		deleted, err := repositories.Activity.DeleteChannel(ctx, f.guildID, id, policy, f.now)
+		if err != nil || len(deleted) != 1 {
+			return oops.Errorf("expected one channel deleted for %d, got %v (%v)", id, deleted, err)
+		}
+	}
+
+	deleted, err := repositories.Activity.DeleteChannel(ctx, f.guildID, channelID, activity.DeletionBlocklist, f.now)
+	if err != nil || len(deleted) != 0 {
+		return oops.Errorf("expected deleting twice to be a no-op, got %v (%v)", deleted, err)
+	}
+
+	tracked, err := trackedIn(ctx, repositories.Activity, f.guildID)
+	if err != nil || len(tracked) != 0 {
+		return oops.Errorf("expected no tracked channel once deleted, got %v (%v)", tracked, err)
+	}
+
+	ignored, err := repositories.Activity.IgnoredChannels(ctx, f.guildID)
+	if err != nil || len(ignored) != 1 || ignored[f.game] != activity.DeletionBlocklist {
+		return oops.Errorf("expected only %q blocklisted, got %v (%v)", f.game, ignored, err)
+	}
+
+	return nil
+}
+
+func checkManualPlacement(ctx context.Context, repositories Repositories, f fixture) error {
+	channelID := f.guildID + 1
+
+	err := repositories.Activity.CreateChannel(ctx, f.channel(channelID, f.game))
+	if err != nil {
+		return oops.Wrapf(err, "failed to create channel")
+	}
+
+	for _, step := range []struct {
+		placed  bool
+		changed int
+	}{
+		{placed: true, changed: 1},
+		{placed: true, changed: 0},
+		{placed: false, changed: 1},
+	} {
+		changed, errPlace := repositories.Activity.SetManuallyPlaced(ctx, f.guildID, channelID, step.placed, f.now)
+		if errPlace != nil || len(changed) != step.changed {
+			return oops.Errorf("expected %d channels changed placing by hand %t, got %v (%v)",
+				step.changed, step.placed, changed, errPlace)
+		}
+
+		tracked, errTracked := repositories.Activity.GuildTrackedChannels(ctx, f.guildID)
+		if errTracked != nil || len(tracked) != 1 || tracked[0].ManuallyPlaced != step.placed {
+			return oops.Errorf("expected manually placed %t, got %v (%v)", step.placed, tracked, errTracked)
+		}
+	}
+
+	return nil
+}
+
+func checkChannelNames(ctx context.Context, repositories Repositories, f fixture) error {
+	channelID := f.guildID + 1
+	channel := f.channel(channelID, f.game)
+
+	err := repositories.Activity.CreateChannel(ctx, channel)
+	if err != nil {
+		return oops.Wrapf(err, "failed to create channel")
+	}
+
+	for _, name := range []string{channel.Name, "🎮-" + channel.Name} {
+		if name != channel.Name {
+			err = repositories.Activity.RenameChannel(ctx, f.guildID, channelID, name)
+			if err != nil {
+				return oops.Wrapf(err, "failed to rename channel")
+			}
+		}
+
+		tracked, errTracked := repositories.Activity.GuildTrackedChannels(ctx, f.guildID)
+		if errTracked != nil || len(tracked) != 1 {
+			return oops.Errorf("expected one tracked channel, got %v (%v)", tracked, errTracked)
+		}
+
+		expected := channel
+		expected.Name = name
+
+		if tracked[0] != expected {
+			return oops.Errorf("expected tracked channel %v, got %v", expected, tracked[0])
+		}
+	}
+
+	return nil
+}
+
+func trackedIn(
+	ctx context.Context,
+	repository activity.Repository,
+	guildID snowflake.ID,
+) ([]activity.TrackedChannel, error) {
+	tracked, err := repository.TrackedChannels(ctx)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to get tracked channels")
+	}
+
+	var inGuild []activity.TrackedChannel
+
+	for _, channel := range tracked {
+		if channel.GuildID == guildID {
+			inGuild = append(inGuild, channel)
+		}
+	}
+
+	return inGuild, nil
+}
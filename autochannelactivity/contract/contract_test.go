@@ -0,0 +1,90 @@
+package contract_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"eggmech/autochannelactivity"
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/contract"
+	"eggmech/autochannelactivity/guildjoin"
+	"eggmech/autochannelactivity/privacy"
+	"eggmech/host"
+	"eggmech/host/database"
+)
+
+// TestCheck runs the contract on a scratch SQLite database and, when POSTGRES_URL is set, on that disposable one.
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(t *testing.T) host.DatabaseConfig
+	}{
+		{
+			name: "sqlite",
+			config: func(t *testing.T) host.DatabaseConfig {
+				return host.DatabaseConfig{Driver: database.SQLiteName, Path: filepath.Join(t.TempDir(), "database.sqlite3")}
+			},
+		},
+		{
+			name: "postgres",
+			config: func(t *testing.T) host.DatabaseConfig {
+				postgresURL := os.Getenv("POSTGRES_URL")
+				if postgresURL == "" {
+					t.Skip("POSTGRES_URL is not set")
+				}
+
+				return host.DatabaseConfig{Driver: database.PostgresName, URL: postgresURL}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			config := tt.config(t)
+
+			dialect, err := config.Dialect()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			migrations := autochannelactivity.Migrations(dialect, "")
+			migrations.Log = io.Discard
+
+			err = host.Migrate(ctx, config, migrations, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := dialect.Open(config.DSN())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(func() { _ = db.Close() })
+
+			thresholds := activity.DefaultThresholds()
+			activityRepository := activity.BuildRepository(db, dialect, thresholds)
+			t.Cleanup(activityRepository.Close)
+
+			err = contract.Check(ctx, contract.Repositories{
+				Activity:  activityRepository,
+				GuildJoin: &guildjoin.SQLRepository{DB: db, Dialect: dialect, Thresholds: thresholds},
+				TwoPlayersGuildJoin: &guildjoin.SQLRepository{
+					DB:         db,
+					Dialect:    dialect,
+					Thresholds: activity.Thresholds{MinimumPlayers: 2, MinimumHours: thresholds.MinimumHours, DayInterval: 7},
+				},
+				Privacy: privacy.Repository{DB: db, Dialect: dialect},
+			}, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+SET statement_timeout = 0;
+SET lock_timeout = 0;
+SET idle_in_transaction_session_timeout = 0;
+SET transaction_timeout = 0;
+SET client_encoding = 'UTF8';
+SET standard_conforming_strings = on;
+SELECT pg_catalog.set_config('search_path', '', false);
+SET check_function_bodies = false;
+SET xmloption = content;
+SET client_min_messages = warning;
+SET row_security = off;
+
+SET default_tablespace = '';
+
+SET default_table_access_method = heap;
+
+--
+-- Name: aca_activity; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_activity (
+    uuid character varying(36) NOT NULL,
+    guild_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    activity_name character varying(256) NOT NULL,
+    started_at bigint NOT NULL,
+    ended_at bigint,
+    duration integer DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: aca_activity_channel; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_activity_channel (
+    uuid character varying(36) NOT NULL,
+    activity_settings_uuid character varying(36) NOT NULL,
+    activity_name character varying(256) NOT NULL,
+    deleted_at bigint,
+    manually_placed_at bigint,
+    channel_name character varying(256),
+    game_name character varying(256)
+);
+
+
+--
+-- Name: aca_activity_channel_ignored; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_activity_channel_ignored (
+    guild_id bigint NOT NULL,
+    channel_name character varying(256) NOT NULL,
+    policy character varying(16) NOT NULL,
+    created_at bigint NOT NULL
+);
+
+
+--
+-- Name: aca_activity_daily; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_activity_daily (
+    guild_id bigint NOT NULL,
+    activity_name character varying(256) NOT NULL,
+    day character varying(10) NOT NULL,
+    players integer DEFAULT 0 NOT NULL,
+    sessions integer DEFAULT 0 NOT NULL,
+    total_seconds bigint DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: aca_activity_daily_player; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_activity_daily_player (
+    guild_id bigint NOT NULL,
+    activity_name character varying(256) NOT NULL,
+    day character varying(10) NOT NULL,
+    user_id bigint NOT NULL
+);
+
+
+--
+-- Name: aca_activity_settings; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_activity_settings (
+    uuid character varying(36) NOT NULL,
+    guild_id bigint NOT NULL,
+    channel_id bigint NOT NULL,
+    minimum_players integer NOT NULL,
+    minimum_hours integer NOT NULL,
+    day_interval integer NOT NULL
+);
+
+
+--
+-- Name: aca_privacy_audit; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_privacy_audit (
+    uuid character varying(36) NOT NULL,
+    guild_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    actor_id bigint NOT NULL,
+    action character varying(32) NOT NULL,
+    deleted_rows bigint DEFAULT 0 NOT NULL,
+    created_at bigint NOT NULL
+);
+
+
+--
+-- Name: aca_privacy_optout; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.aca_privacy_optout (
+    user_id bigint NOT NULL,
+    opted_out_at bigint NOT NULL
+);
+
+
+--
+-- Name: schema_migrations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.schema_migrations (
+    version character varying(128) NOT NULL
+);
+
+
+--
+-- Name: aca_activity_channel_ignored aca_activity_channel_ignored_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity_channel_ignored
+    ADD CONSTRAINT aca_activity_channel_ignored_pkey PRIMARY KEY (guild_id, channel_name);
+
+
+--
+-- Name: aca_activity_channel aca_activity_channel_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity_channel
+    ADD CONSTRAINT aca_activity_channel_pkey PRIMARY KEY (uuid);
+
+
+--
+-- Name: aca_activity_daily aca_activity_daily_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity_daily
+    ADD CONSTRAINT aca_activity_daily_pkey PRIMARY KEY (guild_id, activity_name, day);
+
+
+--
+-- Name: aca_activity_daily_player aca_activity_daily_player_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity_daily_player
+    ADD CONSTRAINT aca_activity_daily_player_pkey PRIMARY KEY (guild_id, activity_name, day, user_id);
+
+
+--
+-- Name: aca_activity aca_activity_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity
+    ADD CONSTRAINT aca_activity_pkey PRIMARY KEY (uuid);
+
+
+--
+-- Name: aca_activity_settings aca_activity_settings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity_settings
+    ADD CONSTRAINT aca_activity_settings_pkey PRIMARY KEY (uuid);
+
+
+--
+-- Name: aca_privacy_audit aca_privacy_audit_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_privacy_audit
+    ADD CONSTRAINT aca_privacy_audit_pkey PRIMARY KEY (uuid);
+
+
+--
+-- Name: aca_privacy_optout aca_privacy_optout_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_privacy_optout
+    ADD CONSTRAINT aca_privacy_optout_pkey PRIMARY KEY (user_id);
+
+
+--
+-- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.schema_migrations
+    ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
+
+
+--
+-- Name: aca_activity_activity_name_started_at_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_activity_activity_name_started_at_index ON public.aca_activity USING btree (activity_name, started_at);
+
+
+--
+-- Name: aca_activity_daily_activity_name_day_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_activity_daily_activity_name_day_index ON public.aca_activity_daily USING btree (activity_name, day);
+
+
+--
+-- Name: aca_activity_daily_player_user_id_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_activity_daily_player_user_id_index ON public.aca_activity_daily_player USING btree (user_id);
+
+
+--
+-- Name: aca_activity_ended_at_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_activity_ended_at_index ON public.aca_activity USING btree (ended_at);
+
+
+--
+-- Name: aca_activity_guild_id_user_id_ended_at_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_activity_guild_id_user_id_ended_at_index ON public.aca_activity USING btree (guild_id, user_id, ended_at);
+
+
+--
+-- Name: aca_activity_open_unique_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX aca_activity_open_unique_index ON public.aca_activity USING btree (guild_id, user_id, activity_name) WHERE (ended_at IS NULL);
+
+
+--
+-- Name: aca_activity_settings_guild_id_channel_id_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_activity_settings_guild_id_channel_id_index ON public.aca_activity_settings USING btree (guild_id, channel_id);
+
+
+--
+-- Name: aca_privacy_audit_guild_id_user_id_index; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX aca_privacy_audit_guild_id_user_id_index ON public.aca_privacy_audit USING btree (guild_id, user_id);
+
+
+--
+-- Name: aca_activity_channel aca_activity_channel_aca_activity_settings_fk; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.aca_activity_channel
+    ADD CONSTRAINT aca_activity_channel_aca_activity_settings_fk FOREIGN KEY (activity_settings_uuid) REFERENCES public.aca_activity_settings(uuid);
+
+
+--
+-- PostgreSQL database dump complete
+--
+
+
+--
+-- Dbmate schema migrations
+--
+
+INSERT INTO public.schema_migrations (version) VALUES
+    ('0001'),
+    ('0009'),
+    ('0010'),
+    ('0011'),
+    ('0012');
@@ -0,0 +1,103 @@
+CREATE TABLE IF NOT EXISTS "schema_migrations" (version varchar(128) primary key);
+CREATE TABLE aca_activity
+(
+    uuid          varchar(36)       not null primary key,
+    guild_id      integer           not null,
+    user_id       integer           not null,
+    activity_name varchar(256)      not null,
+    started_at    integer           not null,
+    duration      integer default 0 not null
+, ended_at integer);
+CREATE TABLE aca_activity_settings
+(
+    uuid            varchar(36) primary key,
+    guild_id        integer not null,
+    channel_id      integer not null,
+    minimum_players integer not null,
+    minimum_hours   integer not null,
+    day_interval    integer not null
+);
+CREATE INDEX aca_activity_settings_guild_id_channel_id_index
+    on aca_activity_settings (guild_id, channel_id);
+CREATE TABLE aca_activity_channel
+(
+    uuid                    varchar(36)     primary key,
+    activity_settings_uuid  varchar(36)     not null,
+    activity_name           varchar(256)    not null, deleted_at integer, manually_placed_at integer, channel_name varchar(256), game_name varchar(256),
+
+    constraint aca_activity_channel_aca_activity_settings_fk
+            foreign key (activity_settings_uuid) references aca_activity_settings (uuid)
+);
+CREATE TABLE aca_privacy_optout
+(
+    user_id       integer not null primary key,
+    opted_out_at  integer not null
+);
+CREATE TABLE aca_privacy_audit
+(
+    uuid          varchar(36)       not null primary key,
+    guild_id      integer           not null,
+    user_id       integer           not null,
+    actor_id      integer           not null,
+    action        varchar(32)       not null,
+    deleted_rows  integer default 0 not null,
+    created_at    integer           not null
+);
+CREATE INDEX aca_privacy_audit_guild_id_user_id_index
+    on aca_privacy_audit (guild_id, user_id);
+CREATE TABLE aca_activity_daily
+(
+    guild_id      integer           not null,
+    activity_name varchar(256)      not null,
+    day           varchar(10)       not null,
+    players       integer default 0 not null,
+    sessions      integer default 0 not null,
+    total_seconds integer default 0 not null,
+
+    primary key (guild_id, activity_name, day)
+);
+CREATE INDEX aca_activity_daily_activity_name_day_index
+    on aca_activity_daily (activity_name, day);
+CREATE INDEX aca_activity_ended_at_index
+    on aca_activity (ended_at);
+CREATE UNIQUE INDEX aca_activity_open_unique_index
+    on aca_activity (guild_id, user_id, activity_name)
+    where ended_at is null;
+CREATE INDEX aca_activity_guild_id_user_id_ended_at_index
+    on aca_activity (guild_id, user_id, ended_at);
+CREATE INDEX aca_activity_activity_name_started_at_index
+    on aca_activity (activity_name, started_at);
+CREATE TABLE aca_activity_channel_ignored
+(
+    guild_id     integer      not null,
+    channel_name varchar(256) not null,
+    policy       varchar(16)  not null,
+    created_at   integer      not null,
+
+    primary key (guild_id, channel_name)
+);
+CREATE TABLE aca_activity_daily_player
+(
+    guild_id      integer      not null,
+    activity_name varchar(256) not null,
+    day           varchar(10)  not null,
+    user_id       integer      not null,
+
+    primary key (guild_id, activity_name, day, user_id)
+);
+CREATE INDEX aca_activity_daily_player_user_id_index
+    on aca_activity_daily_player (user_id);
+-- Dbmate schema migrations
+INSERT INTO "schema_migrations" (version) VALUES
+  ('0001'),
+  ('0002'),
+  ('0003'),
+  ('0004'),
+  ('0005'),
+  ('0006'),
+  ('0007'),
+  ('0008'),
+  ('0009'),
+  ('0010'),
+  ('0011'),
+  ('0012');
@@ -7,13 +7,11 @@ import (
 	"github.com/disgoorg/disgo/events"
 	"github.com/disgoorg/snowflake/v2"
 	"github.com/gofrs/uuid/v5"
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
 	"github.com/samber/oops"
-)
 
-const MinimumPlayers = 1
-const MinimumHours = 1
-const DayInterval = 1
+	"eggmech/autochannelactivity/activity"
+	"eggmech/host/database"
+)
 
 type Uuidv7 string
 type GuildID snowflake.ID
@@ -24,18 +22,23 @@ type CurrentActivity struct {
 	Name string
 }
 
-type Repository struct {
-	DB *sql.DB
+type Repository interface {
+	SetDefaultSettings(ctx context.Context, event *events.GuildJoin) error
+}
+
+type SQLRepository struct {
+	DB         *sql.DB
+	Dialect    database.Dialect
+	Thresholds activity.Thresholds
 }
 
-func (r *Repository) SetDefaultSettings(
+// SetDefaultSettings stores the guild wide settings, under channel 0.
+func (r *SQLRepository) SetDefaultSettings(
 	ctx context.Context,
 	event *events.GuildJoin,
 ) error {
-	stmt, err := r.DB.PrepareContext(
-		ctx,
-		`INSERT INTO aca_activity_settings (uuid, guild_id, minimum_players, minimum_hours, day_interval) VALUES (?, ?, ?, ?, ?)`,
-	)
+	stmt, err := r.DB.PrepareContext(ctx, r.Dialect.Rebind(`INSERT INTO aca_activity_settings
+		(uuid, guild_id, channel_id, minimum_players, minimum_hours, day_interval) VALUES (?, ?, 0, ?, ?, ?)`))
 
 	if err != nil {
 		return oops.Wrapf(err, "failed to prepare statement")
@@ -48,7 +51,14 @@ func (r *Repository) SetDefaultSettings(
 		return oops.Wrapf(err, "failed to create uuidv7")
 	}
 
-	_, err = stmt.ExecContext(ctx, uuidv7, event.GuildID, MinimumPlayers, MinimumHours, DayInterval)
+	_, err = stmt.ExecContext(
+		ctx,
+		uuidv7,
+		event.GuildID,
+		r.Thresholds.MinimumPlayers,
+		r.Thresholds.MinimumHours,
+		r.Thresholds.DayInterval,
+	)
 	if err != nil {
 		return oops.Wrapf(err, "failed to execute query")
 	}
@@ -0,0 +1,240 @@
+package activitytest
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+)
+
+// Channel is the state of a fake Discord channel, a ParentID of 0 means no category.
+type Channel struct {
+	ID       snowflake.ID
+	Type     discord.ChannelType
+	Name     string
+	ParentID snowflake.ID
+	Position int
+}
+
+type Call struct {
+	Method  string
+	GuildID snowflake.ID
+	Arg     any
+}
+
+// RecordingChannels is an activity.ChannelClient over one guild's channels, it records every call
+// and applies creations and moves to Channels. Errors makes a method fail by its name.
+type RecordingChannels struct {
+	mu       sync.Mutex
+	Channels []Channel
+	Calls    []Call
+	Errors   map[string]error
+	nextID   snowflake.ID
+}
+
+func NewRecordingChannels(channels ...Channel) *RecordingChannels {
+	nextID := snowflake.ID(1000)
+
+	for _, channel := range channels {
+		nextID = max(nextID, channel.ID+1)
+	}
+
+	return &RecordingChannels{
+		Channels: channels,
+		Errors:   map[string]error{},
+		nextID:   nextID,
+	}
+}
+
+func (c *RecordingChannels) GetGuildChannels(
+	guildID snowflake.ID,
+	_ ...rest.RequestOpt,
+) ([]discord.GuildChannel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Calls = append(c.Calls, Call{Method: "GetGuildChannels", GuildID: guildID})
+
+	if err := c.Errors["GetGuildChannels"]; err != nil {
+		return nil, err
+	}
+
+	guildChannels := make([]discord.GuildChannel, 0, len(c.Channels))
+
+	for _, channel := range c.Channels {
+		guildChannel, err := channel.GuildChannel(guildID)
+		if err != nil {
+			return nil, err
+		}
+
+		guildChannels = append(guildChannels, guildChannel)
+	}
+
+	return guildChannels, nil
+}
+
+func (c *RecordingChannels) CreateGuildChannel(
+	guildID snowflake.ID,
+	guildChannelCreate discord.GuildChannelCreate,
+	_ ...rest.RequestOpt,
+) (discord.GuildChannel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Calls = append(c.Calls, Call{Method: "CreateGuildChannel", GuildID: guildID, Arg: guildChannelCreate})
+
+	if err := c.Errors["CreateGuildChannel"]; err != nil {
+		return nil, err
+	}
+
+	channel := Channel{ID: c.nextID, Type: guildChannelCreate.Type()}
+	c.nextID++
+
+	switch create := guildChannelCreate.(type) {
+	case discord.GuildCategoryChannelCreate:
+		channel.Name = create.Name
+		channel.Position = create.Position
+	case discord.GuildTextChannelCreate:
+		channel.Name = create.Name
+		channel.ParentID = create.ParentID
+		channel.Position = create.Position
+	default:
+		return nil, oops.Errorf("unsupported channel create %T", guildChannelCreate)
+	}
+
+	c.Channels = append(c.Channels, channel)
+
+	return channel.GuildChannel(guildID)
+}
+
+func (c *RecordingChannels) UpdateChannelPositions(
+	guildID snowflake.ID,
+	guildChannelPositionUpdates []discord.GuildChannelPositionUpdate,
+	_ ...rest.RequestOpt,
+) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Calls = append(c.Calls, Call{Method: "UpdateChannelPositions", GuildID: guildID, Arg: guildChannelPositionUpdates})
+
+	if err := c.Errors["UpdateChannelPositions"]; err != nil {
+		return err
+	}
+
+	for _, update := range guildChannelPositionUpdates {
+		for i := range c.Channels {
+			if c.Channels[i].ID != update.ID {
+				continue
+			}
+
+			if update.ParentID != nil {
+				c.Channels[i].ParentID = *update.ParentID
+			}
+
+			if update.Position != nil && !update.Position.IsNull() {
+				c.Channels[i].Position = update.Position.Value()
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdateChannel only renames, the channels of RecordingChannels are all in the same guild.
+func (c *RecordingChannels) UpdateChannel(
+	channelID snowflake.ID,
+	channelUpdate discord.ChannelUpdate,
+	_ ...rest.RequestOpt,
+) (discord.Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Calls = append(c.Calls, Call{Method: "UpdateChannel", Arg: channelUpdate})
+
+	if err := c.Errors["UpdateChannel"]; err != nil {
+		return nil, err
+	}
+
+	update, ok := channelUpdate.(discord.GuildTextChannelUpdate)
+	if !ok || update.Name == nil {
+		return nil, oops.Errorf("unsupported channel update %T", channelUpdate)
+	}
+
+	for i := range c.Channels {
+		if c.Channels[i].ID == channelID {
+			c.Channels[i].Name = *update.Name
+
+			return c.Channels[i].GuildChannel(0)
+		}
+	}
+
+	return nil, oops.Errorf("unknown channel %d", channelID)
+}
+
+// Find returns the channel named name.
+func (c *RecordingChannels) Find(name string) (Channel, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, channel := range c.Channels {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+
+	return Channel{}, false
+}
+
+// Count returns how many times method was called.
+func (c *RecordingChannels) Count(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+
+	for _, call := range c.Calls {
+		if call.Method == method {
+			count++
+		}
+	}
+
+	return count
+}
+
+// GuildChannel goes through JSON, disgo channels have no exported fields.
+func (c Channel) GuildChannel(guildID snowflake.ID) (discord.GuildChannel, error) {
+	raw := struct {
+		ID       snowflake.ID        `json:"id"`
+		Type     discord.ChannelType `json:"type"`
+		GuildID  snowflake.ID        `json:"guild_id"`
+		Name     string              `json:"name"`
+		Position int                 `json:"position"`
+		ParentID *snowflake.ID       `json:"parent_id,omitempty"`
+	}{ID: c.ID, Type: c.Type, GuildID: guildID, Name: c.Name, Position: c.Position}
+
+	if c.ParentID != 0 {
+		raw.ParentID = &c.ParentID
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to marshal channel")
+	}
+
+	var channel discord.UnmarshalChannel
+
+	err = json.Unmarshal(data, &channel)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to unmarshal channel")
+	}
+
+	guildChannel, ok := channel.Channel.(discord.GuildChannel)
+	if !ok {
+		return nil, oops.Errorf("channel %d is not a guild channel", c.ID)
+	}
+
+	return guildChannel, nil
+}
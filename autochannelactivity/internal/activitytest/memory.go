@@ -0,0 +1,389 @@
+// Package activitytest holds in-memory fakes of what the presence handler talks to, for the tests of activity.
+package activitytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+)
+
+type Session struct {
+	UUID      activity.Uuidv7
+	GuildID   snowflake.ID
+	UserID    snowflake.ID
+	Name      string
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+func (s Session) open() bool {
+	return s.EndedAt.IsZero()
+}
+
+type StoredChannel struct {
+	GuildID      snowflake.ID
+	ChannelID    snowflake.ID
+	ActivityName string
+	GameName     string
+	Name         string
+	DeletedAt    time.Time
+	PlacedAt     time.Time
+}
+
+func (c StoredChannel) tracked() activity.TrackedChannel {
+	name := c.Name
+	if name == "" {
+		name = c.ActivityName
+	}
+
+	return activity.TrackedChannel{
+		GuildID:        c.GuildID,
+		ChannelID:      c.ChannelID,
+		ActivityName:   c.ActivityName,
+		GameName:       c.GameName,
+		Name:           name,
+		ManuallyPlaced: !c.PlacedAt.IsZero(),
+	}
+}
+
+// MemoryRepository is an activity.Repository kept in memory. Usage answers HasEnoughActivityUsage
+// by game name instead of computing it, and Errors makes a method fail by its name.
+type MemoryRepository struct {
+	mu       sync.Mutex
+	Sessions []Session
+	OptedOut map[snowflake.ID]bool
+	Usage    map[string]bool
+	Channels []StoredChannel
+	Ignored  map[snowflake.ID]map[string]activity.DeletionPolicy
+	Errors   map[string]error
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		OptedOut: map[snowflake.ID]bool{},
+		Usage:    map[string]bool{},
+		Ignored:  map[snowflake.ID]map[string]activity.DeletionPolicy{},
+		Errors:   map[string]error{},
+	}
+}
+
+func (r *MemoryRepository) ApplyPresence(
+	_ context.Context,
+	event *events.PresenceUpdate,
+	activitiesToClose []activity.CurrentActivity,
+	activitiesToCreate []discord.Activity,
+	now time.Time,
+) ([]discord.Activity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["ApplyPresence"]; err != nil {
+		return nil, err
+	}
+
+	for _, closed := range activitiesToClose {
+		for i, session := range r.Sessions {
+			if session.open() && r.matches(session, event, closed.Name) {
+				r.Sessions[i].EndedAt = now
+			}
+		}
+	}
+
+	var createdActivities []discord.Activity
+
+	for _, created := range activitiesToCreate {
+		if r.OptedOut[event.PresenceUser.ID] || r.isOpen(event, created.Name) {
+			continue
+		}
+
+		uuidv7, err := uuid.NewV7()
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to create uuidv7")
+		}
+
+		r.Sessions = append(r.Sessions, Session{
+			UUID:      activity.Uuidv7(uuidv7.String()),
+			GuildID:   event.GuildID,
+			UserID:    event.PresenceUser.ID,
+			Name:      created.Name,
+			StartedAt: now,
+		})
+		createdActivities = append(createdActivities, created)
+	}
+
+	return createdActivities, nil
+}
+
+func (r *MemoryRepository) matches(session Session, event *events.PresenceUpdate, name string) bool {
+	return session.GuildID == event.GuildID && session.UserID == event.PresenceUser.ID && session.Name == name
+}
+
+func (r *MemoryRepository) isOpen(event *events.PresenceUpdate, name string) bool {
+	for _, session := range r.Sessions {
+		if session.open() && r.matches(session, event, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *MemoryRepository) IsOptedOut(_ context.Context, userID snowflake.ID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["IsOptedOut"]; err != nil {
+		return false, err
+	}
+
+	return r.OptedOut[userID], nil
+}
+
+func (r *MemoryRepository) CreateChannel(_ context.Context, channel activity.TrackedChannel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["CreateChannel"]; err != nil {
+		return err
+	}
+
+	r.Channels = append(r.Channels, StoredChannel{
+		GuildID:      channel.GuildID,
+		ChannelID:    channel.ChannelID,
+		ActivityName: channel.ActivityName,
+		GameName:     channel.GameName,
+		Name:         channel.Name,
+	})
+
+	return nil
+}
+
+func (r *MemoryRepository) GetCurrentActivitiesUUID(
+	_ context.Context,
+	event *events.PresenceUpdate,
+) ([]activity.CurrentActivity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["GetCurrentActivitiesUUID"]; err != nil {
+		return nil, err
+	}
+
+	var currentActivities []activity.CurrentActivity
+
+	for _, session := range r.Sessions {
+		if session.open() && session.GuildID == event.GuildID && session.UserID == event.PresenceUser.ID {
+			currentActivities = append(currentActivities, activity.CurrentActivity{UUID: session.UUID, Name: session.Name})
+		}
+	}
+
+	return currentActivities, nil
+}
+
+func (r *MemoryRepository) HasEnoughActivityUsage(
+	_ context.Context,
+	_ snowflake.ID,
+	activityName string,
+) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["HasEnoughActivityUsage"]; err != nil {
+		return false, err
+	}
+
+	return r.Usage[activityName], nil
+}
+
+func (r *MemoryRepository) IgnoredChannels(
+	_ context.Context,
+	guildID snowflake.ID,
+) (map[string]activity.DeletionPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["IgnoredChannels"]; err != nil {
+		return nil, err
+	}
+
+	ignored := map[string]activity.DeletionPolicy{}
+
+	for name, policy := range r.Ignored[guildID] {
+		ignored[name] = policy
+	}
+
+	return ignored, nil
+}
+
+func (r *MemoryRepository) TrackedChannels(_ context.Context) ([]activity.TrackedChannel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["TrackedChannels"]; err != nil {
+		return nil, err
+	}
+
+	var channels []activity.TrackedChannel
+
+	for _, channel := range r.Channels {
+		if channel.DeletedAt.IsZero() {
+			channels = append(channels, channel.tracked())
+		}
+	}
+
+	return channels, nil
+}
+
+func (r *MemoryRepository) GuildTrackedChannels(
+	_ context.Context,
+	guildID snowflake.ID,
+) ([]activity.TrackedChannel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["GuildTrackedChannels"]; err != nil {
+		return nil, err
+	}
+
+	var channels []activity.TrackedChannel
+
+	for _, channel := range r.Channels {
+		if channel.GuildID == guildID && channel.DeletedAt.IsZero() {
+			channels = append(channels, channel.tracked())
+		}
+	}
+
+	return channels, nil
+}
+
+func (r *MemoryRepository) DeleteChannel(
+	_ context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	policy activity.DeletionPolicy,
+	now time.Time,
+) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["DeleteChannel"]; err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for i, channel := range r.Channels {
+		if !r.tracks(channel, guildID, channelID) {
+			continue
+		}
+
+		r.Channels[i].DeletedAt = now
+		names = append(names, channel.ActivityName)
+
+		if policy == activity.DeletionRecreate {
+			continue
+		}
+
+		if r.Ignored[guildID] == nil {
+			r.Ignored[guildID] = map[string]activity.DeletionPolicy{}
+		}
+
+		r.Ignored[guildID][channel.ActivityName] = policy
+	}
+
+	return names, nil
+}
+
+func (r *MemoryRepository) SetManuallyPlaced(
+	_ context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	placed bool,
+	now time.Time,
+) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["SetManuallyPlaced"]; err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for i, channel := range r.Channels {
+		if !r.tracks(channel, guildID, channelID) || channel.PlacedAt.IsZero() != placed {
+			continue
+		}
+
+		r.Channels[i].PlacedAt = time.Time{}
+		if placed {
+			r.Channels[i].PlacedAt = now
+		}
+
+		names = append(names, channel.ActivityName)
+	}
+
+	return names, nil
+}
+
+func (r *MemoryRepository) RenameChannel(
+	_ context.Context,
+	guildID snowflake.ID,
+	channelID snowflake.ID,
+	name string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["RenameChannel"]; err != nil {
+		return err
+	}
+
+	for i, channel := range r.Channels {
+		if r.tracks(channel, guildID, channelID) {
+			r.Channels[i].Name = name
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) tracks(channel StoredChannel, guildID snowflake.ID, channelID snowflake.ID) bool {
+	return channel.GuildID == guildID && channel.ChannelID == channelID && channel.DeletedAt.IsZero()
+}
+
+// AggregateActivities only drops the closed sessions, the fake keeps no daily statistics.
+func (r *MemoryRepository) AggregateActivities(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Errors["AggregateActivities"]; err != nil {
+		return 0, err
+	}
+
+	year, month, day := before.UTC().Date()
+	beforeDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	kept := r.Sessions[:0]
+
+	for _, session := range r.Sessions {
+		if session.open() || !session.StartedAt.Before(beforeDay) {
+			kept = append(kept, session)
+		}
+	}
+
+	deletedRows := int64(len(r.Sessions) - len(kept))
+	r.Sessions = kept
+
+	return deletedRows, nil
+}
+
+func (r *MemoryRepository) Close() {}
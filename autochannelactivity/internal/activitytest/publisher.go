@@ -0,0 +1,36 @@
+package activitytest
+
+import (
+	"context"
+	"sync"
+
+	"eggmech/autochannelactivity/stream"
+)
+
+type RecordingPublisher struct {
+	mu        sync.Mutex
+	Envelopes []stream.Envelope
+}
+
+func (p *RecordingPublisher) Publish(_ context.Context, envelope stream.Envelope) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Envelopes = append(p.Envelopes, envelope)
+
+	return nil
+}
+
+// Types returns the type of every published event, in order.
+func (p *RecordingPublisher) Types() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	types := make([]string, 0, len(p.Envelopes))
+
+	for _, envelope := range p.Envelopes {
+		types = append(types, envelope.Type)
+	}
+
+	return types
+}
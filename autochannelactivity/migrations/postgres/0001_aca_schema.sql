@@ -0,0 +1,90 @@
+-- migrate:up
+create table aca_activity
+(
+    uuid          varchar(36)       not null primary key,
+    guild_id      bigint            not null,
+    user_id       bigint            not null,
+    activity_name varchar(256)      not null,
+    started_at    bigint            not null,
+    ended_at      bigint,
+    duration      integer default 0 not null
+);
+
+create index aca_activity_ended_at_index
+    on aca_activity (ended_at);
+
+create unique index aca_activity_open_unique_index
+    on aca_activity (guild_id, user_id, activity_name)
+    where ended_at is null;
+
+create index aca_activity_guild_id_user_id_ended_at_index
+    on aca_activity (guild_id, user_id, ended_at);
+
+create index aca_activity_activity_name_started_at_index
+    on aca_activity (activity_name, started_at);
+
+create table aca_activity_settings
+(
+    uuid            varchar(36) primary key,
+    guild_id        bigint  not null,
+    channel_id      bigint  not null,
+    minimum_players integer not null,
+    minimum_hours   integer not null,
+    day_interval    integer not null
+);
+
+create index aca_activity_settings_guild_id_channel_id_index
+    on aca_activity_settings (guild_id, channel_id);
+
+create table aca_activity_channel
+(
+    uuid                    varchar(36)     primary key,
+    activity_settings_uuid  varchar(36)     not null,
+    activity_name           varchar(256)    not null,
+
+    constraint aca_activity_channel_aca_activity_settings_fk
+            foreign key (activity_settings_uuid) references aca_activity_settings (uuid)
+);
+
+create table aca_privacy_optout
+(
+    user_id       bigint not null primary key,
+    opted_out_at  bigint not null
+);
+
+create table aca_privacy_audit
+(
+    uuid          varchar(36)       not null primary key,
+    guild_id      bigint            not null,
+    user_id       bigint            not null,
+    actor_id      bigint            not null,
+    action        varchar(32)       not null,
+    deleted_rows  bigint  default 0 not null,
+    created_at    bigint            not null
+);
+
+create index aca_privacy_audit_guild_id_user_id_index
+    on aca_privacy_audit (guild_id, user_id);
+
+create table aca_activity_daily
+(
+    guild_id      bigint            not null,
+    activity_name varchar(256)      not null,
+    day           varchar(10)       not null,
+    players       integer default 0 not null,
+    sessions      integer default 0 not null,
+    total_seconds bigint  default 0 not null,
+
+    primary key (guild_id, activity_name, day)
+);
+
+create index aca_activity_daily_activity_name_day_index
+    on aca_activity_daily (activity_name, day);
+
+-- migrate:down
+drop table aca_activity_daily;
+drop table aca_privacy_audit;
+drop table aca_privacy_optout;
+drop table aca_activity_channel;
+drop table aca_activity_settings;
+drop table aca_activity;
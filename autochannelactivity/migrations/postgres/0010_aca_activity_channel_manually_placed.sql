@@ -0,0 +1,5 @@
+-- migrate:up
+alter table aca_activity_channel add column manually_placed_at bigint;
+
+-- migrate:down
+alter table aca_activity_channel drop column manually_placed_at;
@@ -10,4 +10,4 @@ create table aca_activity_channel
 );
 
 -- migrate:down
-drop table aca_activity_settings;
+drop table aca_activity_channel;
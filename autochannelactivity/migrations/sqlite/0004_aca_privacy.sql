@@ -0,0 +1,24 @@
+-- migrate:up
+create table aca_privacy_optout
+(
+    user_id       integer not null primary key,
+    opted_out_at  integer not null
+);
+
+create table aca_privacy_audit
+(
+    uuid          varchar(36)       not null primary key,
+    guild_id      integer           not null,
+    user_id       integer           not null,
+    actor_id      integer           not null,
+    action        varchar(32)       not null,
+    deleted_rows  integer default 0 not null,
+    created_at    integer           not null
+);
+
+create index aca_privacy_audit_guild_id_user_id_index
+    on aca_privacy_audit (guild_id, user_id);
+
+-- migrate:down
+drop table aca_privacy_audit;
+drop table aca_privacy_optout;
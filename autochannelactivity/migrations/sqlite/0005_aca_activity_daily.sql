@@ -0,0 +1,18 @@
+-- migrate:up
+create table aca_activity_daily
+(
+    guild_id      integer           not null,
+    activity_name varchar(256)      not null,
+    day           varchar(10)       not null,
+    players       integer default 0 not null,
+    sessions      integer default 0 not null,
+    total_seconds integer default 0 not null,
+
+    primary key (guild_id, activity_name, day)
+);
+
+create index aca_activity_daily_activity_name_day_index
+    on aca_activity_daily (activity_name, day);
+
+-- migrate:down
+drop table aca_activity_daily;
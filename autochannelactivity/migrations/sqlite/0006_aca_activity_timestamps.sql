@@ -0,0 +1,27 @@
+-- migrate:up
+alter table aca_activity add column ended_at integer;
+
+update aca_activity
+set started_at = cast(round((julianday(started_at) - 2440587.5) * 86400000) as integer)
+where typeof(started_at) = 'text';
+
+update aca_activity
+set ended_at = started_at,
+    duration = 0
+where duration < 0;
+
+update aca_activity
+set ended_at = started_at + duration * 1000
+where duration > 0;
+
+create index aca_activity_ended_at_index
+    on aca_activity (ended_at);
+
+-- migrate:down
+drop index aca_activity_ended_at_index;
+
+update aca_activity
+set started_at = strftime('%Y-%m-%d %H:%M:%f+00:00', started_at / 1000.0, 'unixepoch')
+where typeof(started_at) = 'integer';
+
+alter table aca_activity drop column ended_at;
@@ -0,0 +1,20 @@
+-- migrate:up
+update aca_activity
+set ended_at = started_at,
+    duration = 0
+where ended_at is null
+  and exists (select 1
+              from aca_activity as kept
+              where kept.ended_at is null
+                and kept.guild_id = aca_activity.guild_id
+                and kept.user_id = aca_activity.user_id
+                and kept.activity_name = aca_activity.activity_name
+                and (kept.started_at < aca_activity.started_at
+                  or (kept.started_at = aca_activity.started_at and kept.uuid < aca_activity.uuid)));
+
+create unique index aca_activity_open_unique_index
+    on aca_activity (guild_id, user_id, activity_name)
+    where ended_at is null;
+
+-- migrate:down
+drop index aca_activity_open_unique_index;
@@ -0,0 +1,10 @@
+-- migrate:up
+create index aca_activity_guild_id_user_id_ended_at_index
+    on aca_activity (guild_id, user_id, ended_at);
+
+create index aca_activity_activity_name_started_at_index
+    on aca_activity (activity_name, started_at);
+
+-- migrate:down
+drop index aca_activity_activity_name_started_at_index;
+drop index aca_activity_guild_id_user_id_ended_at_index;
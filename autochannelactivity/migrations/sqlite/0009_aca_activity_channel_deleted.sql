@@ -0,0 +1,17 @@
+-- migrate:up
+alter table aca_activity_channel add column deleted_at integer;
+
+create table aca_activity_channel_ignored
+(
+    guild_id     integer      not null,
+    channel_name varchar(256) not null,
+    policy       varchar(16)  not null,
+    created_at   integer      not null,
+
+    primary key (guild_id, channel_name)
+);
+
+-- migrate:down
+drop table aca_activity_channel_ignored;
+
+alter table aca_activity_channel drop column deleted_at;
@@ -0,0 +1,12 @@
+-- migrate:up
+alter table aca_activity_channel add column channel_name varchar(256);
+
+alter table aca_activity_channel add column game_name varchar(256);
+
+update aca_activity_channel
+set channel_name = activity_name;
+
+-- migrate:down
+alter table aca_activity_channel drop column game_name;
+
+alter table aca_activity_channel drop column channel_name;
@@ -0,0 +1,16 @@
+-- migrate:up
+create table aca_activity_daily_player
+(
+    guild_id      integer      not null,
+    activity_name varchar(256) not null,
+    day           varchar(10)  not null,
+    user_id       integer      not null,
+
+    primary key (guild_id, activity_name, day, user_id)
+);
+
+create index aca_activity_daily_player_user_id_index
+    on aca_activity_daily_player (user_id);
+
+-- migrate:down
+drop table aca_activity_daily_player;
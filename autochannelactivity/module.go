@@ -0,0 +1,308 @@
+package autochannelactivity
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"log/slog"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/command"
+	"eggmech/autochannelactivity/privacy"
+	"eggmech/autochannelactivity/stream"
+	"eggmech/host"
+	"eggmech/host/database"
+)
+
+const Name = "autochannelactivity"
+
+// Modes of the module, gateway and worker split the standalone bot over NATS.
+const (
+	ModeStandalone = "standalone"
+	ModeGateway    = "gateway"
+	ModeWorker     = "worker"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationsEmbed embed.FS
+
+type Module struct {
+	config Config
+}
+
+func New(config Config) *Module {
+	return &Module{config: config}
+}
+
+func (m *Module) Name() string {
+	return Name
+}
+
+func (m *Module) RedactedConfig() any {
+	return m.config.Redacted()
+}
+
+// Intents is empty for a worker, it gets its presences from NATS and only talks to Discord over REST.
+// The presences need the guilds, which also fill the caches the permissions are checked on,
+// seeding the sessions needs the members.
+func (m *Module) Intents() gateway.Intents {
+	if m.config.Mode == ModeWorker {
+		return gateway.IntentsNone
+	}
+
+	intents := gateway.IntentGuilds | gateway.IntentGuildPresences
+
+	if m.config.SeedSessions {
+		intents = intents.Add(gateway.IntentGuildMembers)
+	}
+
+	return intents
+}
+
+func (m *Module) Migrations(dialect database.Dialect) host.Migrations {
+	return Migrations(dialect, m.config.SchemaFile)
+}
+
+// Migrations are kept per dialect in migrations/<dialect>, Postgres started from the SQLite schema of 0008.
+func Migrations(dialect database.Dialect, schemaFile string) host.Migrations {
+	migrations := host.Migrations{
+		FS:         migrationsEmbed,
+		Dir:        "migrations/" + dialect.Name(),
+		TableName:  "schema_migrations",
+		SchemaFile: schemaFile,
+	}
+
+	if !dialect.IsPostgres() {
+		migrations.Prepare = padMigrationVersions
+	}
+
+	return migrations
+}
+
+func (m *Module) Setup(ctx context.Context, h host.Host) (host.Registration, error) {
+	var nc *nats.Conn
+
+	if m.config.NATSURL != "" {
+		var err error
+
+		nc, err = nats.Connect(m.config.NATSURL, nats.Name(Name))
+		if err != nil {
+			return host.Registration{}, oops.Wrapf(err, "error connecting to NATS")
+		}
+
+		context.AfterFunc(ctx, func() { _ = nc.Drain() })
+	}
+
+	switch m.config.Mode {
+	case ModeGateway:
+		return m.setupGateway(ctx, h, nc)
+	case ModeWorker:
+		return m.setupWorker(ctx, h, nc)
+	}
+
+	publisher, err := newPublisher(ctx, nc)
+	if err != nil {
+		return host.Registration{}, err
+	}
+
+	metrics := activity.NewMetrics(h.Metrics)
+	sqlRepository := activity.BuildRepository(h.DB, h.Dialect, m.config.Thresholds)
+	context.AfterFunc(ctx, sqlRepository.Close)
+
+	activityRepository := activity.MetricsRepository{Repository: sqlRepository, Metrics: metrics}
+
+	// the presences, the permissions and the reported errors only come from the guilds of the shards of this process,
+	// the retention is not per guild and runs once over the processes
+	if h.Shards.OwnsShard(0) {
+		go activity.RetentionJob(ctx, activityRepository, m.config.RetentionDays, h.Logger)
+	}
+
+	reporter := activity.NewReporter(h.Client.Rest(), m.config.AdminChannels, h.Logger)
+	permissions := activity.NewPermissionClient(
+		ctx, m.coalescingClient(ctx, h.Client.Rest(), reporter), h.Client.Caches(), reporter, metrics, h.Logger,
+	)
+
+	return m.registration(ctx, h, activity.PresenceHandler(
+		ctx,
+		h.Client.ID(),
+		m.channelClient(ctx, h, permissions),
+		activityRepository,
+		publisher,
+		m.config.Categories,
+		m.config.Namings,
+		metrics,
+		reporter,
+		h.Logger,
+	), activityRepository, permissions.Listener()), nil
+}
+
+// setupGateway only relays presences to the workers, it keeps serving /aca commands and the channel deletions and
+// moves which are plain DB writes.
+func (m *Module) setupGateway(ctx context.Context, h host.Host, nc *nats.Conn) (host.Registration, error) {
+	relay, err := stream.PresenceRelay(ctx, nc, h.Client.ID(), m.config.Partitions, h.Logger)
+	if err != nil {
+		return host.Registration{}, oops.Wrapf(err, "error creating presence relay")
+	}
+
+	sqlRepository := activity.BuildRepository(h.DB, h.Dialect, m.config.Thresholds)
+	context.AfterFunc(ctx, sqlRepository.Close)
+
+	return m.registration(ctx, h, relay, sqlRepository), nil
+}
+
+// setupWorker handles the presences of one partition, the gateway already dropped the bot own presences.
+// Without a gateway there are no caches to check the permissions on, Discord refusals are reported instead.
+func (m *Module) setupWorker(ctx context.Context, h host.Host, nc *nats.Conn) (host.Registration, error) {
+	publisher, err := newPublisher(ctx, nc)
+	if err != nil {
+		return host.Registration{}, err
+	}
+
+	metrics := activity.NewMetrics(h.Metrics)
+	sqlRepository := activity.BuildRepository(h.DB, h.Dialect, m.config.Thresholds)
+	context.AfterFunc(ctx, sqlRepository.Close)
+
+	activityRepository := activity.MetricsRepository{Repository: sqlRepository, Metrics: metrics}
+
+	if m.config.Partition == 0 {
+		go activity.RetentionJob(ctx, activityRepository, m.config.RetentionDays, h.Logger)
+	}
+
+	reporter := activity.NewReporter(h.Client.Rest(), m.config.AdminChannels, h.Logger)
+	handler := activity.ReportingPresenceHandler(
+		ctx,
+		0,
+		m.channelClient(ctx, h, m.coalescingClient(ctx, h.Client.Rest(), reporter)),
+		activityRepository,
+		publisher,
+		m.config.Categories,
+		m.config.Namings,
+		metrics,
+		reporter,
+		h.Logger,
+	)
+
+	go func() {
+		errConsume := stream.ConsumePresences(ctx, nc, m.config.Partition, handler, activity.Retryable, h.Logger)
+		if errConsume != nil {
+			h.Logger.ErrorContext(ctx, "failed to consume presences", slog.Any("error", errConsume))
+		}
+	}()
+
+	return host.Registration{}, nil
+}
+
+// channelClient plans the channel mutations instead of executing them on client when a dry run is configured,
+// planning does not need the permissions.
+func (m *Module) channelClient(ctx context.Context, h host.Host, client activity.ChannelClient) activity.ChannelClient {
+	if !m.config.DryRun.Active() {
+		return client
+	}
+
+	return activity.NewPlanningClient(ctx, client, h.Client.Rest(), m.config.DryRun, h.Logger)
+}
+
+// deletionListener audits the tracked channels once and returns the listener of the channels deleted afterwards,
+// the gateway only sends the deletions of the guilds of the shards of this process.
+func (m *Module) deletionListener(ctx context.Context, h host.Host, repo activity.ChannelRepository) bot.EventListener {
+	go activity.AuditChannels(ctx, h.Client.Rest(), repo, m.config.DeletionPolicies, h.Shards.OwnsGuild, h.Logger)
+
+	return activity.DeletionListener(ctx, repo, m.config.DeletionPolicies, h.Logger)
+}
+
+// coalescingClient sends the position updates of a guild once per window, a burst of presences would otherwise
+// wait on the rate limit of the endpoint one update after the other. The errors of the updates sent later are
+// reported to the guild.
+func (m *Module) coalescingClient(
+	ctx context.Context,
+	client activity.ChannelClient,
+	reporter *activity.Reporter,
+) activity.ChannelClient {
+	if m.config.PositionBatchWindow == 0 {
+		return client
+	}
+
+	return activity.NewCoalescingClient(client, m.config.PositionBatchWindow, func(guildID snowflake.ID, err error) {
+		reporter.Report(ctx, guildID, err)
+	})
+}
+
+func (m *Module) registration(
+	ctx context.Context,
+	h host.Host,
+	onPresenceUpdate func(event *events.PresenceUpdate),
+	repo activity.ChannelRepository,
+	listeners ...bot.EventListener,
+) host.Registration {
+	subcommands := append(
+		privacy.Subcommands(ctx, privacy.Repository{DB: h.DB, Dialect: h.Dialect}),
+		activity.Subcommands(ctx, repo, h.Client.Rest(), m.config.Namings, m.config.DryRun, h.Logger)...,
+	)
+
+	listeners = append(
+		listeners,
+		m.deletionListener(ctx, h, repo),
+		activity.PlacementListener(ctx, repo, h.Client.Caches(), m.config.Categories, h.Logger),
+	)
+
+	if m.config.SeedSessions {
+		listeners = append(listeners, activity.SeedListener(ctx, onPresenceUpdate, h.Logger))
+	}
+
+	return host.Registration{
+		Listeners: append([]bot.EventListener{&events.ListenerAdapter{
+			OnPresenceUpdate:                onPresenceUpdate,
+			OnApplicationCommandInteraction: command.Handler(ctx, subcommands, h.Logger),
+		}}, listeners...),
+		Commands: []discord.ApplicationCommandCreate{command.Create(subcommands)},
+	}
+}
+
+func newPublisher(ctx context.Context, nc *nats.Conn) (stream.Publisher, error) {
+	if nc == nil {
+		return stream.NoopPublisher{}, nil
+	}
+
+	publisher, err := stream.NewJetStreamPublisher(ctx, nc)
+	if err != nil {
+		return nil, oops.Wrapf(err, "error creating event publisher")
+	}
+
+	return publisher, nil
+}
+
+// padMigrationVersions renames the versions recorded before migrations were zero-padded,
+// dbmate sorts migration files by name so "10_x.sql" would otherwise run before "2_x.sql".
+func padMigrationVersions(ctx context.Context, db *sql.DB) error {
+	var exists bool
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+	).Scan(&exists)
+	if err != nil {
+		return oops.Wrapf(err, "failed to look for schema_migrations")
+	}
+
+	if !exists {
+		return nil
+	}
+
+	_, err = db.ExecContext(
+		ctx,
+		`UPDATE schema_migrations SET version = printf('%04d', CAST(version AS integer)) WHERE length(version) < 4`,
+	)
+	if err != nil {
+		return oops.Wrapf(err, "failed to update schema_migrations")
+	}
+
+	return nil
+}
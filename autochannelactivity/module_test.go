@@ -0,0 +1,58 @@
+package autochannelactivity_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"eggmech/autochannelactivity"
+	"eggmech/host"
+	"eggmech/host/database"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "rewrite the schema files from the migrations")
+
+// TestMigrations runs every migration up, down and up again, then compares the schema with the committed one.
+// Regenerate it with `go test . -run TestMigrations -update-schema`. Postgres needs pg_dump and POSTGRES_URL pointing
+// to a disposable database.
+func TestMigrations(t *testing.T) {
+	tests := []struct {
+		name        string
+		dialect     database.Dialect
+		postgresURL string
+		schemaFile  string
+	}{
+		{name: "sqlite", dialect: database.SQLite(), schemaFile: "database-schema.sql"},
+		{
+			name:        "postgres",
+			dialect:     database.Postgres(),
+			postgresURL: os.Getenv("POSTGRES_URL"),
+			schemaFile:  "database-schema.postgres.sql",
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.dialect.IsPostgres() && tt.postgresURL == "" {
+				t.Skip("POSTGRES_URL is not set")
+			}
+
+			err := host.CheckMigrations(
+				context.Background(),
+				tt.postgresURL,
+				autochannelactivity.Migrations(tt.dialect, ""),
+				tt.schemaFile,
+				*updateSchema,
+				logger,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
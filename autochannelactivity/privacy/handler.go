@@ -0,0 +1,121 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/command"
+)
+
+func Subcommands(ctx context.Context, repo Repository) []command.Subcommand {
+	return []command.Subcommand{
+		{
+			Option: discord.ApplicationCommandOptionSubCommand{
+				Name:        "privacy",
+				Description: "Choose whether the bot records your game sessions",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{
+						Name:        "action",
+						Description: "What to do with your data",
+						Required:    true,
+						Choices: []discord.ApplicationCommandOptionChoiceString{
+							{Name: "Stop tracking me", Value: string(ActionOptOut)},
+							{Name: "Track me again", Value: string(ActionOptIn)},
+							{Name: "Delete my recorded sessions", Value: string(ActionDelete)},
+						},
+					},
+				},
+			},
+			Handle: func(event *events.ApplicationCommandInteractionCreate, data discord.SlashCommandInteractionData) error {
+				return privacyHandler(ctx, repo, event, data)
+			},
+		},
+		{
+			Option: discord.ApplicationCommandOptionSubCommand{
+				Name:        "purge",
+				Description: "Delete every recorded session of a member (requires Manage Server)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{
+						Name:        "user",
+						Description: "Member whose data is deleted",
+						Required:    true,
+					},
+				},
+			},
+			Handle: func(event *events.ApplicationCommandInteractionCreate, data discord.SlashCommandInteractionData) error {
+				return purgeHandler(ctx, repo, event, data)
+			},
+		},
+	}
+}
+
+func privacyHandler(
+	ctx context.Context,
+	repo Repository,
+	event *events.ApplicationCommandInteractionCreate,
+	data discord.SlashCommandInteractionData,
+) error {
+	guildID := event.GuildID()
+	if guildID == nil {
+		return command.Reply(event, "This command can only be used in a server.")
+	}
+
+	userID := event.User().ID
+
+	switch Action(data.String("action")) {
+	case ActionOptOut:
+		err := repo.OptOut(ctx, *guildID, userID)
+		if err != nil {
+			return oops.Wrapf(err, "failed to opt-out")
+		}
+
+		return command.Reply(event, "Your game sessions are no longer recorded.")
+	case ActionOptIn:
+		err := repo.OptIn(ctx, *guildID, userID)
+		if err != nil {
+			return oops.Wrapf(err, "failed to opt-in")
+		}
+
+		return command.Reply(event, "Your game sessions are recorded again.")
+	case ActionDelete:
+		deletedRows, err := repo.DeleteUserData(ctx, *guildID, userID)
+		if err != nil {
+			return oops.Wrapf(err, "failed to delete user data")
+		}
+
+		return command.Reply(event, fmt.Sprintf("%d recorded sessions deleted.", deletedRows))
+	}
+
+	return command.Reply(event, "Unknown action.")
+}
+
+func purgeHandler(
+	ctx context.Context,
+	repo Repository,
+	event *events.ApplicationCommandInteractionCreate,
+	data discord.SlashCommandInteractionData,
+) error {
+	guildID := event.GuildID()
+	member := event.Member()
+
+	if guildID == nil || member == nil {
+		return command.Reply(event, "This command can only be used in a server.")
+	}
+
+	if !member.Permissions.Has(discord.PermissionManageGuild) {
+		return command.Reply(event, "You need the Manage Server permission to purge a member.")
+	}
+
+	user := data.User("user")
+
+	deletedRows, err := repo.PurgeUser(ctx, *guildID, user.ID, member.User.ID)
+	if err != nil {
+		return oops.Wrapf(err, "failed to purge user")
+	}
+
+	return command.Reply(event, fmt.Sprintf("%d recorded sessions of %s deleted.", deletedRows, user.Mention()))
+}
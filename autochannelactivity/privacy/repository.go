@@ -0,0 +1,212 @@
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/samber/oops"
+
+	"eggmech/host/database"
+)
+
+type Action string
+
+const (
+	ActionOptOut Action = "opt-out"
+	ActionOptIn  Action = "opt-in"
+	ActionDelete Action = "delete"
+	ActionPurge  Action = "purge"
+)
+
+type Repository struct {
+	DB      *sql.DB
+	Dialect database.Dialect
+}
+
+// OptOut stops tracking the user everywhere and drops the sessions still open for them.
+func (r *Repository) OptOut(ctx context.Context, guildID snowflake.ID, userID snowflake.ID) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return oops.Wrapf(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(
+		ctx,
+		r.Dialect.Rebind(
+			`INSERT INTO aca_privacy_optout (user_id, opted_out_at) VALUES (?, ?) ON CONFLICT (user_id) DO NOTHING`,
+		),
+		userID,
+		time.Now().UTC().UnixMilli(),
+	)
+	if err != nil {
+		return oops.Wrapf(err, "failed to insert opt-out")
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		r.Dialect.Rebind(`DELETE FROM aca_activity WHERE user_id = ? AND ended_at IS NULL`),
+		userID,
+	)
+	if err != nil {
+		return oops.Wrapf(err, "failed to delete open activities")
+	}
+
+	err = r.audit(ctx, tx, guildID, userID, userID, ActionOptOut, result)
+	if err != nil {
+		return err
+	}
+
+	return oops.Wrapf(tx.Commit(), "failed to commit transaction")
+}
+
+func (r *Repository) OptIn(ctx context.Context, guildID snowflake.ID, userID snowflake.ID) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return oops.Wrapf(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, r.Dialect.Rebind(`DELETE FROM aca_privacy_optout WHERE user_id = ?`), userID)
+	if err != nil {
+		return oops.Wrapf(err, "failed to delete opt-out")
+	}
+
+	err = r.audit(ctx, tx, guildID, userID, userID, ActionOptIn, nil)
+	if err != nil {
+		return err
+	}
+
+	return oops.Wrapf(tx.Commit(), "failed to commit transaction")
+}
+
+func (r *Repository) IsOptedOut(ctx context.Context, userID snowflake.ID) (bool, error) {
+	var optedOut bool
+
+	err := r.DB.QueryRowContext(
+		ctx,
+		r.Dialect.Rebind(`SELECT EXISTS (SELECT 1 FROM aca_privacy_optout WHERE user_id = ?)`),
+		userID,
+	).Scan(&optedOut)
+	if err != nil {
+		return false, oops.Wrapf(err, "failed to get opt-out")
+	}
+
+	return optedOut, nil
+}
+
+// DeleteUserData removes every session and aggregated day stored for the user, in all guilds.
+func (r *Repository) DeleteUserData(ctx context.Context, guildID snowflake.ID, userID snowflake.ID) (int64, error) {
+	return r.deleteActivities(
+		ctx,
+		guildID,
+		userID,
+		userID,
+		ActionDelete,
+		`user_id = ?`,
+		userID,
+	)
+}
+
+// PurgeUser removes the sessions and aggregated days of a user in one guild on behalf of a guild admin.
+func (r *Repository) PurgeUser(
+	ctx context.Context,
+	guildID snowflake.ID,
+	userID snowflake.ID,
+	actorID snowflake.ID,
+) (int64, error) {
+	return r.deleteActivities(
+		ctx,
+		guildID,
+		userID,
+		actorID,
+		ActionPurge,
+		`guild_id = ? AND user_id = ?`,
+		guildID,
+		userID,
+	)
+}
+
+// deleteActivities deletes the sessions and the aggregated players matching where, only the sessions are counted in
+// the audit.
+func (r *Repository) deleteActivities(
+	ctx context.Context,
+	guildID snowflake.ID,
+	userID snowflake.ID,
+	actorID snowflake.ID,
+	action Action,
+	where string,
+	args ...any,
+) (int64, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, r.Dialect.Rebind(`DELETE FROM aca_activity WHERE `+where), args...)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to delete activities")
+	}
+
+	_, err = tx.ExecContext(ctx, r.Dialect.Rebind(`DELETE FROM aca_activity_daily_player WHERE `+where), args...)
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to delete aggregated players")
+	}
+
+	err = r.audit(ctx, tx, guildID, userID, actorID, action, result)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, oops.Wrapf(err, "failed to commit transaction")
+	}
+
+	deletedRows, _ := result.RowsAffected()
+
+	return deletedRows, nil
+}
+
+func (r *Repository) audit(
+	ctx context.Context,
+	tx *sql.Tx,
+	guildID snowflake.ID,
+	userID snowflake.ID,
+	actorID snowflake.ID,
+	action Action,
+	result sql.Result,
+) error {
+	var deletedRows int64
+
+	if result != nil {
+		deletedRows, _ = result.RowsAffected()
+	}
+
+	uuidv7, err := uuid.NewV7()
+	if err != nil {
+		return oops.Wrapf(err, "failed to create uuidv7")
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		r.Dialect.Rebind(`INSERT INTO aca_privacy_audit (uuid, guild_id, user_id, actor_id, action, deleted_rows, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		uuidv7,
+		guildID,
+		userID,
+		actorID,
+		action,
+		deletedRows,
+		time.Now().UTC().UnixMilli(),
+	)
+	if err != nil {
+		return oops.Wrapf(err, "failed to insert audit")
+	}
+
+	return nil
+}
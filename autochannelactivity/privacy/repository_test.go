@@ -0,0 +1,309 @@
+package privacy_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity"
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/privacy"
+	"eggmech/host"
+	"eggmech/host/database"
+)
+
+const (
+	guildID      = snowflake.ID(1)
+	otherGuildID = snowflake.ID(2)
+	userID       = snowflake.ID(10)
+	otherUserID  = snowflake.ID(11)
+	adminID      = snowflake.ID(12)
+)
+
+type audit struct {
+	guildID     snowflake.ID
+	userID      snowflake.ID
+	actorID     snowflake.ID
+	action      privacy.Action
+	deletedRows int64
+}
+
+// TestRepository runs each action on a user with a closed and an open session and an aggregated day in two guilds,
+// then handles a new presence of theirs. The data of another user is never touched.
+func TestRepository(t *testing.T) {
+	tests := []struct {
+		name           string
+		act            func(ctx context.Context, repo privacy.Repository) (int64, error)
+		expectDeleted  int64
+		expectOptedOut bool
+		expectSessions map[snowflake.ID]int
+		expectPlayers  map[snowflake.ID]int
+		expectAudits   []audit
+	}{
+		{
+			name: "an opted out user loses their open sessions and gets no new one",
+			act: func(ctx context.Context, repo privacy.Repository) (int64, error) {
+				return 0, repo.OptOut(ctx, guildID, userID)
+			},
+			expectOptedOut: true,
+			expectSessions: map[snowflake.ID]int{guildID: 1, otherGuildID: 1},
+			expectPlayers:  map[snowflake.ID]int{guildID: 1, otherGuildID: 1},
+			expectAudits:   []audit{{guildID, userID, userID, privacy.ActionOptOut, 2}},
+		},
+		{
+			name: "an opted in user is tracked again",
+			act: func(ctx context.Context, repo privacy.Repository) (int64, error) {
+				return 0, errors.Join(repo.OptOut(ctx, guildID, userID), repo.OptIn(ctx, guildID, userID))
+			},
+			expectSessions: map[snowflake.ID]int{guildID: 2, otherGuildID: 1},
+			expectPlayers:  map[snowflake.ID]int{guildID: 1, otherGuildID: 1},
+			expectAudits: []audit{
+				{guildID, userID, userID, privacy.ActionOptOut, 2},
+				{guildID, userID, userID, privacy.ActionOptIn, 0},
+			},
+		},
+		{
+			name: "a deletion removes the sessions and aggregated days of every guild",
+			act: func(ctx context.Context, repo privacy.Repository) (int64, error) {
+				return repo.DeleteUserData(ctx, guildID, userID)
+			},
+			expectDeleted:  4,
+			expectSessions: map[snowflake.ID]int{guildID: 1},
+			expectPlayers:  map[snowflake.ID]int{},
+			expectAudits:   []audit{{guildID, userID, userID, privacy.ActionDelete, 4}},
+		},
+		{
+			name: "a purge removes the sessions and aggregated days of one guild",
+			act: func(ctx context.Context, repo privacy.Repository) (int64, error) {
+				return repo.PurgeUser(ctx, guildID, userID, adminID)
+			},
+			expectDeleted:  2,
+			expectSessions: map[snowflake.ID]int{guildID: 1, otherGuildID: 2},
+			expectPlayers:  map[snowflake.ID]int{otherGuildID: 1},
+			expectAudits:   []audit{{guildID, userID, adminID, privacy.ActionPurge, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db, dialect := newDatabase(ctx, t)
+			repo := privacy.Repository{DB: db, Dialect: dialect}
+
+			activityRepository := activity.BuildRepository(db, dialect, activity.DefaultThresholds())
+			t.Cleanup(activityRepository.Close)
+
+			err := seed(ctx, db, dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deleted, err := tt.act(ctx, repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			optedOut, err := repo.IsOptedOut(ctx, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = activityRepository.ApplyPresence(ctx, presence(), nil, []discord.Activity{{Name: "Factorio"}}, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = errors.Join(
+				expectCount(ctx, db, dialect, "aca_activity", userID, tt.expectSessions),
+				expectCount(ctx, db, dialect, "aca_activity_daily_player", userID, tt.expectPlayers),
+				expectCount(ctx, db, dialect, "aca_activity", otherUserID, map[snowflake.ID]int{guildID: 1}),
+				expectCount(ctx, db, dialect, "aca_activity_daily_player", otherUserID, map[snowflake.ID]int{guildID: 1}),
+				expectAudits(ctx, db, tt.expectAudits),
+			)
+			if deleted != tt.expectDeleted || optedOut != tt.expectOptedOut {
+				err = errors.Join(err, oops.Errorf(
+					"expected %d deleted and opted out %t, got %d and %t",
+					tt.expectDeleted,
+					tt.expectOptedOut,
+					deleted,
+					optedOut,
+				))
+			}
+
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func newDatabase(ctx context.Context, t *testing.T) (*sql.DB, database.Dialect) {
+	t.Helper()
+
+	config := host.DatabaseConfig{Driver: database.SQLiteName, Path: filepath.Join(t.TempDir(), "database.sqlite3")}
+	dialect := database.SQLite()
+
+	migrations := autochannelactivity.Migrations(dialect, "")
+	migrations.Log = io.Discard
+
+	err := host.Migrate(ctx, config, migrations, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := dialect.Open(config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db, dialect
+}
+
+// seed gives the user a closed and an open session and an aggregated day in both guilds, and the other user the same
+// open session and day in the first guild.
+func seed(ctx context.Context, db *sql.DB, dialect database.Dialect) error {
+	startedAt := time.Now().Add(-time.Hour).UTC().UnixMilli()
+
+	sessions := []struct {
+		guildID snowflake.ID
+		userID  snowflake.ID
+		name    string
+		endedAt *int64
+	}{
+		{guildID, userID, "Zelda", &startedAt},
+		{guildID, userID, "Dota 2", nil},
+		{otherGuildID, userID, "Zelda", &startedAt},
+		{otherGuildID, userID, "Dota 2", nil},
+		{guildID, otherUserID, "Dota 2", nil},
+	}
+
+	for _, session := range sessions {
+		uuidv7, err := uuid.NewV7()
+		if err != nil {
+			return oops.Wrapf(err, "failed to create uuidv7")
+		}
+
+		_, err = db.ExecContext(
+			ctx,
+			dialect.Rebind(`INSERT INTO aca_activity (uuid, guild_id, user_id, activity_name, started_at, ended_at)
+				VALUES (?, ?, ?, ?, ?, ?)`),
+			uuidv7,
+			session.guildID,
+			session.userID,
+			session.name,
+			startedAt,
+			session.endedAt,
+		)
+		if err != nil {
+			return oops.Wrapf(err, "failed to insert session")
+		}
+
+		if session.endedAt != nil {
+			continue
+		}
+
+		_, err = db.ExecContext(
+			ctx,
+			dialect.Rebind(`INSERT INTO aca_activity_daily_player (guild_id, activity_name, day, user_id)
+				VALUES (?, ?, ?, ?)`),
+			session.guildID,
+			session.name,
+			"2024-01-01",
+			session.userID,
+		)
+		if err != nil {
+			return oops.Wrapf(err, "failed to insert aggregated player")
+		}
+	}
+
+	return nil
+}
+
+func presence() *events.PresenceUpdate {
+	return &events.PresenceUpdate{
+		EventPresenceUpdate: gateway.EventPresenceUpdate{
+			Presence: discord.Presence{PresenceUser: discord.PresenceUser{ID: userID}, GuildID: guildID},
+		},
+	}
+}
+
+// expectCount checks the rows of user in table by guild, the guilds left out of expected have none.
+func expectCount(
+	ctx context.Context,
+	db *sql.DB,
+	dialect database.Dialect,
+	table string,
+	user snowflake.ID,
+	expected map[snowflake.ID]int,
+) error {
+	for _, guild := range []snowflake.ID{guildID, otherGuildID} {
+		var count int
+
+		err := db.QueryRowContext(
+			ctx,
+			dialect.Rebind(`SELECT COUNT(*) FROM `+table+` WHERE guild_id = ? AND user_id = ?`),
+			guild,
+			user,
+		).Scan(&count)
+		if err != nil {
+			return oops.Wrapf(err, "failed to count %s", table)
+		}
+
+		if count != expected[guild] {
+			return oops.Errorf("expected %d rows of %d in %s of %d, got %d", expected[guild], user, table, guild, count)
+		}
+	}
+
+	return nil
+}
+
+// expectAudits checks the audit holds exactly the expected rows, in the order of the actions.
+func expectAudits(ctx context.Context, db *sql.DB, expected []audit) error {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT guild_id, user_id, actor_id, action, deleted_rows FROM aca_privacy_audit ORDER BY uuid`,
+	)
+	if err != nil {
+		return oops.Wrapf(err, "failed to select audits")
+	}
+	defer rows.Close()
+
+	var audits []audit
+
+	for rows.Next() {
+		var row audit
+
+		err = rows.Scan(&row.guildID, &row.userID, &row.actorID, &row.action, &row.deletedRows)
+		if err != nil {
+			return oops.Wrapf(err, "failed to scan audit")
+		}
+
+		audits = append(audits, row)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return oops.Wrapf(err, "failed to read audits")
+	}
+
+	if !slices.Equal(audits, expected) {
+		return oops.Errorf("expected audits %v, got %v", expected, audits)
+	}
+
+	return nil
+}
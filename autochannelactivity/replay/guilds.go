@@ -0,0 +1,155 @@
+package replay
+
+import (
+	"encoding/json"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/cache"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/host/fakediscord"
+)
+
+// guilds plays the recorded guild and channel events the way the gateway does for the bot: the fakediscord server
+// and the channel caches follow them, and the channel updates and deletions go through the listeners.
+type guilds struct {
+	server    *fakediscord.Server
+	caches    cache.Caches
+	listeners []bot.EventListener
+	known     map[snowflake.ID]bool
+	// ids maps the recorded ids of the channels the bot made to the ids of the ones the replay made
+	ids map[snowflake.ID]snowflake.ID
+}
+
+func newGuilds(server *fakediscord.Server, caches cache.Caches, listeners ...bot.EventListener) *guilds {
+	return &guilds{
+		server:    server,
+		caches:    caches,
+		listeners: listeners,
+		known:     map[snowflake.ID]bool{},
+		ids:       map[snowflake.ID]snowflake.ID{},
+	}
+}
+
+// ensure makes a guild without GUILD_CREATE in the recording exist, without channels.
+func (g *guilds) ensure(guildID snowflake.ID) {
+	if !g.known[guildID] {
+		g.known[guildID] = true
+		g.server.AddGuild(guildID)
+	}
+}
+
+// seed replaces the channels of a guild with the ones of its GUILD_CREATE.
+func (g *guilds) seed(guild discord.GatewayGuild) {
+	channels := make([]fakediscord.Channel, 0, len(guild.Channels))
+
+	g.caches.RemoveChannelsByGuildID(guild.ID)
+
+	for _, channel := range guild.Channels {
+		channel = discord.ApplyGuildIDToChannel(channel, guild.ID)
+		channels = append(channels, fakeChannel(channel, channel.ID()))
+		g.caches.AddChannel(channel)
+	}
+
+	g.known[guild.ID] = true
+	g.server.AddGuild(guild.ID, channels...)
+}
+
+// apply plays a channel event. The creation of a channel named as one the replay already made is the event of the
+// bot own creation, it is skipped and the later events of the channel are played on the one of the replay.
+func (g *guilds) apply(eventType gateway.EventType, recorded discord.GuildChannel) error {
+	guildID := recorded.GuildID()
+	g.ensure(guildID)
+
+	if eventType == gateway.EventTypeChannelCreate {
+		for _, existing := range g.server.Channels(guildID) {
+			if existing.ID != recorded.ID() && existing.Name == recorded.Name() {
+				g.ids[recorded.ID()] = existing.ID
+
+				return nil
+			}
+		}
+	}
+
+	channelID := recorded.ID()
+	if id, found := g.ids[channelID]; found {
+		channelID = id
+	}
+
+	channel := fakeChannel(recorded, channelID)
+
+	guildChannel, err := toGuildChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	oldChannel, _ := g.caches.Channel(channelID)
+	generic := &events.GenericGuildChannel{
+		GenericEvent: events.NewGenericEvent(nil, 0, 0),
+		ChannelID:    channelID,
+		Channel:      guildChannel,
+		GuildID:      guildID,
+	}
+
+	switch eventType {
+	case gateway.EventTypeChannelDelete:
+		g.server.RemoveChannel(guildID, channelID)
+		g.caches.RemoveChannel(channelID)
+		g.dispatch(&events.GuildChannelDelete{GenericGuildChannel: generic})
+	case gateway.EventTypeChannelUpdate:
+		g.server.PutChannel(guildID, channel)
+		g.caches.AddChannel(guildChannel)
+		g.dispatch(&events.GuildChannelUpdate{GenericGuildChannel: generic, OldChannel: oldChannel})
+	default:
+		g.server.PutChannel(guildID, channel)
+		g.caches.AddChannel(guildChannel)
+		g.dispatch(&events.GuildChannelCreate{GenericGuildChannel: generic})
+	}
+
+	return nil
+}
+
+func (g *guilds) dispatch(event bot.Event) {
+	for _, listener := range g.listeners {
+		listener.OnEvent(event)
+	}
+}
+
+// fakeChannel is channel as the fakediscord server keeps it, under channelID.
+func fakeChannel(channel discord.GuildChannel, channelID snowflake.ID) fakediscord.Channel {
+	return fakediscord.Channel{
+		ID:                   channelID,
+		Type:                 channel.Type(),
+		GuildID:              channel.GuildID(),
+		Name:                 channel.Name(),
+		Position:             channel.Position(),
+		ParentID:             channel.ParentID(),
+		PermissionOverwrites: []any{},
+	}
+}
+
+// toGuildChannel goes through JSON, disgo channels have no exported fields.
+func toGuildChannel(c fakediscord.Channel) (discord.GuildChannel, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to marshal channel")
+	}
+
+	var channel discord.UnmarshalChannel
+
+	err = json.Unmarshal(data, &channel)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to unmarshal channel")
+	}
+
+	guildChannel, ok := channel.Channel.(discord.GuildChannel)
+	if !ok {
+		return nil, oops.Errorf("channel %d is not a guild channel", c.ID)
+	}
+
+	return guildChannel, nil
+}
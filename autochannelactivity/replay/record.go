@@ -0,0 +1,185 @@
+// Package replay records raw gateway events to a JSONL file and replays them through the presence handler and the
+// channel listeners, against a fakediscord server and a scratch SQLite database, reporting every Discord mutation and
+// database change.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+)
+
+// Entry is one line of a recording, Data is the raw payload of the gateway event.
+type Entry struct {
+	Type gateway.EventType `json:"type"`
+	At   time.Time         `json:"at"`
+	Data json.RawMessage   `json:"data"`
+}
+
+func recorded(eventType gateway.EventType) bool {
+	switch eventType {
+	case gateway.EventTypePresenceUpdate,
+		gateway.EventTypeGuildCreate,
+		gateway.EventTypeChannelCreate,
+		gateway.EventTypeChannelUpdate,
+		gateway.EventTypeChannelDelete:
+		return true
+	}
+
+	return false
+}
+
+// OptOuts tells the users who opted out, the privacy.Repository of the module.
+type OptOuts interface {
+	IsOptedOut(ctx context.Context, userID snowflake.ID) (bool, error)
+}
+
+// Recorder writes the presence, guild and channel events of the gateway, it needs raw events
+// enabled with gateway.WithEnableRawEvents. The presences and members of the users who opted out are left out.
+type Recorder struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	encoder *json.Encoder
+	optOuts OptOuts
+	logger  *slog.Logger
+}
+
+func NewRecorder(ctx context.Context, w io.Writer, optOuts OptOuts, logger *slog.Logger) *Recorder {
+	return &Recorder{ctx: ctx, encoder: json.NewEncoder(w), optOuts: optOuts, logger: logger}
+}
+
+// Options enable raw events and register the recorder on the client.
+func (r *Recorder) Options() []bot.ConfigOpt {
+	return []bot.ConfigOpt{
+		bot.WithGatewayConfigOpts(gateway.WithEnableRawEvents(true)),
+		bot.WithEventListeners(&events.ListenerAdapter{OnRaw: r.onRaw}),
+	}
+}
+
+func (r *Recorder) onRaw(event *events.Raw) {
+	payload, err := io.ReadAll(event.Payload)
+	if err != nil {
+		r.logger.Error("failed to read raw event", slog.Any("error", oops.Wrap(err)))
+
+		return
+	}
+
+	err = r.RecordRaw(event.EventType, payload, time.Now())
+	if err != nil {
+		r.logger.Error("failed to record event", slog.Any("error", err))
+	}
+}
+
+// RecordRaw records the payload of a gateway event when its type is recorded, without the users who opted out.
+func (r *Recorder) RecordRaw(eventType gateway.EventType, payload json.RawMessage, at time.Time) error {
+	if !recorded(eventType) {
+		return nil
+	}
+
+	payload, err := r.redact(eventType, payload)
+	if err != nil || payload == nil {
+		return err
+	}
+
+	return r.Record(Entry{Type: eventType, At: at.UTC(), Data: payload})
+}
+
+// userPayload is the part of a presence or a member naming its user.
+type userPayload struct {
+	User struct {
+		ID snowflake.ID `json:"id"`
+	} `json:"user"`
+}
+
+// redact returns the payload without the users who opted out, nil when the event is about one of them.
+// A guild keeps its channels and loses the presences and members of those users.
+func (r *Recorder) redact(eventType gateway.EventType, payload json.RawMessage) (json.RawMessage, error) {
+	switch eventType {
+	case gateway.EventTypePresenceUpdate:
+		keep, err := r.keep(payload)
+		if err != nil || !keep {
+			return nil, err
+		}
+	case gateway.EventTypeGuildCreate:
+		return r.redactGuild(payload)
+	}
+
+	return payload, nil
+}
+
+func (r *Recorder) redactGuild(payload json.RawMessage) (json.RawMessage, error) {
+	var guild map[string]json.RawMessage
+
+	err := json.Unmarshal(payload, &guild)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to decode guild")
+	}
+
+	for _, key := range []string{"presences", "members"} {
+		if guild[key] == nil {
+			continue
+		}
+
+		var users []json.RawMessage
+
+		errUsers := json.Unmarshal(guild[key], &users)
+		if errUsers != nil {
+			return nil, oops.With("key", key).Wrapf(errUsers, "failed to decode guild users")
+		}
+
+		kept := make([]json.RawMessage, 0, len(users))
+
+		for _, user := range users {
+			keep, errKeep := r.keep(user)
+			if errKeep != nil {
+				return nil, errKeep
+			}
+
+			if keep {
+				kept = append(kept, user)
+			}
+		}
+
+		guild[key], errUsers = json.Marshal(kept)
+		if errUsers != nil {
+			return nil, oops.With("key", key).Wrapf(errUsers, "failed to encode guild users")
+		}
+	}
+
+	redacted, err := json.Marshal(guild)
+
+	return redacted, oops.Wrapf(err, "failed to encode guild")
+}
+
+// keep tells if the user of a presence or a member did not opt out.
+func (r *Recorder) keep(payload json.RawMessage) (bool, error) {
+	var user userPayload
+
+	err := json.Unmarshal(payload, &user)
+	if err != nil {
+		return false, oops.Wrapf(err, "failed to decode user")
+	}
+
+	optedOut, err := r.optOuts.IsOptedOut(r.ctx, user.User.ID)
+	if err != nil {
+		return false, oops.With("user_id", user.User.ID).Wrapf(err, "failed to check opt-out")
+	}
+
+	return !optedOut, nil
+}
+
+func (r *Recorder) Record(entry Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return oops.Wrapf(r.encoder.Encode(entry), "failed to write entry")
+}
@@ -0,0 +1,136 @@
+package replay_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity/replay"
+)
+
+const (
+	userID         = snowflake.ID(1)
+	optedOutUserID = snowflake.ID(2)
+)
+
+type optOuts map[snowflake.ID]bool
+
+func (o optOuts) IsOptedOut(_ context.Context, userID snowflake.ID) (bool, error) {
+	return o[userID], nil
+}
+
+type failingOptOuts struct{}
+
+func (failingOptOuts) IsOptedOut(context.Context, snowflake.ID) (bool, error) {
+	return false, oops.Errorf("opt-outs unavailable")
+}
+
+func TestRecorderLeavesOptedOutUsersOut(t *testing.T) {
+	guild := `{"id":"100","channels":[{"id":"110","type":4,"name":"game","position":0}],` +
+		`"members":[{"user":{"id":"1"}},{"user":{"id":"2"}}],` +
+		`"presences":[{"user":{"id":"2"},"status":"online"},{"user":{"id":"1"},"status":"online"}]}`
+
+	tests := []struct {
+		name      string
+		eventType gateway.EventType
+		payload   string
+		optOuts   replay.OptOuts
+		expected  string
+		fails     bool
+	}{
+		{
+			name:      "a presence is recorded",
+			eventType: gateway.EventTypePresenceUpdate,
+			payload:   `{"user":{"id":"1"},"guild_id":"100","status":"online"}`,
+			optOuts:   optOuts{optedOutUserID: true},
+			expected:  `{"user":{"id":"1"},"guild_id":"100","status":"online"}`,
+		},
+		{
+			name:      "the presence of an opted out user is dropped",
+			eventType: gateway.EventTypePresenceUpdate,
+			payload:   `{"user":{"id":"2"},"guild_id":"100","status":"online"}`,
+			optOuts:   optOuts{optedOutUserID: true},
+		},
+		{
+			name:      "a guild loses the members and presences of the opted out users",
+			eventType: gateway.EventTypeGuildCreate,
+			payload:   guild,
+			optOuts:   optOuts{optedOutUserID: true},
+			expected: `{"channels":[{"id":"110","type":4,"name":"game","position":0}],"id":"100",` +
+				`"members":[{"user":{"id":"1"}}],"presences":[{"user":{"id":"1"},"status":"online"}]}`,
+		},
+		{
+			name:      "a channel is recorded as is",
+			eventType: gateway.EventTypeChannelDelete,
+			payload:   `{"id":"120","type":0,"guild_id":"100","name":"dota-2","position":0}`,
+			optOuts:   failingOptOuts{},
+			expected:  `{"id":"120","type":0,"guild_id":"100","name":"dota-2","position":0}`,
+		},
+		{
+			name:      "an event is dropped when the opt-outs cannot be read",
+			eventType: gateway.EventTypePresenceUpdate,
+			payload:   `{"user":{"id":"1"},"guild_id":"100","status":"online"}`,
+			optOuts:   failingOptOuts{},
+			fails:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recording bytes.Buffer
+
+			recorder := replay.NewRecorder(
+				context.Background(), &recording, tt.optOuts, slog.New(slog.NewTextHandler(io.Discard, nil)),
+			)
+
+			err := recorder.RecordRaw(tt.eventType, []byte(tt.payload), time.Now())
+			if (err != nil) != tt.fails {
+				t.Fatalf("expected failure %t, got %v", tt.fails, err)
+			}
+
+			entries := readRecording(t, &recording)
+
+			if tt.expected == "" {
+				if len(entries) != 0 {
+					t.Errorf("expected nothing recorded, got %v", entries)
+				}
+
+				return
+			}
+
+			if len(entries) != 1 || entries[0].Type != tt.eventType || string(entries[0].Data) != tt.expected {
+				t.Errorf("expected a %s entry with %s, got %v", tt.eventType, tt.expected, entries)
+			}
+		})
+	}
+}
+
+func readRecording(t *testing.T, recording io.Reader) []replay.Entry {
+	t.Helper()
+
+	var entries []replay.Entry
+
+	scanner := bufio.NewScanner(recording)
+
+	for scanner.Scan() {
+		var entry replay.Entry
+
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
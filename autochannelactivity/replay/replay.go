@@ -0,0 +1,278 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/disgoorg/disgo/cache"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/oops"
+
+	"eggmech/autochannelactivity"
+	"eggmech/autochannelactivity/activity"
+	"eggmech/autochannelactivity/stream"
+	"eggmech/host"
+	"eggmech/host/database"
+	"eggmech/host/fakediscord"
+)
+
+const (
+	// maxEntrySize bounds a line of the recording, a GUILD_CREATE of a large guild is a few MB.
+	maxEntrySize = 64 << 20
+
+	replayToken = "replay"
+)
+
+// Replay runs a recording through activity.HandlePresence and the channel listeners, against a fakediscord server,
+// and writes the report as JSONL to report. Timestamps are shifted so the last entry happens now, usage windows then
+// see the recorded sessions.
+func Replay(
+	ctx context.Context,
+	recording io.Reader,
+	report io.Writer,
+	config autochannelactivity.Config,
+	logger *slog.Logger,
+) error {
+	entries, err := readEntries(recording)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "eggmech-replay")
+	if err != nil {
+		return oops.Wrapf(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	databaseConfig := host.DatabaseConfig{Driver: database.SQLiteName, Path: filepath.Join(dir, "database.sqlite3")}
+	dialect := database.SQLite()
+
+	migrations := autochannelactivity.Migrations(dialect, "")
+	migrations.Log = io.Discard
+
+	err = host.Migrate(ctx, databaseConfig, migrations, logger)
+	if err != nil {
+		return err
+	}
+
+	db, err := dialect.Open(databaseConfig.DSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := activity.BuildRepository(db, dialect, config.Thresholds)
+	defer repository.Close()
+
+	server := fakediscord.New(fakediscord.WithToken(replayToken))
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	caches := cache.New(cache.WithCaches(cache.FlagChannels))
+
+	r := replayer{
+		db:         db,
+		repository: repository,
+		server:     server,
+		client: rest.New(rest.NewClient(
+			replayToken,
+			rest.WithURL(httpServer.URL+fakediscord.APIPath),
+			rest.WithLogger(logger),
+			rest.WithRateLimiterConfigOpts(rest.WithRateLimiterLogger(logger)),
+		)),
+		guilds: newGuilds(
+			server,
+			caches,
+			activity.DeletionListener(ctx, repository, config.DeletionPolicies, logger),
+			activity.PlacementListener(ctx, repository, caches, config.Categories, logger),
+		),
+		categories: config.Categories,
+		namings:    config.Namings,
+		encoder:    json.NewEncoder(report),
+		summary:    map[string]int{},
+		logger:     logger,
+	}
+
+	if len(entries) > 0 {
+		r.shift = time.Since(entries[len(entries)-1].At)
+	}
+
+	for i, entry := range entries {
+		err = r.replay(ctx, i+1, entry)
+		if err != nil {
+			return oops.With("entry", i+1).Wrapf(err, "failed to replay entry")
+		}
+	}
+
+	return r.write(Line{Entry: len(entries), Kind: KindEnd, Summary: r.summary})
+}
+
+func readEntries(recording io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(nil, maxEntrySize)
+
+	var entries []Entry
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, oops.With("line", len(entries)+1).Wrapf(err, "failed to decode entry")
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, oops.Wrapf(scanner.Err(), "failed to read recording")
+}
+
+type replayer struct {
+	db         *sql.DB
+	repository *activity.SQLRepository
+	server     *fakediscord.Server
+	client     rest.Rest
+	guilds     *guilds
+	categories activity.Categories
+	namings    activity.Namings
+	encoder    *json.Encoder
+	shift      time.Duration
+	summary    map[string]int
+	logger     *slog.Logger
+}
+
+// replay plays an entry and reports the Discord requests and the database changes it made.
+func (r *replayer) replay(ctx context.Context, index int, entry Entry) error {
+	r.summary[string(entry.Type)]++
+
+	before, err := takeSnapshot(ctx, r.db)
+	if err != nil {
+		return err
+	}
+
+	requests := len(r.server.Requests())
+
+	err = r.play(ctx, index, entry)
+	if err != nil {
+		return err
+	}
+
+	for _, request := range r.server.Requests()[requests:] {
+		line, reported := restLine(index, request)
+		if !reported {
+			continue
+		}
+
+		r.summary[line.Method]++
+
+		err = r.write(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	after, err := takeSnapshot(ctx, r.db)
+	if err != nil {
+		return err
+	}
+
+	lines, err := diff(index, before, after)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		r.summary[line.Table+" "+line.Change]++
+
+		err = r.write(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *replayer) play(ctx context.Context, index int, entry Entry) error {
+	switch entry.Type {
+	case gateway.EventTypeGuildCreate:
+		var guild gateway.EventGuildCreate
+
+		err := json.Unmarshal(entry.Data, &guild)
+		if err != nil {
+			return oops.Wrapf(err, "failed to decode guild")
+		}
+
+		r.guilds.seed(guild.GatewayGuild)
+	case gateway.EventTypeChannelCreate, gateway.EventTypeChannelUpdate, gateway.EventTypeChannelDelete:
+		var channel gateway.EventChannelCreate
+
+		err := json.Unmarshal(entry.Data, &channel)
+		if err != nil {
+			return oops.Wrapf(err, "failed to decode channel")
+		}
+
+		return r.guilds.apply(entry.Type, channel.GuildChannel)
+	case gateway.EventTypePresenceUpdate:
+		return r.presence(ctx, index, entry)
+	}
+
+	return nil
+}
+
+func (r *replayer) presence(ctx context.Context, index int, entry Entry) error {
+	var presence gateway.EventPresenceUpdate
+
+	err := json.Unmarshal(entry.Data, &presence)
+	if err != nil {
+		return oops.Wrapf(err, "failed to decode presence")
+	}
+
+	for i, presenceActivity := range presence.Activities {
+		if !presenceActivity.CreatedAt.IsZero() {
+			presence.Activities[i].CreatedAt = presenceActivity.CreatedAt.Add(r.shift)
+		}
+	}
+
+	r.guilds.ensure(presence.GuildID)
+
+	err = activity.HandlePresence(
+		ctx,
+		0,
+		r.client,
+		r.repository,
+		stream.NoopPublisher{},
+		r.categories,
+		r.namings,
+		activity.NewMetrics(prometheus.NewRegistry()),
+		&events.PresenceUpdate{EventPresenceUpdate: presence},
+		entry.At.Add(r.shift),
+		r.logger,
+	)
+	if err != nil {
+		r.summary["errors"]++
+		r.logger.WarnContext(ctx, "presence failed", slog.Int("entry", index), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+func (r *replayer) write(line Line) error {
+	return oops.Wrapf(r.encoder.Encode(line), "failed to write report")
+}
@@ -0,0 +1,69 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"eggmech/autochannelactivity"
+	"eggmech/autochannelactivity/replay"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden reports from the recordings")
+
+// TestReplay replays the recordings of testdata and compares their report with the golden one.
+// Regenerate them with `go test ./replay -update-golden`.
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+		golden    string
+	}{
+		{name: "churn", recording: "testdata/churn.jsonl", golden: "testdata/churn.report.jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recording, err := os.Open(tt.recording)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer recording.Close()
+
+			var report bytes.Buffer
+
+			err = replay.Replay(
+				context.Background(),
+				recording,
+				&report,
+				autochannelactivity.DefaultConfig(),
+				slog.New(slog.NewTextHandler(io.Discard, nil)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *updateGolden {
+				err = os.WriteFile(tt.golden, report.Bytes(), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			golden, err := os.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(golden, report.Bytes()) {
+				t.Errorf("replay differs from %s, rerun with -update-golden to accept it:\n%s", tt.golden, report.String())
+			}
+		})
+	}
+}
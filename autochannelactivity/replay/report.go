@@ -0,0 +1,222 @@
+package replay
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/samber/oops"
+
+	"eggmech/host/fakediscord"
+)
+
+// Line is one line of the report. Rows leave out the uuids and timestamps so that a report
+// only changes when the behaviour does, which makes it usable as a golden file.
+type Line struct {
+	Entry   int            `json:"entry"`
+	Kind    string         `json:"kind"`
+	GuildID string         `json:"guild_id,omitempty"`
+	Method  string         `json:"method,omitempty"`
+	Arg     any            `json:"arg,omitempty"`
+	Status  int            `json:"status,omitempty"`
+	Table   string         `json:"table,omitempty"`
+	Change  string         `json:"change,omitempty"`
+	Row     map[string]any `json:"row,omitempty"`
+	Summary map[string]int `json:"summary,omitempty"`
+}
+
+const (
+	KindRest = "rest"
+	KindDB   = "db"
+	KindEnd  = "end"
+)
+
+// reportedTables are diffed after every presence, keyed by their uuid column.
+func reportedTables() []string {
+	return []string{"aca_activity", "aca_activity_settings", "aca_activity_channel"}
+}
+
+func hiddenColumn(column string) bool {
+	switch column {
+	case "uuid", "activity_settings_uuid", "started_at", "ended_at":
+		return true
+	}
+
+	return false
+}
+
+// markedColumn is a timestamp the listeners set to now, it is reported as true once set.
+func markedColumn(column string) bool {
+	return column == "deleted_at" || column == "manually_placed_at"
+}
+
+// restLine reports a channel mutation the fakediscord server answered under the name of its rest.Rest method, the
+// reads are left out and Status is only kept for the refusals.
+func restLine(entry int, request fakediscord.Request) (Line, bool) {
+	guildPath, isGuild := strings.CutPrefix(request.Path, fakediscord.APIPath+"/guilds/")
+	guildID, endpoint, _ := strings.Cut(guildPath, "/")
+
+	if !isGuild || endpoint != "channels" {
+		return Line{}, false
+	}
+
+	line := Line{Entry: entry, Kind: KindRest, GuildID: guildID, Arg: json.RawMessage(request.Body)}
+
+	switch request.Method {
+	case http.MethodPost:
+		line.Method = "CreateGuildChannel"
+	case http.MethodPatch:
+		line.Method = "UpdateChannelPositions"
+	default:
+		return Line{}, false
+	}
+
+	if request.Status >= http.StatusBadRequest {
+		line.Status = request.Status
+	}
+
+	return line, true
+}
+
+// snapshot maps table and uuid to the row, encoded for comparison.
+type snapshot map[string]map[string]string
+
+func takeSnapshot(ctx context.Context, db *sql.DB) (snapshot, error) {
+	snap := snapshot{}
+
+	for _, table := range reportedTables() {
+		rows, err := readRows(ctx, db, table)
+		if err != nil {
+			return nil, err
+		}
+
+		snap[table] = map[string]string{}
+
+		for _, row := range rows {
+			encoded, errMarshal := json.Marshal(row)
+			if errMarshal != nil {
+				return nil, oops.Wrapf(errMarshal, "failed to encode row")
+			}
+
+			uuid, _ := row["uuid"].(string)
+			snap[table][uuid] = string(encoded)
+		}
+	}
+
+	return snap, nil
+}
+
+func readRows(ctx context.Context, db *sql.DB, table string) ([]map[string]any, error) {
+	//nolint:gosec // table names come from reportedTables
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+table+" ORDER BY uuid")
+	if err != nil {
+		return nil, oops.With("table", table).Wrapf(err, "failed to read table")
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to read columns")
+	}
+
+	var result []map[string]any
+
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to scan row")
+		}
+
+		row := map[string]any{}
+
+		for i, column := range columns {
+			if text, ok := values[i].([]byte); ok {
+				values[i] = string(text)
+			}
+
+			row[column] = values[i]
+		}
+
+		result = append(result, row)
+	}
+
+	return result, oops.Wrapf(rows.Err(), "failed to fetch rows")
+}
+
+// diff reports the rows inserted, updated and deleted between two snapshots, in a stable order.
+func diff(entry int, before snapshot, after snapshot) ([]Line, error) {
+	var lines []Line
+
+	for _, table := range reportedTables() {
+		keys := slices.Sorted(maps.Keys(after[table]))
+
+		for _, key := range keys {
+			previous, existed := before[table][key]
+			if existed && previous == after[table][key] {
+				continue
+			}
+
+			change := "insert"
+			if existed {
+				change = "update"
+			}
+
+			line, err := rowLine(entry, table, change, after[table][key])
+			if err != nil {
+				return nil, err
+			}
+
+			lines = append(lines, line)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(before[table])) {
+			if _, kept := after[table][key]; kept {
+				continue
+			}
+
+			line, err := rowLine(entry, table, "delete", before[table][key])
+			if err != nil {
+				return nil, err
+			}
+
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+func rowLine(entry int, table string, change string, encoded string) (Line, error) {
+	var row map[string]any
+
+	// numbers stay json.Number, snowflakes do not fit a float64
+	decoder := json.NewDecoder(strings.NewReader(encoded))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&row)
+	if err != nil {
+		return Line{}, oops.Wrapf(err, "failed to decode row")
+	}
+
+	for column, value := range row {
+		switch {
+		case hiddenColumn(column):
+			delete(row, column)
+		case markedColumn(column) && value != nil:
+			row[column] = true
+		}
+	}
+
+	return Line{Entry: entry, Kind: KindDB, Table: table, Change: change, Row: row}, nil
+}
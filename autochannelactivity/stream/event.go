@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/samber/oops"
+)
+
+// Version of the envelope and payloads, see schema.json. Bumped on breaking changes only.
+const Version = 1
+
+const (
+	SubjectSessionStarted  = "aca.session.started"
+	SubjectSessionEnded    = "aca.session.ended"
+	SubjectChannelCreated  = "aca.channel.created"
+	SubjectChannelArchived = "aca.channel.archived"
+)
+
+type Event interface {
+	Subject() string
+}
+
+// Envelope is the message published on the stream, ID is also the JetStream message ID for deduplication.
+type Envelope struct {
+	ID         string       `json:"id"`
+	Version    int          `json:"version"`
+	Type       string       `json:"type"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	GuildID    snowflake.ID `json:"guild_id"`
+	Data       Event        `json:"data"`
+}
+
+type SessionStarted struct {
+	UserID       snowflake.ID `json:"user_id"`
+	ActivityName string       `json:"activity_name"`
+	StartedAt    time.Time    `json:"started_at"`
+}
+
+type SessionEnded struct {
+	UserID       snowflake.ID `json:"user_id"`
+	ActivityName string       `json:"activity_name"`
+	EndedAt      time.Time    `json:"ended_at"`
+}
+
+type ChannelCreated struct {
+	ChannelID    snowflake.ID `json:"channel_id"`
+	ChannelName  string       `json:"channel_name"`
+	CategoryID   snowflake.ID `json:"category_id"`
+	ActivityName string       `json:"activity_name"`
+}
+
+type ChannelArchived struct {
+	ChannelID    snowflake.ID `json:"channel_id"`
+	CategoryID   snowflake.ID `json:"category_id"`
+	ActivityName string       `json:"activity_name"`
+}
+
+func NewEnvelope(guildID snowflake.ID, event Event, occurredAt time.Time) (Envelope, error) {
+	uuidv7, err := uuid.NewV7()
+	if err != nil {
+		return Envelope{}, oops.Wrapf(err, "failed to create uuidv7")
+	}
+
+	return Envelope{
+		ID:         uuidv7.String(),
+		Version:    Version,
+		Type:       event.Subject(),
+		OccurredAt: occurredAt.UTC(),
+		GuildID:    guildID,
+		Data:       event,
+	}, nil
+}
+
+func (SessionStarted) Subject() string {
+	return SubjectSessionStarted
+}
+
+func (SessionEnded) Subject() string {
+	return SubjectSessionEnded
+}
+
+func (ChannelCreated) Subject() string {
+	return SubjectChannelCreated
+}
+
+func (ChannelArchived) Subject() string {
+	return SubjectChannelArchived
+}
@@ -0,0 +1,231 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/samber/oops"
+)
+
+const PresenceStreamName = "ACA_PRESENCES"
+const subjectPresence = "aca.presence"
+const presenceMaxAge = time.Hour
+
+// presenceMaxDeliver bounds the redeliveries of a presence the handler keeps failing on.
+const presenceMaxDeliver = 5
+
+// Presence is the normalized PresenceUpdate relayed from the gateway to the workers,
+// only game activities are kept.
+type Presence struct {
+	GuildID    snowflake.ID       `json:"guild_id"`
+	UserID     snowflake.ID       `json:"user_id"`
+	Activities []PresenceActivity `json:"activities"`
+	ReceivedAt time.Time          `json:"received_at"`
+}
+
+type PresenceActivity struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PresenceSubject is aca.presence.<partition>.<guild_id>, a guild always lands on the same partition
+// so its events are handled in order by a single worker.
+func PresenceSubject(guildID snowflake.ID, partitions int) string {
+	partition := uint64(guildID) % uint64(max(partitions, 1))
+
+	return subjectPresence + "." + strconv.FormatUint(partition, 10) + "." + guildID.String()
+}
+
+func NewPresence(event *events.PresenceUpdate, receivedAt time.Time) Presence {
+	presence := Presence{
+		GuildID:    event.GuildID,
+		UserID:     event.PresenceUser.ID,
+		Activities: []PresenceActivity{},
+		ReceivedAt: receivedAt.UTC(),
+	}
+
+	for _, activity := range event.Activities {
+		if activity.Type != discord.ActivityTypeGame {
+			continue
+		}
+
+		presence.Activities = append(presence.Activities, PresenceActivity{
+			Name:      activity.Name,
+			CreatedAt: activity.CreatedAt,
+		})
+	}
+
+	return presence
+}
+
+func (p Presence) Event() *events.PresenceUpdate {
+	activities := make([]discord.Activity, 0, len(p.Activities))
+
+	for _, activity := range p.Activities {
+		activities = append(activities, discord.Activity{
+			Name:      activity.Name,
+			Type:      discord.ActivityTypeGame,
+			CreatedAt: activity.CreatedAt,
+		})
+	}
+
+	return &events.PresenceUpdate{
+		EventPresenceUpdate: gateway.EventPresenceUpdate{
+			Presence: discord.Presence{
+				PresenceUser: discord.PresenceUser{ID: p.UserID},
+				GuildID:      p.GuildID,
+				Activities:   activities,
+			},
+		},
+	}
+}
+
+func presenceStream(ctx context.Context, nc *nats.Conn) (jetstream.JetStream, jetstream.Stream, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, nil, oops.Wrapf(err, "failed to create jetstream context")
+	}
+
+	presences, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        PresenceStreamName,
+		Description: "autochannelactivity presences relayed by the gateway",
+		Subjects:    []string{subjectPresence + ".>"},
+		Retention:   jetstream.WorkQueuePolicy,
+		MaxAge:      presenceMaxAge,
+	})
+	if err != nil {
+		return nil, nil, oops.Wrapf(err, "failed to create stream %s", PresenceStreamName)
+	}
+
+	return js, presences, nil
+}
+
+// PresenceRelay returns the gateway listener publishing every PresenceUpdate on its partition subject.
+func PresenceRelay(
+	ctx context.Context,
+	nc *nats.Conn,
+	botID snowflake.ID,
+	partitions int,
+	logger *slog.Logger,
+) (func(event *events.PresenceUpdate), error) {
+	js, _, err := presenceStream(ctx, nc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(event *events.PresenceUpdate) {
+		if botID == event.PresenceUser.ID {
+			return
+		}
+
+		payload, errMarshal := json.Marshal(NewPresence(event, time.Now()))
+		if errMarshal != nil {
+			logger.ErrorContext(ctx, "failed to marshal presence", slog.Any("error", oops.Wrap(errMarshal)))
+
+			return
+		}
+
+		_, errPublish := js.Publish(ctx, PresenceSubject(event.GuildID, partitions), payload)
+		if errPublish != nil {
+			logger.ErrorContext(ctx, "failed to relay presence", slog.Any("error", oops.Wrap(errPublish)))
+		}
+	}, nil
+}
+
+// ConsumePresences feeds the presences of one partition to handler until ctx is done. A presence the handler panics
+// on, or fails on with an error retryable tells, is delivered again, up to presenceMaxDeliver times. The presences
+// failing otherwise are dropped.
+func ConsumePresences(
+	ctx context.Context,
+	nc *nats.Conn,
+	partition int,
+	handler func(event *events.PresenceUpdate) error,
+	retryable func(err error) bool,
+	logger *slog.Logger,
+) error {
+	_, presences, err := presenceStream(ctx, nc)
+	if err != nil {
+		return err
+	}
+
+	name := "aca-worker-" + strconv.Itoa(partition)
+
+	consumer, err := presences.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       name,
+		FilterSubject: subjectPresence + "." + strconv.Itoa(partition) + ".>",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		MaxAckPending: 1,
+		MaxDeliver:    presenceMaxDeliver,
+	})
+	if err != nil {
+		return oops.Wrapf(err, "failed to create consumer %s", name)
+	}
+
+	consumeContext, err := consumer.Consume(func(msg jetstream.Msg) {
+		var presence Presence
+
+		errUnmarshal := json.Unmarshal(msg.Data(), &presence)
+		if errUnmarshal != nil {
+			logger.ErrorContext(ctx, "failed to unmarshal presence", slog.Any("error", oops.Wrap(errUnmarshal)))
+			_ = msg.Term()
+
+			return
+		}
+
+		errHandle := handle(handler, presence)
+		if errHandle == nil {
+			_ = msg.Ack()
+
+			return
+		}
+
+		metadata, errMetadata := msg.Metadata()
+		if (errors.Is(errHandle, errHandlerPanicked) || retryable(errHandle)) &&
+			errMetadata == nil && metadata.NumDelivered < presenceMaxDeliver {
+			logger.WarnContext(
+				ctx,
+				"failed to handle presence, it is delivered again",
+				slog.Uint64("delivered", metadata.NumDelivered),
+				slog.Any("error", errHandle),
+			)
+			_ = msg.Nak()
+
+			return
+		}
+
+		logger.ErrorContext(ctx, "failed to handle presence, it is dropped", slog.Any("error", errHandle))
+		_ = msg.Term()
+	})
+	if err != nil {
+		return oops.Wrapf(err, "failed to consume presences")
+	}
+
+	<-ctx.Done()
+	consumeContext.Drain()
+
+	return nil
+}
+
+// errHandlerPanicked is the error of the presences the handler panicked on.
+var errHandlerPanicked = errors.New("presence handler panicked")
+
+// handle recovers the panics of handler, as disgo does for the gateway listeners.
+func handle(handler func(event *events.PresenceUpdate) error, presence Presence) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = oops.With("guild_id", presence.GuildID).Wrapf(errHandlerPanicked, "%v", r)
+		}
+	}()
+
+	return handler(presence.Event())
+}
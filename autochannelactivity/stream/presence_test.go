@@ -0,0 +1,257 @@
+package stream_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"eggmech/autochannelactivity/stream"
+)
+
+const (
+	botID      = snowflake.ID(1)
+	userID     = snowflake.ID(2)
+	partitions = 2
+)
+
+func TestPresenceSubject(t *testing.T) {
+	tests := []struct {
+		guildID    snowflake.ID
+		partitions int
+		expected   string
+	}{
+		{guildID: 1000, partitions: 2, expected: "aca.presence.0.1000"},
+		{guildID: 1001, partitions: 2, expected: "aca.presence.1.1001"},
+		{guildID: 1001, partitions: 0, expected: "aca.presence.0.1001"},
+	}
+
+	for _, tt := range tests {
+		subject := stream.PresenceSubject(tt.guildID, tt.partitions)
+		if subject != tt.expected {
+			t.Errorf("expected %s for guild %d over %d partitions, got %s", tt.expected, tt.guildID, tt.partitions, subject)
+		}
+	}
+}
+
+func TestPresenceRoundTrip(t *testing.T) {
+	nc := runJetStream(t)
+	relay := presenceRelay(t, nc)
+
+	// the bot own presence is never relayed
+	relay(presenceUpdate(botID, discord.Activity{Name: "Celeste", Type: discord.ActivityTypeGame}))
+	relay(presenceUpdate(
+		userID,
+		discord.Activity{Name: "Celeste", Type: discord.ActivityTypeGame},
+		discord.Activity{Name: "Spotify", Type: discord.ActivityTypeListening},
+	))
+
+	received := consumePresences(t, nc, 1, func(*events.PresenceUpdate) error { return nil })
+
+	event := receive(t, received)
+	if event.PresenceUser.ID != userID || event.GuildID != guildID {
+		t.Errorf("expected the presence of user %d in guild %d, got %+v", userID, guildID, event.Presence)
+	}
+
+	names := make([]string, 0, len(event.Activities))
+	for _, activity := range event.Activities {
+		names = append(names, activity.Name)
+	}
+
+	if !slices.Equal(names, []string{"Celeste"}) {
+		t.Errorf("expected only the game activity, got %v", names)
+	}
+
+	waitPresences(t, nc, 0)
+}
+
+// errRetryable is the error the consumers of the tests deliver again.
+var errRetryable = errors.New("database is locked")
+
+func TestPresenceRedeliveredAfterFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       func(delivery int) error
+		deliveries int
+	}{
+		{
+			name: "the handler panics once",
+			fail: func(delivery int) error {
+				if delivery == 1 {
+					panic("handler failed")
+				}
+
+				return nil
+			},
+			deliveries: 2,
+		},
+		{
+			name: "the handler fails once with a retryable error",
+			fail: func(delivery int) error {
+				if delivery == 1 {
+					return errRetryable
+				}
+
+				return nil
+			},
+			deliveries: 2,
+		},
+		{
+			name:       "the handler keeps failing with a retryable error",
+			fail:       func(int) error { return errRetryable },
+			deliveries: 5,
+		},
+		{
+			name:       "the handler fails with another error",
+			fail:       func(int) error { return errors.New("channel name refused") },
+			deliveries: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nc := runJetStream(t)
+			relay := presenceRelay(t, nc)
+
+			relay(presenceUpdate(userID, discord.Activity{Name: "Celeste", Type: discord.ActivityTypeGame}))
+
+			delivery := 0
+			received := consumePresences(t, nc, tt.deliveries+1, func(*events.PresenceUpdate) error {
+				delivery++
+
+				return tt.fail(delivery)
+			})
+
+			for range tt.deliveries {
+				event := receive(t, received)
+				if event.PresenceUser.ID != userID {
+					t.Errorf("expected the presence of user %d again, got %+v", userID, event.Presence)
+				}
+			}
+
+			waitPresences(t, nc, 0)
+
+			select {
+			case <-received:
+				t.Errorf("expected %d deliveries, got one more", tt.deliveries)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func presenceRelay(t *testing.T, nc *nats.Conn) func(event *events.PresenceUpdate) {
+	t.Helper()
+
+	relay, err := stream.PresenceRelay(context.Background(), nc, botID, partitions, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return relay
+}
+
+// consumePresences consumes partition 0 in the background, every delivery is sent on the returned channel before
+// handler runs on it. The consumer stops with the test.
+func consumePresences(
+	t *testing.T,
+	nc *nats.Conn,
+	deliveries int,
+	handler func(event *events.PresenceUpdate) error,
+) <-chan *events.PresenceUpdate {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan *events.PresenceUpdate, deliveries)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- stream.ConsumePresences(ctx, nc, 0, func(event *events.PresenceUpdate) error {
+			received <- event
+
+			return handler(event)
+		}, func(err error) bool { return errors.Is(err, errRetryable) }, discardLogger())
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+
+		err := <-done
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return received
+}
+
+func receive(t *testing.T, received <-chan *events.PresenceUpdate) *events.PresenceUpdate {
+	t.Helper()
+
+	select {
+	case event := <-received:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a presence to be delivered")
+
+		return nil
+	}
+}
+
+// waitPresences waits for the work queue to hold count presences, the acked ones are removed from it.
+func waitPresences(t *testing.T, nc *nats.Conn, count uint64) {
+	t.Helper()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	presences, err := js.Stream(context.Background(), stream.PresenceStreamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info *jetstream.StreamInfo
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		info, err = presences.Info(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.State.Msgs == count {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("expected %d presences left in the stream, got %d", count, info.State.Msgs)
+}
+
+func presenceUpdate(userID snowflake.ID, activities ...discord.Activity) *events.PresenceUpdate {
+	return &events.PresenceUpdate{
+		EventPresenceUpdate: gateway.EventPresenceUpdate{
+			Presence: discord.Presence{
+				PresenceUser: discord.PresenceUser{ID: userID},
+				GuildID:      guildID,
+				Activities:   activities,
+			},
+		},
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
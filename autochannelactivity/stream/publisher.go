@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/samber/oops"
+)
+
+const StreamName = "ACA_EVENTS"
+
+type Publisher interface {
+	Publish(ctx context.Context, envelope Envelope) error
+}
+
+// NoopPublisher is used when no NATS server is configured.
+type NoopPublisher struct{}
+
+func (NoopPublisher) Publish(context.Context, Envelope) error {
+	return nil
+}
+
+type JetStreamPublisher struct {
+	js jetstream.JetStream
+}
+
+// NewJetStreamPublisher creates or updates the events stream on the server behind nc,
+// which can be a real server or an embedded nats-server.
+func NewJetStreamPublisher(ctx context.Context, nc *nats.Conn) (*JetStreamPublisher, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to create jetstream context")
+	}
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        StreamName,
+		Description: "autochannelactivity sessions and channels events",
+		Subjects:    []string{"aca.session.>", "aca.channel.>"},
+	})
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to create stream %s", StreamName)
+	}
+
+	return &JetStreamPublisher{js: js}, nil
+}
+
+func (p *JetStreamPublisher) Publish(ctx context.Context, envelope Envelope) error {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return oops.Wrapf(err, "failed to marshal event")
+	}
+
+	_, err = p.js.Publish(ctx, envelope.Type, payload, jetstream.WithMsgID(envelope.ID))
+	if err != nil {
+		return oops.With("subject", envelope.Type).Wrapf(err, "failed to publish event")
+	}
+
+	return nil
+}
+
+// Publish logs instead of returning the error, events must never block the presence handling.
+func Publish(
+	ctx context.Context,
+	publisher Publisher,
+	guildID snowflake.ID,
+	event Event,
+	occurredAt time.Time,
+	logger *slog.Logger,
+) {
+	envelope, err := NewEnvelope(guildID, event, occurredAt)
+	if err == nil {
+		err = publisher.Publish(ctx, envelope)
+	}
+
+	if err != nil {
+		logger.WarnContext(
+			ctx,
+			"failed to publish event",
+			slog.String("subject", event.Subject()),
+			slog.Any("error", oops.Wrap(err)),
+		)
+	}
+}
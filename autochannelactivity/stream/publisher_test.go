@@ -0,0 +1,210 @@
+package stream_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"eggmech/autochannelactivity/stream"
+)
+
+const guildID = snowflake.ID(1000)
+
+func TestNewJetStreamPublisher(t *testing.T) {
+	ctx := context.Background()
+	nc := runJetStream(t)
+
+	// the stream is created once and updated on every start
+	for range 2 {
+		_, err := stream.NewJetStreamPublisher(ctx, nc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := eventsStream(t, nc)
+
+	info, err := events.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"aca.session.>", "aca.channel.>"}
+	if !slices.Equal(info.Config.Subjects, expected) {
+		t.Errorf("expected subjects %v, got %v", expected, info.Config.Subjects)
+	}
+}
+
+func TestJetStreamPublisherPublish(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		event   stream.Event
+		subject string
+	}{
+		{
+			name:    "session started",
+			event:   stream.SessionStarted{UserID: 1, ActivityName: "Celeste", StartedAt: now},
+			subject: stream.SubjectSessionStarted,
+		},
+		{
+			name:    "session ended",
+			event:   stream.SessionEnded{UserID: 1, ActivityName: "Celeste", EndedAt: now},
+			subject: stream.SubjectSessionEnded,
+		},
+		{
+			name:    "channel created",
+			event:   stream.ChannelCreated{ChannelID: 2, ChannelName: "celeste", CategoryID: 3, ActivityName: "celeste"},
+			subject: stream.SubjectChannelCreated,
+		},
+		{
+			name:    "channel archived",
+			event:   stream.ChannelArchived{ChannelID: 2, CategoryID: 4, ActivityName: "celeste"},
+			subject: stream.SubjectChannelArchived,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			nc := runJetStream(t)
+
+			publisher, err := stream.NewJetStreamPublisher(ctx, nc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			envelope, err := stream.NewEnvelope(guildID, tt.event, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = publisher.Publish(ctx, envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := eventsStream(t, nc).GetMsg(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.Subject != tt.subject {
+				t.Errorf("expected subject %s, got %s", tt.subject, msg.Subject)
+			}
+
+			if id := msg.Header.Get(jetstream.MsgIDHeader); id != envelope.ID {
+				t.Errorf("expected message id %s, got %q", envelope.ID, id)
+			}
+
+			var published struct {
+				ID         string       `json:"id"`
+				Version    int          `json:"version"`
+				Type       string       `json:"type"`
+				OccurredAt time.Time    `json:"occurred_at"`
+				GuildID    snowflake.ID `json:"guild_id"`
+			}
+
+			err = json.Unmarshal(msg.Data, &published)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if published.ID != envelope.ID || published.Version != stream.Version || published.Type != tt.subject ||
+				!published.OccurredAt.Equal(now) || published.GuildID != guildID {
+				t.Errorf("expected envelope %+v, got %+v", envelope, published)
+			}
+		})
+	}
+}
+
+func TestJetStreamPublisherDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	nc := runJetStream(t)
+
+	publisher, err := stream.NewJetStreamPublisher(ctx, nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := stream.NewEnvelope(guildID, stream.SessionStarted{UserID: 1, ActivityName: "Celeste"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a retried publish carries the same envelope ID
+	for range 2 {
+		err = publisher.Publish(ctx, envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := eventsStream(t, nc).Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.State.Msgs != 1 {
+		t.Errorf("expected the retry to be deduplicated, got %d messages", info.State.Msgs)
+	}
+}
+
+func TestPublishLogsFailures(t *testing.T) {
+	ctx := context.Background()
+	nc := runJetStream(t)
+
+	publisher, err := stream.NewJetStreamPublisher(ctx, nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nc.Close()
+
+	var logs bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	envelope, err := stream.NewEnvelope(guildID, stream.SessionEnded{UserID: 1, ActivityName: "Celeste"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = publisher.Publish(ctx, envelope)
+	if err == nil {
+		t.Error("expected publishing on a closed connection to fail")
+	}
+
+	stream.Publish(ctx, publisher, guildID, stream.SessionEnded{UserID: 1}, time.Now(), logger)
+
+	if !strings.Contains(logs.String(), "failed to publish event") ||
+		!strings.Contains(logs.String(), stream.SubjectSessionEnded) {
+		t.Errorf("expected the failure to be logged with its subject, got %q", logs.String())
+	}
+}
+
+func eventsStream(t *testing.T, nc *nats.Conn) jetstream.Stream {
+	t.Helper()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := js.Stream(context.Background(), stream.StreamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
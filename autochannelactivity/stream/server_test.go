@@ -0,0 +1,30 @@
+package stream_test
+
+import (
+	"testing"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+// runJetStream starts an embedded nats-server with JetStream and connects to it, both go away with the test.
+func runJetStream(t *testing.T) *nats.Conn {
+	t.Helper()
+
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	server := natsserver.RunServer(&opts)
+	t.Cleanup(server.Shutdown)
+
+	nc, err := nats.Connect(server.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(nc.Close)
+
+	return nc
+}
@@ -0,0 +1,436 @@
+package host
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+	"gopkg.in/yaml.v3"
+
+	"eggmech/host/database"
+)
+
+const redacted = "REDACTED"
+
+// Config is the host part of the configuration file, module sections are kept raw
+// and decoded by each module with DecodeModule. Every other top-level key is kept as a section too,
+// ValidateSections rejects the ones of no enabled module.
+type Config struct {
+	Discord  DiscordConfig        `yaml:"discord"`
+	Database DatabaseConfig       `yaml:"database"`
+	HTTP     HTTPConfig           `yaml:"http"`
+	Tracing  TracingConfig        `yaml:"tracing"`
+	Sharding ShardingConfig       `yaml:"sharding"`
+	Modules  []string             `yaml:"modules"`
+	Sections map[string]yaml.Node `yaml:",inline"`
+}
+
+// DiscordConfig.APIURL replaces the REST base URL, e.g. to point the client at a fakediscord server.
+type DiscordConfig struct {
+	Token  string `yaml:"token"`
+	APIURL string `yaml:"api_url,omitempty"`
+}
+
+// HTTPConfig.Addr is where /metrics, /healthz and /readyz are served, nothing is served when empty.
+// /healthz fails once a gateway shard is disconnected for longer than MaxDisconnect.
+type HTTPConfig struct {
+	Addr          string        `yaml:"addr"`
+	MaxDisconnect time.Duration `yaml:"max_disconnect"`
+}
+
+// TracingConfig.Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318, no span is exported when empty.
+// UserHashKey keys the HMAC of the user IDs set on the spans, they are left out of the spans when it is empty.
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+	UserHashKey string  `yaml:"user_hash_key"`
+}
+
+// ShardingConfig opens one gateway connection per shard instead of a single one when Enabled.
+// Count is the total number of shards, the one recommended by Discord when 0. IDs are the shards run by this process,
+// all of them when empty, several processes share the guilds by running distinct IDs of the same Count.
+// AutoScaling splits a shard Discord reports too large.
+type ShardingConfig struct {
+	Enabled     bool  `yaml:"enabled"`
+	Count       int   `yaml:"count"`
+	IDs         []int `yaml:"ids,flow"`
+	AutoScaling bool  `yaml:"auto_scaling"`
+}
+
+// DatabaseConfig uses Path with the sqlite driver and URL with the postgres driver.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"`
+	Path   string `yaml:"path"`
+	URL    string `yaml:"url"`
+}
+
+func (c DatabaseConfig) Dialect() (database.Dialect, error) {
+	return database.ByName(c.Driver)
+}
+
+// DSN is what the database/sql driver opens.
+func (c DatabaseConfig) DSN() string {
+	if c.Driver == database.PostgresName {
+		return c.URL
+	}
+
+	return c.Path
+}
+
+// MigrationURL is the dbmate URL of the database.
+func (c DatabaseConfig) MigrationURL() (*url.URL, error) {
+	if c.Driver == database.PostgresName {
+		databaseURL, err := url.Parse(c.URL)
+		if err != nil {
+			return nil, oops.Wrapf(err, "invalid database url")
+		}
+
+		return databaseURL, nil
+	}
+
+	return &url.URL{Scheme: "sqlite", Opaque: c.Path}, nil
+}
+
+// LoadConfig reads the configuration with ReadConfig and validates it.
+func LoadConfig(path string, getenv func(string) string) (Config, error) {
+	config, err := ReadConfig(path, getenv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, config.Validate()
+}
+
+// ReadConfig reads the optional YAML file at path, then lets the environment override it.
+// Tools that never connect to Discord use it directly to skip the validation.
+func ReadConfig(path string, getenv func(string) string) (Config, error) {
+	config := Config{
+		Database: DatabaseConfig{Driver: database.SQLiteName, Path: DatabasePath},
+		HTTP:     HTTPConfig{MaxDisconnect: DefaultMaxDisconnect},
+		Tracing:  TracingConfig{SampleRatio: 1},
+		Sections: map[string]yaml.Node{},
+	}
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, oops.Wrapf(err, "failed to read configuration file")
+		}
+
+		err = decodeStrict(content, &config)
+		if err != nil {
+			return Config{}, oops.With("file", path).Wrapf(err, "failed to parse configuration file")
+		}
+	}
+
+	EnvString(getenv, "DISCORD_TOKEN", &config.Discord.Token)
+	EnvString(getenv, "DISCORD_API_URL", &config.Discord.APIURL)
+	EnvString(getenv, "EGGMECH_DATABASE_DRIVER", &config.Database.Driver)
+	EnvString(getenv, "EGGMECH_DATABASE_PATH", &config.Database.Path)
+	EnvString(getenv, "EGGMECH_DATABASE_URL", &config.Database.URL)
+	EnvString(getenv, "EGGMECH_HTTP_ADDR", &config.HTTP.Addr)
+	EnvString(getenv, "EGGMECH_TRACING_ENDPOINT", &config.Tracing.Endpoint)
+	EnvString(getenv, "EGGMECH_TRACING_USER_HASH_KEY", &config.Tracing.UserHashKey)
+
+	err := errors.Join(
+		EnvDuration(getenv, "EGGMECH_HTTP_MAX_DISCONNECT", &config.HTTP.MaxDisconnect),
+		EnvFloat(getenv, "EGGMECH_TRACING_SAMPLE_RATIO", &config.Tracing.SampleRatio),
+		EnvBool(getenv, "EGGMECH_SHARDING", &config.Sharding.Enabled),
+		EnvInt(getenv, "EGGMECH_SHARD_COUNT", &config.Sharding.Count),
+		EnvInts(getenv, "EGGMECH_SHARD_IDS", &config.Sharding.IDs),
+		EnvBool(getenv, "EGGMECH_SHARD_AUTO_SCALING", &config.Sharding.AutoScaling),
+	)
+	if err != nil {
+		return Config{}, oops.Wrapf(err, "invalid configuration")
+	}
+
+	if modules := getenv("EGGMECH_MODULES"); modules != "" {
+		config.Modules = strings.Split(modules, ",")
+	}
+
+	for i, module := range config.Modules {
+		config.Modules[i] = strings.TrimSpace(module)
+	}
+
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Discord.Token == "" {
+		errs = append(errs, errors.New("discord.token (DISCORD_TOKEN) is required"))
+	}
+
+	if c.Discord.APIURL != "" {
+		if apiURL, err := url.Parse(c.Discord.APIURL); err != nil || apiURL.Scheme == "" || apiURL.Host == "" {
+			errs = append(errs, errors.New("discord.api_url (DISCORD_API_URL) must be an absolute URL"))
+		}
+	}
+
+	if c.HTTP.MaxDisconnect <= 0 {
+		errs = append(errs, errors.New("http.max_disconnect (EGGMECH_HTTP_MAX_DISCONNECT) must be positive"))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio (EGGMECH_TRACING_SAMPLE_RATIO) must be in [0, 1]"))
+	}
+
+	if c.Tracing.Endpoint != "" {
+		if endpoint, err := url.Parse(c.Tracing.Endpoint); err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+			errs = append(errs, errors.New("tracing.endpoint (EGGMECH_TRACING_ENDPOINT) must be an absolute URL"))
+		}
+	}
+
+	errs = append(errs, c.Sharding.validate()...)
+
+	if _, err := c.Database.Dialect(); err != nil {
+		errs = append(errs, errors.New("database.driver (EGGMECH_DATABASE_DRIVER) must be sqlite or postgres"))
+	}
+
+	if c.Database.Driver == database.SQLiteName && c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path (EGGMECH_DATABASE_PATH) is required with sqlite"))
+	}
+
+	if c.Database.Driver == database.PostgresName && c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url (EGGMECH_DATABASE_URL) is required with postgres"))
+	}
+
+	if len(errs) > 0 {
+		return oops.Wrapf(errors.Join(errs...), "invalid configuration")
+	}
+
+	return nil
+}
+
+func (c ShardingConfig) validate() []error {
+	var errs []error
+
+	if c.Count < 0 {
+		errs = append(errs, errors.New("sharding.count (EGGMECH_SHARD_COUNT) must not be negative"))
+	}
+
+	if len(c.IDs) > 0 && c.Count == 0 {
+		errs = append(errs, errors.New("sharding.ids (EGGMECH_SHARD_IDS) needs sharding.count (EGGMECH_SHARD_COUNT)"))
+	}
+
+	if len(c.IDs) > 0 && c.AutoScaling {
+		errs = append(errs, errors.New("sharding.auto_scaling (EGGMECH_SHARD_AUTO_SCALING) cannot be used with sharding.ids"))
+	}
+
+	for _, id := range c.IDs {
+		if id < 0 || (c.Count > 0 && id >= c.Count) {
+			errs = append(errs, oops.Errorf("sharding.ids (EGGMECH_SHARD_IDS) must be in [0, %d), got %d", c.Count, id))
+		}
+	}
+
+	return errs
+}
+
+// DecodeModule decodes the section of a module into config, which should hold the module defaults.
+func (c Config) DecodeModule(name string, config any) error {
+	section, ok := c.Sections[name]
+	if !ok {
+		return nil
+	}
+
+	// yaml.Node.Decode cannot reject unknown fields, the section goes through a strict decoder instead
+	content, err := yaml.Marshal(&section)
+	if err != nil {
+		return oops.With("module", name).Wrapf(err, "failed to encode configuration section")
+	}
+
+	err = decodeStrict(content, config)
+	if err != nil {
+		return oops.With("module", name).Wrapf(err, "failed to decode configuration section")
+	}
+
+	return nil
+}
+
+// ValidateSections rejects the top-level keys that are neither host settings nor the section of an enabled module,
+// typos included.
+func (c Config) ValidateSections(modules []Module) error {
+	var errs []error
+
+	for name := range c.Sections {
+		if !slices.ContainsFunc(modules, func(module Module) bool { return module.Name() == name }) {
+			errs = append(errs, oops.Errorf("%s is neither a host setting nor the section of an enabled module", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a error, b error) int { return strings.Compare(a.Error(), b.Error()) })
+
+		return oops.Wrapf(errors.Join(errs...), "invalid configuration")
+	}
+
+	return nil
+}
+
+// decodeStrict decodes content into out, failing on the fields out does not have. An empty content is no error.
+func decodeStrict(content []byte, out any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(out)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	return oops.Wrap(err)
+}
+
+func (c Config) Redacted() Config {
+	redactedConfig := c
+	redactedConfig.Sections = nil
+
+	redactedConfig.Database.URL = RedactURL(c.Database.URL)
+	redactedConfig.Discord.APIURL = RedactURL(c.Discord.APIURL)
+	redactedConfig.Tracing.Endpoint = RedactURL(c.Tracing.Endpoint)
+
+	if redactedConfig.Discord.Token != "" {
+		redactedConfig.Discord.Token = redacted
+	}
+
+	if redactedConfig.Tracing.UserHashKey != "" {
+		redactedConfig.Tracing.UserHashKey = redacted
+	}
+
+	return redactedConfig
+}
+
+// PrintConfig writes the redacted host configuration followed by the section of each module.
+func PrintConfig(w io.Writer, config Config, modules []Module) error {
+	sections := map[string]any{}
+
+	for _, module := range modules {
+		sections[module.Name()] = module.RedactedConfig()
+	}
+
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+
+	err := encoder.Encode(struct {
+		Config   `yaml:",inline"`
+		Sections map[string]any `yaml:",inline"`
+	}{config.Redacted(), sections})
+	if err != nil {
+		return oops.Wrapf(err, "failed to print configuration")
+	}
+
+	return nil
+}
+
+// RedactURL hides the password of a URL, for the connection strings of printed configurations.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+	}
+
+	return parsed.String()
+}
+
+func EnvString(getenv func(string) string, name string, target *string) {
+	if value := getenv(name); value != "" {
+		*target = value
+	}
+}
+
+func EnvBool(getenv func(string) string, name string, target *bool) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return oops.Errorf("%s must be a boolean, got %q", name, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+func EnvDuration(getenv func(string) string, name string, target *time.Duration) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return oops.Errorf("%s must be a duration such as 2m, got %q", name, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+func EnvFloat(getenv func(string) string, name string, target *float64) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return oops.Errorf("%s must be a number, got %q", name, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+func EnvInts(getenv func(string) string, name string, target *[]int) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	var parsed []int
+
+	for _, field := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return oops.Errorf("%s must be a comma separated list of integers, got %q", name, value)
+		}
+
+		parsed = append(parsed, number)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+func EnvInt(getenv func(string) string, name string, target *int) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return oops.Errorf("%s must be an integer, got %q", name, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
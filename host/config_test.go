@@ -0,0 +1,119 @@
+package host_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/disgo/gateway"
+
+	"eggmech/host"
+	"eggmech/host/database"
+)
+
+type module struct {
+	name string
+}
+
+func (m module) Name() string {
+	return m.name
+}
+
+func (module) Intents() gateway.Intents {
+	return gateway.IntentsNone
+}
+
+func (module) Migrations(database.Dialect) host.Migrations {
+	return host.Migrations{}
+}
+
+func (module) Setup(context.Context, host.Host) (host.Registration, error) {
+	return host.Registration{}, nil
+}
+
+func (module) RedactedConfig() any {
+	return nil
+}
+
+type moduleConfig struct {
+	Mode string `yaml:"mode"`
+}
+
+func TestConfigStrictDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// expected is part of the error, none when empty
+		expected string
+	}{
+		{
+			name:    "known keys",
+			content: "discord:\n  token: token\ngreeter:\n  mode: worker\n",
+		},
+		{
+			name:    "empty file",
+			content: "",
+		},
+		{
+			name:     "unknown host field",
+			content:  "discord:\n  tokne: token\n",
+			expected: "field tokne not found",
+		},
+		{
+			name:     "unknown top-level key",
+			content:  "discrod:\n  token: token\n",
+			expected: "discrod is neither a host setting nor the section of an enabled module",
+		},
+		{
+			name:     "section of a disabled module",
+			content:  "farewell:\n  mode: worker\n",
+			expected: "farewell is neither a host setting nor the section of an enabled module",
+		},
+		{
+			name:     "unknown module field",
+			content:  "greeter:\n  mdoe: worker\n",
+			expected: "field mdoe not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loadConfig(t, tt.content)
+
+			switch {
+			case tt.expected == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.expected != "" && (err == nil || !strings.Contains(err.Error(), tt.expected)):
+				t.Errorf("expected an error with %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// loadConfig reads content and decodes the section of the only enabled module, greeter, as the binaries do.
+func loadConfig(t *testing.T, content string) error {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "eggmech.yaml")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := host.ReadConfig(path, func(string) string { return "" })
+	if err != nil {
+		return err
+	}
+
+	err = config.ValidateSections([]host.Module{module{name: "greeter"}})
+	if err != nil {
+		return err
+	}
+
+	var decoded moduleConfig
+
+	return config.DecodeModule("greeter", &decoded)
+}
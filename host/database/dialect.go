@@ -0,0 +1,147 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"           // Postgres driver
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"github.com/samber/oops"
+)
+
+const (
+	SQLiteName   = "sqlite"
+	PostgresName = "postgres"
+)
+
+// Dialect holds what differs between the SQL backends, queries are written with ? placeholders
+// and the SQLite functions, the few SQLite-only expressions go through the methods below.
+type Dialect struct {
+	name string
+}
+
+func SQLite() Dialect {
+	return Dialect{name: SQLiteName}
+}
+
+func Postgres() Dialect {
+	return Dialect{name: PostgresName}
+}
+
+func ByName(name string) (Dialect, error) {
+	switch name {
+	case SQLiteName:
+		return SQLite(), nil
+	case PostgresName:
+		return Postgres(), nil
+	}
+
+	return Dialect{}, oops.Errorf("unknown database driver %q, expected %s or %s", name, SQLiteName, PostgresName)
+}
+
+// Name is also the sub directory of the dialect migrations.
+func (d Dialect) Name() string {
+	return d.name
+}
+
+func (d Dialect) IsPostgres() bool {
+	return d.name == PostgresName
+}
+
+// DriverName is the database/sql driver of the dialect.
+func (d Dialect) DriverName() string {
+	if d.IsPostgres() {
+		return "postgres"
+	}
+
+	return "sqlite3"
+}
+
+// Open opens dsn, a file path for SQLite and a connection URL for Postgres.
+func (d Dialect) Open(dsn string) (*sql.DB, error) {
+	if !d.IsPostgres() {
+		dsn += "?_foreign_keys=true"
+	}
+
+	db, err := sql.Open(d.DriverName(), dsn)
+	if err != nil {
+		return nil, oops.With("driver", d.name).Wrapf(err, "failed to open database")
+	}
+
+	return db, nil
+}
+
+// Rebind turns the ? placeholders into $1, $2... for Postgres, queries must not hold a literal ?.
+func (d Dialect) Rebind(query string) string {
+	if !d.IsPostgres() {
+		return query
+	}
+
+	var builder strings.Builder
+
+	position := 0
+
+	for _, char := range query {
+		if char != '?' {
+			builder.WriteRune(char)
+
+			continue
+		}
+
+		position++
+
+		builder.WriteString("$" + strconv.Itoa(position))
+	}
+
+	return builder.String()
+}
+
+// Greatest is the scalar maximum of two values, SQLite overloads MAX for it.
+func (d Dialect) Greatest() string {
+	if d.IsPostgres() {
+		return "GREATEST"
+	}
+
+	return "MAX"
+}
+
+// UnixNow is the current time in seconds since the epoch.
+func (d Dialect) UnixNow() string {
+	if d.IsPostgres() {
+		return "CAST(EXTRACT(EPOCH FROM now()) AS bigint)"
+	}
+
+	return "CAST(strftime('%s', 'now') AS integer)"
+}
+
+// DaysAgo is the UTC date, as YYYY-MM-DD, of days days ago.
+func (d Dialect) DaysAgo(days string) string {
+	if d.IsPostgres() {
+		return "to_char((now() AT TIME ZONE 'UTC')::date - (" + days + "), 'YYYY-MM-DD')"
+	}
+
+	return "DATE('now', '-' || (" + days + ") || ' day')"
+}
+
+// DayOfMillis is the UTC date, as YYYY-MM-DD, of a unix milliseconds column.
+func (d Dialect) DayOfMillis(millis string) string {
+	if d.IsPostgres() {
+		return "to_char(to_timestamp(" + millis + " / 1000) AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	}
+
+	return "DATE(" + millis + " / 1000, 'unixepoch')"
+}
+
+// SchemaObjects lists the type and name of the tables and indexes, except the ones of the excluded table.
+func (d Dialect) SchemaObjects() string {
+	if d.IsPostgres() {
+		return `SELECT 'table', table_name FROM information_schema.tables
+				WHERE table_schema = current_schema() AND table_name != $1
+			UNION ALL
+			SELECT 'index', indexname FROM pg_indexes
+				WHERE schemaname = current_schema() AND tablename != $1`
+	}
+
+	return `SELECT type, name FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' AND name != ?`
+}
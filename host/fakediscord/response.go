@@ -0,0 +1,56 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError answers with the JSON error body of Discord, errors holds the per field details.
+func writeError(w http.ResponseWriter, status int, code int, message string, errors map[string]any) {
+	body := map[string]any{"code": code, "message": message}
+	if errors != nil {
+		body["errors"] = errors
+	}
+
+	writeJSON(w, status, body)
+}
+
+func writeFormError(w http.ResponseWriter, field string, code string, message string) {
+	writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body", map[string]any{
+		field: map[string]any{"_errors": []map[string]string{{"code": code, "message": message}}},
+	})
+}
+
+// writeRateLimit answers like a route rate limit, disgo reads Retry-After as whole seconds
+// and treats a 429 without Via as a Cloudflare ban.
+func writeRateLimit(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	w.Header().Set("Via", "1.1 fakediscord")
+	w.Header().Set("X-RateLimit-Bucket", "fakediscord")
+	w.Header().Set("X-RateLimit-Limit", "1")
+	w.Header().Set("X-RateLimit-Remaining", "0")
+	w.Header().Set("X-RateLimit-Reset-After", "1")
+	w.Header().Set("X-RateLimit-Scope", "user")
+
+	writeJSON(w, http.StatusTooManyRequests, map[string]any{
+		"message":     "You are being rate limited.",
+		"retry_after": 1,
+		"global":      false,
+	})
+}
@@ -0,0 +1,658 @@
+// Package fakediscord is an in-process Discord REST API limited to the channel endpoints the modules use.
+// It keeps Discord's parent and position rules, the channel limits, and answers 429 on demand.
+package fakediscord
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+const (
+	// APIPath is appended to the server URL to get the REST URL, as in rest.WithURL(url + APIPath).
+	APIPath = "/api/v10"
+
+	MaxCategoryChannels = 50
+	MaxGuildChannels    = 500
+
+	// gatewayURL is answered to the gateway lookups, the fake has no gateway.
+	gatewayURL = "wss://gateway.fakediscord.invalid"
+)
+
+type Channel struct {
+	ID                   snowflake.ID        `json:"id"`
+	Type                 discord.ChannelType `json:"type"`
+	GuildID              snowflake.ID        `json:"guild_id"`
+	Name                 string              `json:"name"`
+	Position             int                 `json:"position"`
+	ParentID             *snowflake.ID       `json:"parent_id"`
+	PermissionOverwrites []any               `json:"permission_overwrites"`
+}
+
+func (c Channel) isCategory() bool {
+	return c.Type == discord.ChannelTypeGuildCategory
+}
+
+func (c Channel) parent() snowflake.ID {
+	if c.ParentID == nil {
+		return 0
+	}
+
+	return *c.ParentID
+}
+
+type Message struct {
+	ID        snowflake.ID `json:"id"`
+	ChannelID snowflake.ID `json:"channel_id"`
+	Content   string       `json:"content"`
+}
+
+// Request is a request the server answered, Status included.
+type Request struct {
+	Method string
+	Path   string
+	Body   string
+	Status int
+}
+
+type Server struct {
+	mu             sync.Mutex
+	token          string
+	guilds         map[snowflake.ID][]Channel
+	requests       []Request
+	messages       []Message
+	nextID         snowflake.ID
+	rateLimitEvery int
+	mutations      int
+	denyChannels   bool
+	shards         int
+	flags          discord.ApplicationFlags
+	mux            *http.ServeMux
+}
+
+type Option func(server *Server)
+
+// WithToken makes the server reject requests without "Bot <token>".
+func WithToken(token string) Option {
+	return func(server *Server) {
+		server.token = token
+	}
+}
+
+// WithRateLimitEvery answers 429 to every nth mutation, the client retry then goes through.
+func WithRateLimitEvery(n int) Option {
+	return func(server *Server) {
+		server.rateLimitEvery = n
+	}
+}
+
+// WithoutManageChannels answers 403 Missing Permissions to every channel creation, move and update,
+// as Discord does when the bot lacks Manage Channels.
+func WithoutManageChannels() Option {
+	return func(server *Server) {
+		server.denyChannels = true
+	}
+}
+
+// WithRecommendedShards is the shard count answered by GET /gateway/bot, 1 by default.
+func WithRecommendedShards(shards int) Option {
+	return func(server *Server) {
+		server.shards = shards
+	}
+}
+
+// WithApplicationFlags are the flags of the application answered by GET /applications/@me,
+// such as the privileged intents it may use.
+func WithApplicationFlags(flags discord.ApplicationFlags) Option {
+	return func(server *Server) {
+		server.flags = flags
+	}
+}
+
+func New(opts ...Option) *Server {
+	server := &Server{
+		guilds: map[snowflake.ID][]Channel{},
+		nextID: 1 << 40,
+		shards: 1,
+		mux:    http.NewServeMux(),
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	server.mux.HandleFunc("GET "+APIPath+"/guilds/{guildID}/channels", server.getChannels)
+	server.mux.HandleFunc("POST "+APIPath+"/guilds/{guildID}/channels", server.createChannel)
+	server.mux.HandleFunc("PATCH "+APIPath+"/guilds/{guildID}/channels", server.updatePositions)
+	server.mux.HandleFunc("PATCH "+APIPath+"/channels/{channelID}", server.updateChannel)
+	server.mux.HandleFunc("POST "+APIPath+"/channels/{channelID}/messages", server.createMessage)
+	server.mux.HandleFunc("PUT "+APIPath+"/applications/{applicationID}/commands", server.setCommands)
+	server.mux.HandleFunc("GET "+APIPath+"/applications/@me", server.getApplication)
+	server.mux.HandleFunc("GET "+APIPath+"/gateway", server.getGateway)
+	server.mux.HandleFunc("GET "+APIPath+"/gateway/bot", server.getGatewayBot)
+
+	return server
+}
+
+// AddGuild creates or replaces a guild with its channels, positions are taken as given.
+func (s *Server) AddGuild(guildID snowflake.ID, channels ...Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range channels {
+		channels[i].GuildID = guildID
+		s.nextID = max(s.nextID, channels[i].ID+1)
+	}
+
+	s.guilds[guildID] = channels
+}
+
+// PutChannel creates or replaces a channel of a guild as an admin would, its position is taken as given.
+func (s *Server) PutChannel(guildID snowflake.ID, channel Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel.GuildID = guildID
+	s.nextID = max(s.nextID, channel.ID+1)
+
+	index := slices.IndexFunc(s.guilds[guildID], func(existing Channel) bool { return existing.ID == channel.ID })
+	if index < 0 {
+		s.guilds[guildID] = append(s.guilds[guildID], channel)
+
+		return
+	}
+
+	s.guilds[guildID][index] = channel
+}
+
+// RemoveChannel deletes a channel of a guild as an admin would.
+func (s *Server) RemoveChannel(guildID snowflake.ID, channelID snowflake.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.guilds[guildID] = slices.DeleteFunc(s.guilds[guildID], func(channel Channel) bool { return channel.ID == channelID })
+}
+
+// Channels returns the channels of a guild, by parent then position.
+func (s *Server) Channels(guildID snowflake.ID) []Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels := slices.Clone(s.guilds[guildID])
+	slices.SortFunc(channels, func(a, b Channel) int {
+		return cmp.Or(cmp.Compare(a.parent(), b.parent()), cmp.Compare(a.Position, b.Position))
+	})
+
+	return channels
+}
+
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// Messages returns the messages posted to a channel, in order.
+func (s *Server) Messages(channelID snowflake.ID) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var messages []Message
+
+	for _, message := range s.messages {
+		if message.ChannelID == channelID {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && r.Header.Get("Authorization") != "Bot "+s.token {
+		writeError(w, http.StatusUnauthorized, 0, "401: Unauthorized", nil)
+
+		return
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	body := readBody(r)
+
+	switch {
+	case r.Method != http.MethodGet && s.rateLimited():
+		writeRateLimit(recorder)
+	case s.denyChannels && managesChannels(r):
+		writeError(recorder, http.StatusForbidden, 50013, "Missing Permissions", nil)
+	default:
+		s.mux.ServeHTTP(recorder, r)
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Body: body, Status: recorder.status})
+	s.mu.Unlock()
+}
+
+// managesChannels tells if r needs Manage Channels: a creation or move of channels, or the update of one.
+func managesChannels(r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return false
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/channels") {
+		return true
+	}
+
+	channelPath, found := strings.CutPrefix(r.URL.Path, APIPath+"/channels/")
+
+	return found && r.Method == http.MethodPatch && !strings.Contains(channelPath, "/")
+}
+
+func (s *Server) rateLimited() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mutations++
+
+	return s.rateLimitEvery > 0 && s.mutations%s.rateLimitEvery == 0
+}
+
+func (s *Server) guild(w http.ResponseWriter, r *http.Request) (snowflake.ID, bool) {
+	guildID, err := snowflake.Parse(r.PathValue("guildID"))
+	if _, exists := s.guilds[guildID]; err != nil || !exists {
+		writeError(w, http.StatusNotFound, 10004, "Unknown Guild", nil)
+
+		return 0, false
+	}
+
+	return guildID, true
+}
+
+func (s *Server) getChannels(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guildID, ok := s.guild(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.guilds[guildID])
+}
+
+type channelCreate struct {
+	Type     discord.ChannelType `json:"type"`
+	Name     string              `json:"name"`
+	Position *int                `json:"position"`
+	ParentID *snowflake.ID       `json:"parent_id"`
+}
+
+func (s *Server) createChannel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guildID, ok := s.guild(w, r)
+	if !ok {
+		return
+	}
+
+	var create channelCreate
+
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.", nil)
+
+		return
+	}
+
+	if create.Name == "" || len(create.Name) > 100 {
+		writeFormError(w, "name", "BASE_TYPE_BAD_LENGTH", "Must be between 1 and 100 in length.")
+
+		return
+	}
+
+	if create.ParentID != nil && *create.ParentID == 0 {
+		create.ParentID = nil
+	}
+
+	channels := s.guilds[guildID]
+	if len(channels) >= MaxGuildChannels {
+		writeError(w, http.StatusBadRequest, 30013, "Maximum number of guild channels reached (500)", nil)
+
+		return
+	}
+
+	channel := Channel{
+		ID:                   s.nextID,
+		Type:                 create.Type,
+		GuildID:              guildID,
+		Name:                 create.Name,
+		Position:             len(channels),
+		ParentID:             create.ParentID,
+		PermissionOverwrites: []any{},
+	}
+
+	if create.Position != nil {
+		// placed before the sibling at that position, normalize keeps the id order on ties
+		channel.Position = *create.Position
+		channels = shift(channels, channel, *create.Position)
+	}
+
+	channels = append(channels, channel)
+
+	if field, code, message := validate(channels); field != "" {
+		writeFormError(w, field, code, message)
+
+		return
+	}
+
+	s.nextID++
+	s.guilds[guildID] = normalize(channels)
+
+	created, _ := find(s.guilds[guildID], channel.ID)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+type positionUpdate struct {
+	ID       snowflake.ID  `json:"id"`
+	Position *int          `json:"position"`
+	ParentID *snowflake.ID `json:"parent_id"`
+}
+
+func (s *Server) updatePositions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guildID, ok := s.guild(w, r)
+	if !ok {
+		return
+	}
+
+	var updates []positionUpdate
+
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.", nil)
+
+		return
+	}
+
+	// applied on a copy, a rejected request changes nothing
+	channels := slices.Clone(s.guilds[guildID])
+
+	for _, update := range updates {
+		index := slices.IndexFunc(channels, func(channel Channel) bool { return channel.ID == update.ID })
+		if index < 0 {
+			writeFormError(w, "id", "CHANNEL_NOT_FOUND", "Unknown channel "+update.ID.String())
+
+			return
+		}
+
+		if update.ParentID != nil {
+			channels[index].ParentID = update.ParentID
+			if *update.ParentID == 0 {
+				channels[index].ParentID = nil
+			}
+		}
+
+		if update.Position != nil {
+			channels[index].Position = *update.Position
+		}
+	}
+
+	if field, code, message := validate(channels); field != "" {
+		writeFormError(w, field, code, message)
+
+		return
+	}
+
+	s.guilds[guildID] = normalize(channels)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type channelUpdate struct {
+	Name     *string       `json:"name"`
+	Position *int          `json:"position"`
+	ParentID *snowflake.ID `json:"parent_id"`
+}
+
+// updateChannel renames or moves a channel, a parent_id of 0 moves it to the top level.
+func (s *Server) updateChannel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channelID, err := snowflake.Parse(r.PathValue("channelID"))
+	guildID, found := s.channelGuild(channelID)
+
+	if err != nil || !found {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel", nil)
+
+		return
+	}
+
+	var update channelUpdate
+
+	if err = json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.", nil)
+
+		return
+	}
+
+	if update.Name != nil && (*update.Name == "" || len(*update.Name) > 100) {
+		writeFormError(w, "name", "BASE_TYPE_BAD_LENGTH", "Must be between 1 and 100 in length.")
+
+		return
+	}
+
+	// applied on a copy, a rejected request changes nothing
+	channels := slices.Clone(s.guilds[guildID])
+	index := slices.IndexFunc(channels, func(channel Channel) bool { return channel.ID == channelID })
+
+	if update.Name != nil {
+		channels[index].Name = *update.Name
+	}
+
+	if update.ParentID != nil {
+		channels[index].ParentID = update.ParentID
+		if *update.ParentID == 0 {
+			channels[index].ParentID = nil
+		}
+	}
+
+	if update.Position != nil {
+		channels[index].Position = *update.Position
+	}
+
+	if field, code, message := validate(channels); field != "" {
+		writeFormError(w, field, code, message)
+
+		return
+	}
+
+	s.guilds[guildID] = normalize(channels)
+
+	updated, _ := find(s.guilds[guildID], channelID)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channelID, err := snowflake.Parse(r.PathValue("channelID"))
+	if _, found := s.channelGuild(channelID); err != nil || !found {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel", nil)
+
+		return
+	}
+
+	var message Message
+
+	if err = json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.", nil)
+
+		return
+	}
+
+	if message.Content == "" || len(message.Content) > 2000 {
+		writeFormError(w, "content", "BASE_TYPE_BAD_LENGTH", "Must be between 1 and 2000 in length.")
+
+		return
+	}
+
+	message.ID = s.nextID
+	message.ChannelID = channelID
+	s.nextID++
+	s.messages = append(s.messages, message)
+
+	writeJSON(w, http.StatusOK, message)
+}
+
+// channelGuild finds the guild of a channel.
+func (s *Server) channelGuild(channelID snowflake.ID) (snowflake.ID, bool) {
+	for guildID, channels := range s.guilds {
+		if _, found := find(channels, channelID); found {
+			return guildID, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *Server) setCommands(w http.ResponseWriter, r *http.Request) {
+	var commands []map[string]any
+
+	if err := json.NewDecoder(r.Body).Decode(&commands); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.", nil)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, command := range commands {
+		command["id"] = s.nextID.String()
+		command["application_id"] = r.PathValue("applicationID")
+		command["version"] = s.nextID.String()
+		s.nextID++
+	}
+
+	writeJSON(w, http.StatusOK, commands)
+}
+
+func (s *Server) getApplication(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"id": "1", "name": "fakediscord", "flags": s.flags})
+}
+
+// getGateway lets a client be created.
+func (s *Server) getGateway(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"url": gatewayURL})
+}
+
+// getGatewayBot serves the sharding configuration.
+func (s *Server) getGatewayBot(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"url":    gatewayURL,
+		"shards": s.shards,
+		"session_start_limit": map[string]int{
+			"total": 1000, "remaining": 1000, "reset_after": 0, "max_concurrency": 1,
+		},
+	})
+}
+
+// shift makes room at position among the siblings of channel.
+func shift(channels []Channel, channel Channel, position int) []Channel {
+	for i := range channels {
+		if siblings(channels[i], channel) && channels[i].Position >= position {
+			channels[i].Position++
+		}
+	}
+
+	return channels
+}
+
+func siblings(a Channel, b Channel) bool {
+	return a.parent() == b.parent() && a.isCategory() == b.isCategory()
+}
+
+// validate applies the parent rules of Discord: parents are categories of the guild, categories have
+// no parent and hold at most MaxCategoryChannels channels.
+func validate(channels []Channel) (string, string, string) {
+	children := map[snowflake.ID]int{}
+
+	for _, channel := range channels {
+		if channel.ParentID == nil {
+			continue
+		}
+
+		if channel.isCategory() {
+			return "parent_id", "CHANNEL_PARENT_INVALID_TYPE", "Categories cannot have subcategories"
+		}
+
+		parent, found := find(channels, *channel.ParentID)
+		if !found || !parent.isCategory() {
+			return "parent_id", "CHANNEL_PARENT_INVALID_TYPE", "Not a category"
+		}
+
+		children[parent.ID]++
+
+		if children[parent.ID] > MaxCategoryChannels {
+			return "parent_id", "CHANNEL_PARENT_MAX_CHANNELS", "Maximum number of channels in category reached (" +
+				strconv.Itoa(MaxCategoryChannels) + ")"
+		}
+	}
+
+	return "", "", ""
+}
+
+// normalize renumbers the positions of every group of siblings from 0, ordered by position then id.
+func normalize(channels []Channel) []Channel {
+	slices.SortStableFunc(channels, func(a, b Channel) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.ID, b.ID))
+	})
+
+	type group struct {
+		parent   snowflake.ID
+		category bool
+	}
+
+	next := map[group]int{}
+
+	for i := range channels {
+		key := group{parent: channels[i].parent(), category: channels[i].isCategory()}
+		channels[i].Position = next[key]
+		next[key]++
+	}
+
+	return channels
+}
+
+func find(channels []Channel, id snowflake.ID) (Channel, bool) {
+	for _, channel := range channels {
+		if channel.ID == id {
+			return channel, true
+		}
+	}
+
+	return Channel{}, false
+}
+
+func readBody(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return string(body)
+}
@@ -0,0 +1,100 @@
+package host
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/disgo/handlers"
+	"github.com/samber/oops"
+)
+
+// GuildMembersChunk is dispatched for every member chunk received, with the presences of the members when they were
+// requested. disgo itself only hands the members to its chunking manager.
+type GuildMembersChunk struct {
+	*events.GenericEvent
+	gateway.EventGuildMembersChunk
+}
+
+// gatewayHandlers are the disgo handlers, the member chunks are also dispatched as GuildMembersChunk.
+func gatewayHandlers() map[gateway.EventType]bot.GatewayEventHandler {
+	gatewayHandlers := handlers.GetGatewayHandlers()
+	chunkHandler := gatewayHandlers[gateway.EventTypeGuildMembersChunk]
+
+	gatewayHandlers[gateway.EventTypeGuildMembersChunk] = bot.NewGatewayEventHandler(
+		gateway.EventTypeGuildMembersChunk,
+		func(client bot.Client, sequenceNumber int, shardID int, event gateway.EventGuildMembersChunk) {
+			chunkHandler.HandleGatewayEvent(client, sequenceNumber, shardID, event)
+			client.EventManager().DispatchEvent(&GuildMembersChunk{
+				GenericEvent:           events.NewGenericEvent(client, sequenceNumber, shardID),
+				EventGuildMembersChunk: event,
+			})
+		},
+	)
+
+	return gatewayHandlers
+}
+
+// privilegedIntents are the intents Discord refuses unless they are enabled on the Bot page of the Developer Portal,
+// with the application flags telling they are.
+var privilegedIntents = []struct {
+	intent  gateway.Intents
+	name    string
+	enabled []discord.ApplicationFlags
+}{
+	{
+		gateway.IntentGuildPresences,
+		"Presence Intent",
+		[]discord.ApplicationFlags{discord.ApplicationFlagGatewayPresence, discord.ApplicationFlagGatewayPresenceLimited},
+	},
+	{
+		gateway.IntentGuildMembers,
+		"Server Members Intent",
+		[]discord.ApplicationFlags{
+			discord.ApplicationFlagGatewayGuildMembers,
+			discord.ApplicationFlagGatewayGuildMemberLimited,
+		},
+	},
+	{
+		gateway.IntentMessageContent,
+		"Message Content Intent",
+		[]discord.ApplicationFlags{
+			discord.ApplicationFlagGatewayMessageContent,
+			discord.ApplicationFlagGatewayMessageContentLimited,
+		},
+	},
+}
+
+// checkPrivilegedIntents logs every privileged intent of intents the application is not allowed to use,
+// Discord would close the gateway without telling the modules which events they miss.
+func checkPrivilegedIntents(ctx context.Context, client bot.Client, intents gateway.Intents, logger *slog.Logger) {
+	application, err := client.Rest().GetCurrentApplication()
+	if err != nil {
+		logger.WarnContext(ctx, "cannot check the privileged intents", slog.Any("error", oops.Wrap(err)))
+
+		return
+	}
+
+	for _, privileged := range privilegedIntents {
+		if !intents.Has(privileged.intent) {
+			continue
+		}
+
+		enabled := false
+
+		for _, flag := range privileged.enabled {
+			enabled = enabled || application.Flags.Has(flag)
+		}
+
+		if !enabled {
+			logger.ErrorContext(
+				ctx,
+				"privileged intent needed by the modules is not enabled, enable it on the Bot page of the Developer Portal",
+				slog.String("intent", privileged.name),
+			)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package host
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+)
+
+const (
+	DefaultMaxDisconnect = 2 * time.Minute
+	healthWatchInterval  = 5 * time.Second
+	healthPingTimeout    = 2 * time.Second
+)
+
+// health is what /healthz and /readyz report, Run fills it in as the host starts.
+type health struct {
+	mu            sync.Mutex
+	maxDisconnect time.Duration
+	migrated      bool
+	db            *sql.DB
+	// withoutGateway is set for the processes that open no gateway, such as the workers
+	withoutGateway bool
+	gateways       func() []gateway.Gateway
+	ownedShards    []int
+	readyShards    map[int]bool
+	disconnected   map[int]time.Time
+}
+
+func newHealth(maxDisconnect time.Duration) *health {
+	return &health{
+		maxDisconnect: maxDisconnect,
+		readyShards:   map[int]bool{},
+		disconnected:  map[int]time.Time{},
+	}
+}
+
+func (h *health) setMigrated(db *sql.DB) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.migrated = true
+	h.db = db
+}
+
+// setWithoutGateway makes the process ready without shards, it opens no gateway.
+func (h *health) setWithoutGateway() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.withoutGateway = true
+}
+
+// watchGateways records since when each shard is disconnected until ctx is done, gateways lists the shards and
+// owned are the IDs of the shards of this process, which must all be ready.
+func (h *health) watchGateways(ctx context.Context, gateways func() []gateway.Gateway, owned []int) {
+	h.mu.Lock()
+	h.gateways = gateways
+	h.ownedShards = owned
+	h.mu.Unlock()
+
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		h.observe(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *health) observe(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, shard := range h.gateways() {
+		if shard.Status().IsConnected() {
+			delete(h.disconnected, shard.ShardID())
+		} else if _, known := h.disconnected[shard.ShardID()]; !known {
+			h.disconnected[shard.ShardID()] = now
+		}
+	}
+}
+
+func (h *health) onReady(event *events.Ready) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.readyShards[event.ShardID()] = true
+}
+
+// live fails when a shard is disconnected for longer than maxDisconnect or the database does not answer.
+func (h *health) live(ctx context.Context) map[string]string {
+	h.mu.Lock()
+	db := h.db
+	checks := map[string]string{"gateway": "ok"}
+
+	for shard, since := range h.disconnected {
+		if time.Since(since) > h.maxDisconnect {
+			checks["gateway"] = "shard " + strconv.Itoa(shard) + " disconnected since " + since.UTC().Format(time.RFC3339)
+		}
+	}
+	h.mu.Unlock()
+
+	checks["database"] = ping(ctx, db)
+
+	return checks
+}
+
+// ready holds once the migrations ran and every owned shard received its Ready event and is still connected, or
+// once the process is marked without gateway.
+func (h *health) ready() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	checks := map[string]string{"migrations": "ok", "gateway": "ok"}
+
+	if !h.migrated {
+		checks["migrations"] = "pending"
+	}
+
+	switch {
+	case h.withoutGateway:
+		return checks
+	case h.gateways == nil:
+		checks["gateway"] = "not opened"
+
+		return checks
+	}
+
+	statuses := map[int]gateway.Status{}
+
+	for _, shard := range h.gateways() {
+		statuses[shard.ShardID()] = shard.Status()
+	}
+
+	for _, shardID := range h.ownedShards {
+		status, opened := statuses[shardID]
+
+		switch {
+		case !opened || !h.readyShards[shardID]:
+			checks["gateway"] = "shard " + strconv.Itoa(shardID) + " not ready"
+		case status != gateway.StatusReady:
+			checks["gateway"] = "shard " + strconv.Itoa(shardID) + " not connected"
+		}
+	}
+
+	return checks
+}
+
+// ping leaves the database check ok until the migrations opened it, liveness must not fail during migrations.
+func ping(ctx context.Context, db *sql.DB) string {
+	if db == nil {
+		return "ok"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return err.Error()
+	}
+
+	return "ok"
+}
+
+func (h *health) handleLive(w http.ResponseWriter, r *http.Request) {
+	writeChecks(w, h.live(r.Context()))
+}
+
+func (h *health) handleReady(w http.ResponseWriter, _ *http.Request) {
+	writeChecks(w, h.ready())
+}
+
+// writeChecks answers 503 unless every check is ok, the body lists them.
+func writeChecks(w http.ResponseWriter, checks map[string]string) {
+	status := http.StatusOK
+
+	for _, check := range checks {
+		if check != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(checks)
+}
@@ -0,0 +1,99 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+)
+
+// stubGateway is a shard with an ID, a count and a status, its other methods are not called.
+type stubGateway struct {
+	gateway.Gateway
+	shardID    int
+	shardCount int
+	status     gateway.Status
+}
+
+func (g stubGateway) ShardID() int {
+	return g.shardID
+}
+
+func (g stubGateway) ShardCount() int {
+	return g.shardCount
+}
+
+func (g stubGateway) Status() gateway.Status {
+	return g.status
+}
+
+func TestHealthReady(t *testing.T) {
+	tests := []struct {
+		name           string
+		withoutGateway bool
+		gateways       []gateway.Gateway
+		owned          []int
+		ready          []int
+		watched        bool
+		expected       string
+	}{
+		{name: "the gateway is not opened yet", expected: "not opened"},
+		{name: "a worker has no gateway", withoutGateway: true, expected: "ok"},
+		{
+			name:     "every owned shard is ready",
+			gateways: []gateway.Gateway{stubGateway{shardID: 0, status: gateway.StatusReady}},
+			owned:    []int{0},
+			ready:    []int{0},
+			watched:  true,
+			expected: "ok",
+		},
+		{
+			name:     "a shard did not receive Ready",
+			gateways: []gateway.Gateway{stubGateway{shardID: 0, status: gateway.StatusReady}},
+			owned:    []int{0},
+			watched:  true,
+			expected: "shard 0 not ready",
+		},
+		{
+			name:     "an owned shard is not opened yet",
+			gateways: []gateway.Gateway{stubGateway{shardID: 2, status: gateway.StatusReady}},
+			owned:    []int{2, 3},
+			ready:    []int{2},
+			watched:  true,
+			expected: "shard 3 not ready",
+		},
+		{
+			name:     "a ready shard got disconnected",
+			gateways: []gateway.Gateway{stubGateway{shardID: 0, status: gateway.StatusDisconnected}},
+			owned:    []int{0},
+			ready:    []int{0},
+			watched:  true,
+			expected: "shard 0 not connected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealth(DefaultMaxDisconnect)
+			h.setMigrated(nil)
+
+			if tt.withoutGateway {
+				h.setWithoutGateway()
+			}
+
+			if tt.watched {
+				h.gateways = func() []gateway.Gateway { return tt.gateways }
+				h.ownedShards = tt.owned
+			}
+
+			for _, shardID := range tt.ready {
+				h.onReady(&events.Ready{GenericEvent: events.NewGenericEvent(nil, 0, shardID)})
+			}
+
+			checks := h.ready()
+			if checks["gateway"] != tt.expected || checks["migrations"] != "ok" {
+				t.Errorf("expected gateway %q, got %v", tt.expected, checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package host
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/disgoorg/disgo"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/cache"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/oops"
+
+	"eggmech/host/database"
+)
+
+const DatabasePath = "deployments/data/database.sqlite3"
+
+// Run migrates every module, sets them up on one client and one database pool, then blocks until interrupted.
+// opts are added to the client configuration, after the intents of the modules.
+func Run(ctx context.Context, config Config, modules []Module, logger *slog.Logger, opts ...bot.ConfigOpt) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	if len(modules) == 0 {
+		return oops.Errorf("no module enabled")
+	}
+
+	dialect, err := config.Database.Dialect()
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := setupTracing(ctx, config.Tracing, config.Modules)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing(context.WithoutCancel(ctx))
+
+	registry := NewRegistry()
+	health := newHealth(config.HTTP.MaxDisconnect)
+
+	if config.HTTP.Addr != "" {
+		go serveHTTP(ctx, config.HTTP.Addr, registry, health, logger)
+	}
+
+	err = migrate(ctx, config.Database, dialect, modules, logger)
+	if err != nil {
+		return err
+	}
+
+	db, err := dialect.Open(config.Database.DSN())
+	if err != nil {
+		return oops.Wrapf(err, "failed to connect to database")
+	}
+	defer db.Close()
+
+	health.setMigrated(db)
+
+	intents := gateway.IntentsNone
+
+	for _, module := range modules {
+		intents = intents.Add(module.Intents())
+	}
+
+	shards := Shards{Count: 1}
+
+	client, err := disgo.New(
+		config.Discord.Token,
+		append(clientOptions(config, intents, registry, health, &shards, logger), opts...)...,
+	)
+	if err != nil {
+		return oops.Wrapf(err, "error connecting to disgo")
+	}
+	defer client.Close(context.WithoutCancel(ctx))
+
+	liveGateways := func() []gateway.Gateway { return gateways(client) }
+	shards.live = liveGateways
+
+	host := Host{
+		Client:  client,
+		DB:      db,
+		Dialect: dialect,
+		Logger:  logger,
+		Metrics: registry,
+		Shards:  shards,
+	}
+
+	var commands []discord.ApplicationCommandCreate
+
+	names := make([]string, 0, len(modules))
+
+	for _, module := range modules {
+		registration, errSetup := module.Setup(ctx, host)
+		if errSetup != nil {
+			return oops.With("module", module.Name()).Wrapf(errSetup, "failed to set up module")
+		}
+
+		client.AddEventListeners(registration.Listeners...)
+		commands = append(commands, registration.Commands...)
+		names = append(names, module.Name())
+	}
+
+	if intents != gateway.IntentsNone {
+		checkPrivilegedIntents(ctx, client, intents, logger)
+
+		err = openGateway(ctx, client, commands)
+		if err != nil {
+			return err
+		}
+
+		registry.MustRegister(newShardCollector(liveGateways))
+
+		go health.watchGateways(ctx, liveGateways, host.Shards.Owned())
+	} else {
+		health.setWithoutGateway()
+	}
+
+	logger.InfoContext(
+		ctx,
+		"Bot is now running. Press CTRL-C to exit.",
+		slog.String("modules", strings.Join(names, ",")),
+		slog.Int("shard_count", shards.Count),
+		slog.Any("shard_ids", shards.IDs),
+	)
+	<-ctx.Done()
+
+	return nil
+}
+
+func migrate(
+	ctx context.Context,
+	config DatabaseConfig,
+	dialect database.Dialect,
+	modules []Module,
+	logger *slog.Logger,
+) error {
+	for _, module := range modules {
+		err := Migrate(ctx, config, module.Migrations(dialect), logger)
+		if err != nil {
+			return oops.With("module", module.Name()).Wrapf(err, "failed to run migration")
+		}
+	}
+
+	return nil
+}
+
+// clientOptions sets the gateway or the shards with the intents, the caches, the member chunk events,
+// the REST and shard metrics, the REST base URL, and reports Ready events to health.
+func clientOptions(
+	config Config,
+	intents gateway.Intents,
+	registry prometheus.Registerer,
+	health *health,
+	shards *Shards,
+	logger *slog.Logger,
+) []bot.ConfigOpt {
+	rateLimiter := newMetricsRateLimiter(rest.NewRateLimiter(rest.WithRateLimiterLogger(logger)), registry)
+
+	clientOpts := []bot.ConfigOpt{
+		bot.WithRestClientConfigOpts(rest.WithRateLimiter(rateLimiter)),
+		bot.WithEventListenerFunc(health.onReady),
+		bot.WithEventListenerFunc(newShardEventCounter(registry)),
+		bot.WithCaches(newCaches()),
+		bot.WithEventManagerConfigOpts(bot.WithGatewayHandlers(gatewayHandlers())),
+	}
+
+	if config.Sharding.Enabled {
+		clientOpts = append(clientOpts, shardingOptions(config.Sharding, intents, shards))
+	} else {
+		clientOpts = append(clientOpts, bot.WithGatewayConfigOpts(gateway.WithIntents(intents)))
+	}
+
+	if config.Discord.APIURL != "" {
+		clientOpts = append(clientOpts, bot.WithRestClientConfigOpts(rest.WithURL(config.Discord.APIURL)))
+	}
+
+	return clientOpts
+}
+
+// newCaches keeps what the permissions of the bot are computed from: the guilds, their channels and roles,
+// and the bot own member.
+func newCaches() cache.Caches {
+	var caches cache.Caches
+
+	caches = cache.New(
+		cache.WithCaches(cache.FlagGuilds|cache.FlagChannels|cache.FlagRoles|cache.FlagMembers),
+		cache.WithMemberCachePolicy(func(member discord.Member) bool {
+			selfUser, ok := caches.SelfUser()
+
+			return ok && member.User.ID == selfUser.ID
+		}),
+	)
+
+	return caches
+}
+
+// openGateway opens the shards of this process when the client is sharded, the single gateway otherwise.
+func openGateway(ctx context.Context, client bot.Client, commands []discord.ApplicationCommandCreate) error {
+	if client.HasShardManager() {
+		client.ShardManager().Open(ctx)
+	} else if err := client.OpenGateway(ctx); err != nil {
+		return oops.Wrapf(err, "error connecting to Discord")
+	}
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	_, err := client.Rest().SetGlobalCommands(client.ApplicationID(), commands)
+	if err != nil {
+		return oops.Wrapf(err, "error registering commands")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package host
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// NewRegistry is the registry served on /metrics, with the Go runtime and process collectors.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	return registry
+}
+
+// newShardEventCounter counts the gateway events received by each shard.
+func newShardEventCounter(registerer prometheus.Registerer) func(event bot.Event) {
+	received := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "eggmech_gateway_events_total",
+		Help: "Gateway events received by shard.",
+	}, []string{"shard_id"})
+
+	return func(event bot.Event) {
+		if shardEvent, ok := event.(interface{ ShardID() int }); ok {
+			received.WithLabelValues(strconv.Itoa(shardEvent.ShardID())).Inc()
+		}
+	}
+}
+
+// metricsRateLimiter counts the REST calls by route and status and times the rate limit waits,
+// disgo hands every request to the rate limiter before and after sending it.
+type metricsRateLimiter struct {
+	rest.RateLimiter
+	requests *prometheus.CounterVec
+	waits    *prometheus.HistogramVec
+}
+
+func newMetricsRateLimiter(rateLimiter rest.RateLimiter, registerer prometheus.Registerer) rest.RateLimiter {
+	factory := promauto.With(registerer)
+
+	return &metricsRateLimiter{
+		RateLimiter: rateLimiter,
+		requests: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "eggmech_rest_requests_total",
+			Help: "Discord REST calls by route and status, error when no response was received.",
+		}, []string{"method", "route", "status"}),
+		waits: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "eggmech_rest_rate_limit_wait_seconds",
+			Help:    "Time spent waiting for the rate limit bucket of a route before sending a REST call.",
+			Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"method", "route"}),
+	}
+}
+
+func (r *metricsRateLimiter) WaitBucket(ctx context.Context, endpoint *rest.CompiledEndpoint) error {
+	start := time.Now()
+	err := r.RateLimiter.WaitBucket(ctx, endpoint)
+	r.waits.WithLabelValues(endpoint.Endpoint.Method, endpoint.Endpoint.Route).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+func (r *metricsRateLimiter) UnlockBucket(endpoint *rest.CompiledEndpoint, rs *http.Response) error {
+	status := "error"
+	if rs != nil {
+		status = strconv.Itoa(rs.StatusCode)
+	}
+
+	r.requests.WithLabelValues(endpoint.Endpoint.Method, endpoint.Endpoint.Route, status).Inc()
+
+	return r.RateLimiter.UnlockBucket(endpoint, rs)
+}
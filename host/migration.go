@@ -0,0 +1,272 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/amacneil/dbmate/v2/pkg/dbmate"
+	"github.com/amacneil/dbmate/v2/pkg/dbutil"
+	_ "github.com/amacneil/dbmate/v2/pkg/driver/postgres" // Postgres driver
+	_ "github.com/amacneil/dbmate/v2/pkg/driver/sqlite"   // SQLite driver
+	"github.com/samber/oops"
+
+	"eggmech/host/database"
+)
+
+func Migrate(ctx context.Context, config DatabaseConfig, migrations Migrations, logger *slog.Logger) error {
+	databaseURL, err := config.MigrationURL()
+	if err != nil {
+		return err
+	}
+
+	db := newMigrator(databaseURL, migrations)
+
+	if migrations.SchemaFile != "" {
+		db.SchemaFile = migrations.SchemaFile
+	} else {
+		db.AutoDumpSchema = false
+	}
+
+	files, _ := fs.ReadDir(migrations.FS, migrations.Dir)
+
+	if len(files) == 1 && files[0].Name() == "blank.sql" {
+		return nil
+	}
+
+	if migrations.Prepare != nil {
+		err = prepare(ctx, config, migrations.Prepare)
+		if err != nil {
+			return oops.Wrapf(err, "failed to prepare migrations")
+		}
+	}
+
+	found, err := db.FindMigrations()
+	if err != nil {
+		return oops.Wrapf(err, "failed to find migrations")
+	}
+
+	for _, m := range found {
+		logger.InfoContext(ctx, "Migration", slog.String("version", m.Version), slog.String("file", m.FilePath))
+	}
+
+	err = db.CreateAndMigrate()
+	if err != nil {
+		return oops.Wrapf(err, "failed to create migration")
+	}
+
+	return nil
+}
+
+// CheckMigrations runs every migration up, down and up again against a scratch database,
+// then compares the resulting schema with schemaFile. With update, schemaFile is rewritten instead.
+// An empty postgresURL checks the SQLite migrations, otherwise it must point to a disposable database.
+func CheckMigrations(
+	ctx context.Context,
+	postgresURL string,
+	migrations Migrations,
+	schemaFile string,
+	update bool,
+	logger *slog.Logger,
+) error {
+	dir, err := os.MkdirTemp("", "eggmech-migrations")
+	if err != nil {
+		return oops.Wrapf(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	config := DatabaseConfig{Driver: database.SQLiteName, Path: filepath.Join(dir, "database.sqlite3")}
+	if postgresURL != "" {
+		config = DatabaseConfig{Driver: database.PostgresName, URL: postgresURL}
+	}
+
+	databaseURL, err := config.MigrationURL()
+	if err != nil {
+		return err
+	}
+
+	db := newMigrator(databaseURL, migrations)
+	db.SchemaFile = filepath.Join(dir, "database-schema.sql")
+	db.AutoDumpSchema = false
+	db.Log = io.Discard
+
+	err = db.CreateAndMigrate()
+	if err != nil {
+		return oops.Wrapf(err, "failed to migrate up")
+	}
+
+	for {
+		err = db.Rollback()
+		if errors.Is(err, dbmate.ErrNoRollback) {
+			break
+		}
+
+		if err != nil {
+			return oops.Wrapf(err, "failed to migrate down")
+		}
+	}
+
+	err = checkEmptySchema(ctx, config, migrations.TableName)
+	if err != nil {
+		return err
+	}
+
+	err = db.Migrate()
+	if err != nil {
+		return oops.Wrapf(err, "failed to migrate up again")
+	}
+
+	err = db.DumpSchema()
+	if err != nil {
+		return oops.Wrapf(err, "failed to dump schema")
+	}
+
+	schema, err := os.ReadFile(db.SchemaFile)
+	if err != nil {
+		return oops.Wrapf(err, "failed to read dumped schema")
+	}
+
+	if postgresURL != "" {
+		schema, err = normalizePostgresSchema(schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	if update {
+		logger.InfoContext(ctx, "schema file updated", slog.String("file", schemaFile))
+
+		return oops.Wrapf(os.WriteFile(schemaFile, schema, 0o600), "failed to write schema file")
+	}
+
+	expected, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return oops.Wrapf(err, "failed to read schema file")
+	}
+
+	if !bytes.Equal(expected, schema) {
+		return oops.
+			With("file", schemaFile).
+			Errorf("schema drift: migrations do not produce %s, regenerate it with -update-schema", schemaFile)
+	}
+
+	logger.InfoContext(ctx, "migrations are consistent", slog.String("file", schemaFile))
+
+	return nil
+}
+
+// normalizePostgresSchema drops the \restrict and \unrestrict lines, with the blank line after them, that pg_dump
+// writes with a random key since 17.6 (and 16.10, 15.14...), then the header comments dbmate kept because of them.
+func normalizePostgresSchema(schema []byte) ([]byte, error) {
+	var kept bytes.Buffer
+
+	skipBlank := false
+
+	for _, line := range bytes.SplitAfter(schema, []byte("\n")) {
+		if skipBlank && bytes.Equal(line, []byte("\n")) {
+			skipBlank = false
+
+			continue
+		}
+
+		skipBlank = bytes.HasPrefix(line, []byte(`\restrict `)) || bytes.HasPrefix(line, []byte(`\unrestrict `))
+		if !skipBlank {
+			kept.Write(line)
+		}
+	}
+
+	normalized, err := dbutil.TrimLeadingSQLComments(kept.Bytes())
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to trim schema header")
+	}
+
+	return normalized, nil
+}
+
+func newMigrator(databaseURL *url.URL, migrations Migrations) *dbmate.DB {
+	db := dbmate.New(databaseURL)
+	db.MigrationsDir = []string{migrations.Dir}
+	db.FS = migrations.FS
+
+	if migrations.Log != nil {
+		db.Log = migrations.Log
+	}
+
+	if migrations.TableName != "" {
+		db.MigrationsTableName = migrations.TableName
+	}
+
+	return db
+}
+
+func prepare(ctx context.Context, config DatabaseConfig, prepare func(ctx context.Context, db *sql.DB) error) error {
+	dialect, err := config.Dialect()
+	if err != nil {
+		return err
+	}
+
+	if _, errStat := os.Stat(config.Path); !dialect.IsPostgres() && errors.Is(errStat, os.ErrNotExist) {
+		return nil
+	}
+
+	db, err := sql.Open(dialect.DriverName(), config.DSN())
+	if err != nil {
+		return oops.Wrapf(err, "failed to open database")
+	}
+	defer db.Close()
+
+	return prepare(ctx, db)
+}
+
+func checkEmptySchema(ctx context.Context, config DatabaseConfig, tableName string) error {
+	dialect, err := config.Dialect()
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(dialect.DriverName(), config.DSN())
+	if err != nil {
+		return oops.Wrapf(err, "failed to open database")
+	}
+	defer db.Close()
+
+	if tableName == "" {
+		tableName = "schema_migrations"
+	}
+
+	rows, err := db.QueryContext(ctx, dialect.SchemaObjects(), tableName)
+	if err != nil {
+		return oops.Wrapf(err, "failed to list schema objects")
+	}
+	defer rows.Close()
+
+	var leftovers []string
+
+	for rows.Next() {
+		var objectType, name string
+
+		err = rows.Scan(&objectType, &name)
+		if err != nil {
+			return oops.Wrapf(err, "failed to scan row")
+		}
+
+		leftovers = append(leftovers, objectType+" "+name)
+	}
+
+	if rows.Err() != nil {
+		return oops.Wrapf(rows.Err(), "failed to fetch rows")
+	}
+
+	if len(leftovers) > 0 {
+		return oops.With("objects", leftovers).Errorf("down migrations left %d schema objects", len(leftovers))
+	}
+
+	return nil
+}
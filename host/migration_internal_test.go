@@ -0,0 +1,25 @@
+package host
+
+import (
+	"testing"
+)
+
+func TestNormalizePostgresSchema(t *testing.T) {
+	// what dbmate dumps with pg_dump before and since 17.6, the header of the former is already trimmed
+	before := "SET statement_timeout = 0;\n\nCREATE TABLE public.t (\n    id bigint NOT NULL\n);\n\n\n" +
+		"--\n-- PostgreSQL database dump complete\n--\n\n\n--\n-- Dbmate schema migrations\n--\n"
+	since := "\\restrict Ab3dE\n\n-- Dumped from database version 17.6\n-- Dumped by pg_dump version 17.6\n\n" +
+		"SET statement_timeout = 0;\n\nCREATE TABLE public.t (\n    id bigint NOT NULL\n);\n\n\n" +
+		"--\n-- PostgreSQL database dump complete\n--\n\n\\unrestrict Ab3dE\n\n\n--\n-- Dbmate schema migrations\n--\n"
+
+	for name, schema := range map[string]string{"before 17.6": before, "since 17.6": since} {
+		normalized, err := normalizePostgresSchema([]byte(schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(normalized) != before {
+			t.Errorf("expected the schema dumped %s to normalize to\n%s\ngot\n%s", name, before, normalized)
+		}
+	}
+}
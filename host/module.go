@@ -0,0 +1,61 @@
+package host
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"io/fs"
+	"log/slog"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"eggmech/host/database"
+)
+
+// Module is one eggmech feature run by the host, on the shared gateway connection and database.
+type Module interface {
+	Name() string
+	// Intents needed by the module, the host opens the gateway only when at least one module needs it.
+	Intents() gateway.Intents
+	// Migrations of the dialect the host runs on.
+	Migrations(dialect database.Dialect) Migrations
+	// Setup is called once migrations ran and the client exists, before the gateway is opened.
+	Setup(ctx context.Context, host Host) (Registration, error)
+	// RedactedConfig is printed by --print-config, secrets must be hidden.
+	RedactedConfig() any
+}
+
+// Migrations of a module, run by dbmate.
+type Migrations struct {
+	FS  fs.FS
+	Dir string
+	// TableName records the applied versions, schema_migrations when empty. Modules sharing a table must not reuse
+	// each other's versions.
+	TableName string
+	// SchemaFile receives the schema dump after migrating, optional.
+	SchemaFile string
+	// Log receives the dbmate output, os.Stdout when nil.
+	Log io.Writer
+	// Prepare runs before dbmate on an existing database, for fixes dbmate cannot do itself.
+	Prepare func(ctx context.Context, db *sql.DB) error
+}
+
+type Registration struct {
+	Listeners []bot.EventListener
+	Commands  []discord.ApplicationCommandCreate
+}
+
+// Host is what the modules share.
+// Host.Metrics is where modules register their collectors, they are served with the host ones on /metrics.
+// Host.Shards tells which guilds the events of Client come from.
+type Host struct {
+	Client  bot.Client
+	DB      *sql.DB
+	Dialect database.Dialect
+	Logger  *slog.Logger
+	Metrics prometheus.Registerer
+	Shards  Shards
+}
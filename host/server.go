@@ -0,0 +1,41 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/samber/oops"
+)
+
+// serveHTTP serves /metrics, /healthz and /readyz on addr until ctx is done.
+func serveHTTP(ctx context.Context, addr string, registry *prometheus.Registry, health *health, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry}))
+	mux.HandleFunc("GET /healthz", health.handleLive)
+	mux.HandleFunc("GET /readyz", health.handleReady)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	})
+
+	logger.InfoContext(ctx, "serving metrics and health", slog.String("addr", addr))
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.ErrorContext(ctx, "http server stopped", slog.Any("error", oops.Wrap(err)))
+	}
+}
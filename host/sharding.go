@@ -0,0 +1,148 @@
+package host
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/disgo/sharding"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// shardingOptions configure the shard manager, disgo fills in the recommended count before them.
+// shards receives the shards of this process once disgo applied them.
+func shardingOptions(config ShardingConfig, intents gateway.Intents, shards *Shards) bot.ConfigOpt {
+	return bot.WithShardManagerConfigOpts(
+		sharding.WithGatewayConfigOpts(gateway.WithIntents(intents)),
+		sharding.WithAutoScaling(config.AutoScaling),
+		func(shardingConfig *sharding.Config) {
+			if config.Count > 0 {
+				shardingConfig.ShardCount = config.Count
+			}
+
+			*shards = Shards{Count: shardingConfig.ShardCount, IDs: config.IDs}
+
+			shardIDs := config.IDs
+			if len(shardIDs) == 0 {
+				for shardID := range shardingConfig.ShardCount {
+					shardIDs = append(shardIDs, shardID)
+				}
+			}
+
+			shardingConfig.ShardIDs = map[int]struct{}{}
+			for _, shardID := range shardIDs {
+				shardingConfig.ShardIDs[shardID] = struct{}{}
+			}
+		},
+	)
+}
+
+// gateways are the shards of the client, its single gateway when it is not sharded.
+func gateways(client bot.Client) []gateway.Gateway {
+	if client.HasShardManager() {
+		shards := make([]gateway.Gateway, 0, len(client.ShardManager().Shards()))
+		for _, shard := range client.ShardManager().Shards() {
+			shards = append(shards, shard)
+		}
+
+		return shards
+	}
+
+	if client.HasGateway() {
+		return []gateway.Gateway{client.Gateway()}
+	}
+
+	return nil
+}
+
+// Shards are the gateway shards run by this process, every shard when IDs is empty, as without sharding.
+// Per-guild state, such as queues and caches, must only be kept by the process owning the guild.
+// Count and IDs are the ones the process started with, auto scaling may split the shards later.
+type Shards struct {
+	Count int
+	IDs   []int
+	// live are the open shards, they follow the splits of auto scaling
+	live func() []gateway.Gateway
+}
+
+// OwnsGuild tells if the events of guildID are received by this process, by the open shards once there are some.
+func (s Shards) OwnsGuild(guildID snowflake.ID) bool {
+	if len(s.IDs) == 0 {
+		return true
+	}
+
+	if s.live != nil {
+		if shards := s.live(); len(shards) > 0 {
+			return slices.ContainsFunc(shards, func(shard gateway.Gateway) bool {
+				return shard.ShardID() == sharding.ShardIDByGuild(guildID, shard.ShardCount())
+			})
+		}
+	}
+
+	return slices.Contains(s.IDs, sharding.ShardIDByGuild(guildID, s.Count))
+}
+
+// Owned are the IDs of the shards run by this process.
+func (s Shards) Owned() []int {
+	if len(s.IDs) > 0 {
+		return s.IDs
+	}
+
+	owned := make([]int, 0, s.Count)
+	for shardID := range s.Count {
+		owned = append(owned, shardID)
+	}
+
+	return owned
+}
+
+// OwnsShard tells if this process runs shardID, jobs that must run once over all the processes run with shard 0.
+func (s Shards) OwnsShard(shardID int) bool {
+	return len(s.IDs) == 0 || slices.Contains(s.IDs, shardID)
+}
+
+// shardCollector reports the connection and heartbeat latency of every shard when scraped.
+type shardCollector struct {
+	gateways  func() []gateway.Gateway
+	connected *prometheus.Desc
+	latency   *prometheus.Desc
+}
+
+func newShardCollector(gateways func() []gateway.Gateway) *shardCollector {
+	return &shardCollector{
+		gateways: gateways,
+		connected: prometheus.NewDesc(
+			"eggmech_gateway_shard_connected",
+			"1 while the gateway shard is connected.",
+			[]string{"shard_id"},
+			nil,
+		),
+		latency: prometheus.NewDesc(
+			"eggmech_gateway_shard_latency_seconds",
+			"Last heartbeat round trip of the gateway shard.",
+			[]string{"shard_id"},
+			nil,
+		),
+	}
+}
+
+func (c *shardCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.connected
+	descs <- c.latency
+}
+
+func (c *shardCollector) Collect(metrics chan<- prometheus.Metric) {
+	for _, shard := range c.gateways() {
+		shardID := strconv.Itoa(shard.ShardID())
+
+		connected := 0.0
+		if shard.Status().IsConnected() {
+			connected = 1
+		}
+
+		metrics <- prometheus.MustNewConstMetric(c.connected, prometheus.GaugeValue, connected, shardID)
+		metrics <- prometheus.MustNewConstMetric(c.latency, prometheus.GaugeValue, shard.Latency().Seconds(), shardID)
+	}
+}
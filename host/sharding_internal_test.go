@@ -0,0 +1,76 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// guildOnShard is a guild ID whose shard is shardID for any count above shardID.
+func guildOnShard(shardID int) snowflake.ID {
+	return snowflake.ID(uint64(shardID) << 22)
+}
+
+func TestShardsOwnsGuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		shards   Shards
+		guildID  snowflake.ID
+		expected bool
+	}{
+		{
+			name:     "every shard",
+			shards:   Shards{Count: 2},
+			guildID:  guildOnShard(1),
+			expected: true,
+		},
+		{
+			name:     "shard of another process",
+			shards:   Shards{Count: 2, IDs: []int{0}},
+			guildID:  guildOnShard(1),
+			expected: false,
+		},
+		{
+			name: "shards not opened yet",
+			shards: Shards{Count: 2, IDs: []int{0}, live: func() []gateway.Gateway {
+				return nil
+			}},
+			guildID:  guildOnShard(0),
+			expected: true,
+		},
+		{
+			name: "split by auto scaling into a shard of this process",
+			shards: Shards{Count: 2, IDs: []int{0}, live: func() []gateway.Gateway {
+				return []gateway.Gateway{stubGateway{shardID: 0, shardCount: 4}, stubGateway{shardID: 2, shardCount: 4}}
+			}},
+			guildID:  guildOnShard(2),
+			expected: true,
+		},
+		{
+			name: "split by auto scaling out of this process",
+			shards: Shards{Count: 2, IDs: []int{1}, live: func() []gateway.Gateway {
+				return []gateway.Gateway{stubGateway{shardID: 1, shardCount: 4}, stubGateway{shardID: 3, shardCount: 4}}
+			}},
+			guildID:  guildOnShard(6),
+			expected: false,
+		},
+		{
+			name: "open shards over the ones the process started with",
+			shards: Shards{Count: 2, IDs: []int{0}, live: func() []gateway.Gateway {
+				return []gateway.Gateway{stubGateway{shardID: 1, shardCount: 2}}
+			}},
+			guildID:  guildOnShard(1),
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			owns := test.shards.OwnsGuild(test.guildID)
+			if owns != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, owns)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package host
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/samber/oops"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "eggmech"
+
+// untracedContext are the oops context keys EndSpan leaves out of the spans.
+var untracedContext = map[string]bool{"user_id": true}
+
+// userHashKey keys UserHash, setupTracing sets it from the configuration.
+var userHashKey []byte
+
+// setupTracing installs the global tracer provider exporting to config.Endpoint over OTLP/HTTP, spans are
+// dropped when no endpoint is set. The returned function flushes the pending spans.
+func setupTracing(ctx context.Context, config TracingConfig, modules []string) (func(context.Context) error, error) {
+	SetUserHashKey(config.UserHashKey)
+
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.Endpoint))
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to create OTLP exporter")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(serviceName),
+			attribute.StringSlice("eggmech.modules", modules),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// SetUserHashKey keys UserHash with key, UserHash is empty when key is.
+func SetUserHashKey(key string) {
+	userHashKey = []byte(key)
+}
+
+// UserHash groups the spans of a user without the user ID, an HMAC keyed by a secret cannot be reversed by hashing
+// every snowflake. It is empty when no key is set.
+func UserHash(userID snowflake.ID) string {
+	if len(userHashKey) == 0 {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, userHashKey)
+	_, _ = mac.Write([]byte(userID.String()))
+
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// EndSpan ends span, marking it failed when err is set, with the oops code, hint and context as attributes.
+// The user IDs of the context are left out.
+func EndSpan(span trace.Span, err error) {
+	defer span.End()
+
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	oopsError, ok := oops.AsOops(err)
+	if !ok {
+		return
+	}
+
+	attributes := []attribute.KeyValue{}
+
+	if code := oopsError.Code(); code != "" {
+		attributes = append(attributes, attribute.String("error.code", code))
+	}
+
+	if hint := oopsError.Hint(); hint != "" {
+		attributes = append(attributes, attribute.String("error.hint", hint))
+	}
+
+	for key, value := range oopsError.Context() {
+		if untracedContext[key] {
+			continue
+		}
+
+		attributes = append(attributes, attribute.String("error.context."+key, fmt.Sprint(value)))
+	}
+
+	if tags := oopsError.Tags(); len(tags) > 0 {
+		attributes = append(attributes, attribute.String("error.tags", strings.Join(tags, ",")))
+	}
+
+	span.SetAttributes(attributes...)
+}